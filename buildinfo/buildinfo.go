@@ -0,0 +1,53 @@
+// Package buildinfo reports which build is running. Release builds stamp
+// it with -ldflags:
+//
+//	go build -ldflags "-X class-go-ai/buildinfo.Commit=$(git rev-parse HEAD) \
+//	  -X class-go-ai/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Without them, the VCS details Go embeds in builds from a checkout are used.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Set at link time with -X
+var (
+	Commit    string
+	BuildTime string
+)
+
+// Info describes the running build
+type Info struct {
+	Commit     string `json:"commit"`
+	CommitTime string `json:"commitTime,omitempty"`
+	Modified   bool   `json:"modified,omitempty"` // built from a checkout with uncommitted changes
+	BuildTime  string `json:"buildTime,omitempty"`
+	GoVersion  string `json:"goVersion"`
+}
+
+// Get returns the stamped build details, falling back to the VCS details
+// embedded by the Go toolchain for the commit. The commit is "unknown" when
+// neither is set, as under go run or go test.
+func Get() Info {
+	info := Info{Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()}
+
+	if build, ok := debug.ReadBuildInfo(); ok && info.Commit == "" {
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Commit = setting.Value
+			case "vcs.time":
+				info.CommitTime = setting.Value
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	return info
+}
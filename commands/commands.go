@@ -0,0 +1,65 @@
+// Package commands implements the CLI subcommands of the server binary,
+// e.g. `go run . reconcile -repair`
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"class-go-ai/config"
+	"class-go-ai/database"
+)
+
+// command runs a subcommand with the arguments that follow its name
+type command func(args []string) error
+
+var registry = map[string]command{
+	"migrate":   Migrate,
+	"reconcile": Reconcile,
+	"replay":    Replay,
+	"set-role":  SetRole,
+}
+
+// Run dispatches args[0] to the matching subcommand
+func Run(args []string) error {
+	run, ok := registry[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q (available: %s)", args[0], strings.Join(names(), ", "))
+	}
+	return run(args[1:])
+}
+
+func names() []string {
+	list := make([]string, 0, len(registry))
+	for name := range registry {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// open loads the configuration from CONFIG_FILE and the environment, and
+// connects to its database
+func open() (*config.Config, error) {
+	cfg, err := config.Load(nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := database.Connect(cfg.Database); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// connect is open, refusing to work on an unmigrated schema
+func connect() (*config.Config, error) {
+	cfg, err := open()
+	if err != nil {
+		return nil, err
+	}
+	if err := database.CheckSchema(database.DB); err != nil {
+		return nil, fmt.Errorf("%w (run `migrate up`)", err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+
+	"class-go-ai/database"
+)
+
+// Migrate applies or rolls back schema migrations:
+//
+//	migrate up             apply every pending migration
+//	migrate down [-steps]  roll back the latest migration (or -steps of them)
+//	migrate to <version>   move up or down to version, 0 rolls back everything
+//	migrate status         list migrations and whether they are applied
+func Migrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up | down [-steps n] | to <version> | status")
+	}
+
+	if _, err := open(); err != nil {
+		return err
+	}
+	migrator, err := database.NewMigrator(database.DB)
+	if err != nil {
+		return err
+	}
+
+	var done []database.Migration
+	direction := "applied"
+
+	switch args[0] {
+	case "up":
+		done, err = migrator.Up()
+
+	case "down":
+		flags := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+		steps := flags.Int("steps", 1, "number of migrations to roll back")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *steps < 1 {
+			return errors.New("-steps must be at least 1")
+		}
+		direction = "rolled back"
+		done, err = migrator.Down(*steps)
+
+	case "to":
+		if len(args) != 2 {
+			return errors.New("usage: migrate to <version>")
+		}
+		version, convErr := strconv.Atoi(args[1])
+		if convErr != nil || version < 0 {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		current, versionErr := migrator.Version()
+		if versionErr != nil {
+			return versionErr
+		}
+		if version < current {
+			direction = "rolled back"
+		}
+		done, err = migrator.To(version)
+
+	case "status":
+		return printStatus(migrator)
+
+	default:
+		return fmt.Errorf("unknown migrate action %q", args[0])
+	}
+
+	for _, migration := range done {
+		fmt.Printf("%s %04d_%s\n", direction, migration.Version, migration.Name)
+	}
+	if err != nil {
+		return err
+	}
+
+	version, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("schema at version %d (latest %d)\n", version, migrator.Latest())
+	return nil
+}
+
+func printStatus(migrator *database.Migrator) error {
+	status, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range status {
+		applied := "pending"
+		if migration.Applied {
+			applied = "applied " + migration.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%04d_%-20s %s\n", migration.Version, migration.Name, applied)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+
+	"class-go-ai/database"
+	"class-go-ai/services"
+)
+
+// ErrDiscrepancies is returned when reconciliation finds problems, so the
+// process exits non-zero for cron and CI
+var ErrDiscrepancies = errors.New("reconciliation found discrepancies")
+
+// Reconcile prints a JSON reconciliation report to stdout
+func Reconcile(args []string) error {
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	repair := flags.Bool("repair", false, "post adjust entries so the ledger matches user balances")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if _, err := connect(); err != nil {
+		return err
+	}
+
+	report, err := services.NewReconcileService(database.DB).Run(*repair)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+
+	if !report.Clean() && !*repair {
+		return ErrDiscrepancies
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"class-go-ai/database"
+	"class-go-ai/services"
+)
+
+// Replay rebuilds user balances from the ledger and prints a JSON report.
+// It only reports what would change unless -apply is given.
+func Replay(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	apply := flags.Bool("apply", false, "write the replayed balances instead of only reporting them")
+	asOfValue := flags.String("as-of", "", "replay entries up to this RFC 3339 time only (report only)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var asOf *time.Time
+	if *asOfValue != "" {
+		if *apply {
+			return services.ErrReplayAsOf
+		}
+		t, err := time.Parse(time.RFC3339, *asOfValue)
+		if err != nil {
+			return fmt.Errorf("invalid -as-of: %w", err)
+		}
+		t = t.Local()
+		asOf = &t
+	}
+
+	if _, err := connect(); err != nil {
+		return err
+	}
+
+	report, err := services.NewReplayService(database.DB).Replay(asOf, !*apply)
+	if err != nil && !errors.Is(err, services.ErrReplayInconsistent) {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if encodeErr := encoder.Encode(report); encodeErr != nil {
+		return encodeErr
+	}
+	return err
+}
@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"class-go-ai/database"
+	"class-go-ai/models"
+	"class-go-ai/repository"
+	"class-go-ai/services"
+)
+
+// SetRole changes a user's role, e.g. to bootstrap the first admin
+func SetRole(args []string) error {
+	flags := flag.NewFlagSet("set-role", flag.ContinueOnError)
+	email := flags.String("email", "", "email of the user to change")
+	role := flags.String("role", "", "new role: member, support or admin")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *email == "" || *role == "" {
+		return errors.New("-email and -role are required")
+	}
+
+	cfg, err := connect()
+	if err != nil {
+		return err
+	}
+
+	var user models.User
+	if err := database.DB.Where("email = ?", *email).First(&user).Error; err != nil {
+		return fmt.Errorf("user %s: %w", *email, err)
+	}
+
+	auth := services.NewAuthService(repository.NewGormStore(database.DB), []byte(cfg.Auth.JWTSecret))
+	updated, err := auth.SetRole(user.ID, models.Role(*role))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("user %d (%s) is now %s\n", updated.ID, updated.Email, updated.Role)
+	return nil
+}
@@ -0,0 +1,267 @@
+// Package config loads the server settings. Each setting comes from, in
+// increasing priority: its default, a JSON config file, an environment
+// variable, and a command line flag.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds every setting of the server
+type Config struct {
+	Server   Server   `json:"server"`
+	Database Database `json:"database"`
+	CORS     CORS     `json:"cors"`
+	Limits   Limits   `json:"limits"`
+	Auth     Auth     `json:"auth"`
+}
+
+// Server configures the HTTP listener
+type Server struct {
+	Port            int      `json:"port"`
+	BodyLimit       int      `json:"bodyLimit"`       // largest accepted request body in bytes
+	ShutdownDelay   Duration `json:"shutdownDelay"`   // how long to keep serving with readiness failing on SIGTERM
+	ShutdownTimeout Duration `json:"shutdownTimeout"` // how long to drain requests and workers after that
+}
+
+// Database selects the database, see database.Open for the URL forms
+type Database struct {
+	URL string `json:"url"`
+}
+
+// CORS restricts which browser origins may call the API
+type CORS struct {
+	AllowOrigins     []string `json:"allowOrigins"` // "*" allows any origin
+	AllowCredentials bool     `json:"allowCredentials"`
+}
+
+// Limits bounds what a single request may ask for
+type Limits struct {
+	MaxPageSize int      `json:"maxPageSize"` // largest page of transfers or ledger entries
+	HoldTTL     Duration `json:"holdTtl"`     // how long a hold stays pending
+}
+
+// Auth holds the signing secrets. They are read from the file or the
+// environment only, never from flags, which other users can see.
+type Auth struct {
+	JWTSecret            string `json:"jwtSecret"`
+	PartnerSigningSecret string `json:"partnerSigningSecret"`
+}
+
+// Duration is a time.Duration written as "15m" in JSON
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string such as "90s" or "15m"
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string such as \"15m\": %w", err)
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON writes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Default returns the settings used when nothing overrides them
+func Default() *Config {
+	return &Config{
+		Server:   Server{Port: 3000, BodyLimit: 4 * 1024 * 1024, ShutdownTimeout: Duration(30 * time.Second)},
+		Database: Database{URL: "users.db"},
+		CORS:     CORS{AllowOrigins: []string{"*"}},
+		Limits:   Limits{MaxPageSize: 200, HoldTTL: Duration(15 * time.Minute)},
+	}
+}
+
+// Load builds the configuration from the defaults, the JSON file named by
+// -config or CONFIG_FILE, the environment and then the flags in args, and
+// validates it
+func Load(args []string) (*Config, error) {
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	file := flags.String("config", os.Getenv("CONFIG_FILE"), "JSON config file")
+	port := flags.Int("port", 0, "HTTP port")
+	databaseURL := flags.String("database-url", "", "database URL or SQLite path")
+	origins := flags.String("cors-origins", "", "comma separated allowed CORS origins")
+	maxPageSize := flags.Int("max-page-size", 0, "largest page size a list request may ask for")
+	holdTTL := flags.Duration("hold-ttl", 0, "how long holds stay pending")
+	shutdownDelay := flags.Duration("shutdown-delay", 0, "how long to keep serving with readiness failing on SIGTERM")
+	shutdownTimeout := flags.Duration("shutdown-timeout", 0, "how long to drain on SIGTERM before giving up")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+	if *file != "" {
+		if err := cfg.loadFile(*file); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	// Only flags given on the command line override
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "port":
+			cfg.Server.Port = *port
+		case "database-url":
+			cfg.Database.URL = *databaseURL
+		case "cors-origins":
+			cfg.CORS.AllowOrigins = splitList(*origins)
+		case "max-page-size":
+			cfg.Limits.MaxPageSize = *maxPageSize
+		case "hold-ttl":
+			cfg.Limits.HoldTTL = Duration(*holdTTL)
+		case "shutdown-delay":
+			cfg.Server.ShutdownDelay = Duration(*shutdownDelay)
+		case "shutdown-timeout":
+			cfg.Server.ShutdownTimeout = Duration(*shutdownTimeout)
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadEnv applies the environment variables that are set
+func (c *Config) loadEnv() error {
+	var errs []error
+	integer := func(name string, target *int) {
+		if value, ok := os.LookupEnv(name); ok {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s must be an integer, got %q", name, value))
+				return
+			}
+			*target = parsed
+		}
+	}
+	str := func(name string, target *string) {
+		if value, ok := os.LookupEnv(name); ok {
+			*target = value
+		}
+	}
+
+	integer("PORT", &c.Server.Port)
+	integer("BODY_LIMIT", &c.Server.BodyLimit)
+	str("DATABASE_URL", &c.Database.URL)
+	integer("MAX_PAGE_SIZE", &c.Limits.MaxPageSize)
+	str("JWT_SECRET", &c.Auth.JWTSecret)
+	str("PARTNER_SIGNING_SECRET", &c.Auth.PartnerSigningSecret)
+
+	if value, ok := os.LookupEnv("CORS_ALLOW_ORIGINS"); ok {
+		c.CORS.AllowOrigins = splitList(value)
+	}
+	if value, ok := os.LookupEnv("CORS_ALLOW_CREDENTIALS"); ok {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("CORS_ALLOW_CREDENTIALS must be true or false, got %q", value))
+		}
+		c.CORS.AllowCredentials = parsed
+	}
+	duration := func(name string, target *Duration) {
+		if value, ok := os.LookupEnv(name); ok {
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s must be a duration such as 30m, got %q", name, value))
+			}
+			*target = Duration(parsed)
+		}
+	}
+	duration("HOLD_TTL", &c.Limits.HoldTTL)
+	duration("SHUTDOWN_DELAY", &c.Server.ShutdownDelay)
+	duration("SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout)
+
+	return errors.Join(errs...)
+}
+
+// Validate reports every invalid setting at once
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port must be between 1 and 65535, got %d", c.Server.Port))
+	}
+	if c.Server.BodyLimit < 1 {
+		errs = append(errs, fmt.Errorf("server.bodyLimit must be positive, got %d", c.Server.BodyLimit))
+	}
+	if c.Server.ShutdownDelay < 0 {
+		errs = append(errs, fmt.Errorf("server.shutdownDelay cannot be negative, got %s", time.Duration(c.Server.ShutdownDelay)))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("server.shutdownTimeout must be positive, got %s", time.Duration(c.Server.ShutdownTimeout)))
+	}
+	if c.Database.URL == "" {
+		errs = append(errs, errors.New("database.url is required"))
+	}
+
+	if len(c.CORS.AllowOrigins) == 0 {
+		errs = append(errs, errors.New("cors.allowOrigins needs at least one origin, or \"*\""))
+	}
+	for _, origin := range c.CORS.AllowOrigins {
+		if origin == "*" {
+			if c.CORS.AllowCredentials {
+				errs = append(errs, errors.New("cors.allowCredentials cannot be used with the \"*\" origin"))
+			}
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" {
+			errs = append(errs, fmt.Errorf("cors.allowOrigins: %q is not an origin such as https://app.example.com", origin))
+		}
+	}
+
+	if c.Limits.MaxPageSize < 1 {
+		errs = append(errs, fmt.Errorf("limits.maxPageSize must be positive, got %d", c.Limits.MaxPageSize))
+	}
+	if c.Limits.HoldTTL <= 0 {
+		errs = append(errs, fmt.Errorf("limits.holdTtl must be positive, got %s", time.Duration(c.Limits.HoldTTL)))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
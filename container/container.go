@@ -0,0 +1,91 @@
+// Package container wires the configuration, the database and the services
+// of one application instance together, so each instance (the server, or an
+// app in a test) owns its own state instead of sharing package globals
+package container
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"class-go-ai/config"
+	"class-go-ai/events"
+	"class-go-ai/metrics"
+	"class-go-ai/middleware"
+	"class-go-ai/models"
+	"class-go-ai/repository"
+	"class-go-ai/services"
+	"class-go-ai/workers"
+
+	"gorm.io/gorm"
+)
+
+// Container holds everything the routes and workers of an app depend on
+type Container struct {
+	Config *config.Config
+	DB     *gorm.DB
+	Store  repository.Store
+
+	// Broker publishes committed ledger and transfer changes to live streams
+	Broker *events.Broker
+	// Metrics counts requests and committed changes for /metrics
+	Metrics *metrics.Metrics
+
+	Auth      *services.AuthService
+	APIKeys   *services.APIKeyService
+	Transfers *services.TransferService
+	Points    *services.PointsService
+	Ledger    *services.LedgerService
+	Schedules *services.ScheduleService
+	Webhooks  *services.WebhookService
+	Reconcile *services.ReconcileService
+
+	// Workers runs the background jobs; readiness checks their heartbeats
+	Workers *workers.Group
+	// Draining is set once a graceful shutdown begins, failing readiness
+	Draining atomic.Bool
+}
+
+// New builds the services on db and applies cfg to them. Auth, API keys,
+// transfers, points and the ledger go through Store; schedules, webhooks and
+// reconciliation still query db directly.
+func New(cfg *config.Config, db *gorm.DB) *Container {
+	store := repository.NewGormStore(db)
+	broker := events.NewBroker()
+	meter := metrics.New(db, broker)
+	publisher := events.Publishers{broker, meter}
+
+	transfers := services.NewTransferService(store)
+	transfers.SetPublisher(publisher)
+	transfers.SetMetrics(meter)
+	transfers.SetHoldTTL(time.Duration(cfg.Limits.HoldTTL))
+	transfers.SetMaxPageSize(cfg.Limits.MaxPageSize)
+
+	points := services.NewPointsService(store)
+	points.SetPublisher(publisher)
+
+	ledger := services.NewLedgerService(store)
+	ledger.SetMaxPageSize(cfg.Limits.MaxPageSize)
+
+	webhooks := services.NewWebhookService(db)
+	webhooks.SetAllUsersPermission(func(role models.Role) bool {
+		return middleware.RoleCan(role, middleware.PermTransfersRead)
+	})
+
+	return &Container{
+		Config:    cfg,
+		DB:        db,
+		Store:     store,
+		Broker:    broker,
+		Metrics:   meter,
+		Auth:      services.NewAuthService(store, []byte(cfg.Auth.JWTSecret)),
+		APIKeys:   services.NewAPIKeyService(store),
+		Transfers: transfers,
+		Points:    points,
+		Ledger:    ledger,
+		Schedules: services.NewScheduleService(db, transfers),
+		Webhooks:  webhooks,
+		Reconcile: services.NewReconcileService(db),
+		Workers:   workers.NewGroup(context.Background()),
+	}
+}
@@ -3,21 +3,27 @@ package database
 import (
 	"log"
 
+	"class-go-ai/config"
 	"class-go-ai/models"
 
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
 var DB *gorm.DB
 
-// Initialize database connection and auto-migrate models
-func Connect() error {
+// Models lists every table the application uses, in dependency order
+func Models() []any {
+	return []any{&models.User{}, &models.Transfer{}, &models.PointLedger{}, &models.LedgerHead{}, &models.ScheduledTransfer{}, &models.ScheduledTransferRun{}, &models.RefreshToken{}, &models.APIKey{}, &models.WebhookSubscription{}, &models.OutboxEvent{}, &models.WebhookDelivery{}}
+}
+
+// Initialize database connection. The schema is managed by migrations,
+// see Migrator.
+func Connect(cfg config.Database) error {
 	var err error
 	
-	// Open database connection
-	DB, err = gorm.Open(sqlite.Open("users.db"), &gorm.Config{
+	// Open database connection; the URL selects the driver, see Open
+	DB, err = Open(cfg.URL, &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Silent),
 	})
 	
@@ -25,13 +31,7 @@ func Connect() error {
 		return err
 	}
 
-	// Auto migrate the schema
-	err = DB.AutoMigrate(&models.User{}, &models.Transfer{}, &models.PointLedger{})
-	if err != nil {
-		return err
-	}
-
-	log.Println("Database connected and migrated successfully")
+	log.Println("Database connected successfully")
 	return nil
 }
 
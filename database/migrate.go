@@ -0,0 +1,386 @@
+package database
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"class-go-ai/models"
+
+	"gorm.io/gorm"
+)
+
+// migrationFiles holds one directory of migrations per dialect, named
+// NNNN_name.up.sql and NNNN_name.down.sql. Statements end with a semicolon
+// at the end of a line.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+var migrationName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+var (
+	ErrSchemaOutdated = errors.New("database schema is out of date")
+	ErrSchemaTooNew   = errors.New("database schema is newer than this build")
+	ErrNoMigration    = errors.New("no such migration version")
+	ErrNotAdopted     = errors.New("database predates versioned migrations; migrate it up before rolling back")
+)
+
+// Migration is one versioned schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// SchemaMigration records an applied migration
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:255;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// TableName keeps the conventional name instead of schema_migration_s
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// MigrationStatus tells whether a migration has been applied
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+}
+
+// Migrator applies the embedded migrations of the database's dialect
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// NewMigrator loads the migrations for db's dialect
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	migrations, err := loadMigrations(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+func loadMigrations(dialect string) ([]Migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for %s: %w", dialect, err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		script, err := fs.ReadFile(migrationFiles, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration := byVersion[version]
+		if migration == nil {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if match[3] == "up" {
+			migration.Up = string(script)
+		} else {
+			migration.Down = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down script", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Latest returns the version this build expects
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the highest applied version, 0 for an empty database
+func (m *Migrator) Version() (int, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+
+	version := 0
+	for v := range applied {
+		version = max(version, v)
+	}
+	return version, nil
+}
+
+// Status lists every migration with whether it has been applied
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		entry := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if row, ok := applied[migration.Version]; ok {
+			entry.Applied = true
+			entry.AppliedAt = &row.AppliedAt
+		}
+		status = append(status, entry)
+	}
+	return status, nil
+}
+
+// Check returns ErrSchemaOutdated if migrations are pending, and
+// ErrSchemaTooNew if the database has versions this build does not know
+func (m *Migrator) Check() error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+
+	for version := range applied {
+		if m.find(version) == nil {
+			return fmt.Errorf("%w: version %d is applied but unknown", ErrSchemaTooNew, version)
+		}
+	}
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			return fmt.Errorf("%w: migration %04d_%s is pending", ErrSchemaOutdated, migration.Version, migration.Name)
+		}
+	}
+	return nil
+}
+
+// Up applies every pending migration in order
+func (m *Migrator) Up() ([]Migration, error) {
+	return m.To(m.Latest())
+}
+
+// Down rolls back the latest steps applied migrations. A database that
+// prepare has not adopted yet records none, so it is refused with
+// ErrNotAdopted instead of rolling back to 0.
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	if m.legacy() {
+		return nil, ErrNotAdopted
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]int, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+	target := 0
+	if steps < len(versions) {
+		target = versions[steps]
+	}
+	return m.To(target)
+}
+
+// To migrates up or down until version is the latest applied migration.
+// Version 0 rolls everything back, except on a database prepare has not
+// adopted yet, see Down.
+func (m *Migrator) To(version int) ([]Migration, error) {
+	if version != 0 && m.find(version) == nil {
+		return nil, fmt.Errorf("%w: %d", ErrNoMigration, version)
+	}
+	if version == 0 && m.legacy() {
+		return nil, ErrNotAdopted
+	}
+	adopted, err := m.prepare()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	if adopted {
+		done = append(done, m.migrations[0])
+	}
+
+	// Roll back newest first
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok || migration.Version <= version {
+			continue
+		}
+		if err := m.run(migration, false); err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok || migration.Version > version {
+			continue
+		}
+		if err := m.run(migration, true); err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// run applies or rolls back one migration together with its
+// schema_migrations row. MySQL commits DDL implicitly, so there a failed
+// migration can be left half applied.
+func (m *Migrator) run(migration Migration, up bool) error {
+	script := migration.Down
+	if up {
+		script = migration.Up
+	}
+
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range splitStatements(script) {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		if up {
+			return tx.Create(&SchemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+		}
+		return tx.Delete(&SchemaMigration{}, migration.Version).Error
+	})
+	if err != nil {
+		direction := "down"
+		if up {
+			direction = "up"
+		}
+		return fmt.Errorf("migration %04d_%s %s: %w", migration.Version, migration.Name, direction, err)
+	}
+	return nil
+}
+
+// prepare creates schema_migrations. A database that already has tables
+// but no schema_migrations was created by AutoMigrate before versioned
+// migrations existed: AutoMigrate brings it to the baseline once more and
+// it is recorded at version 1, so later migrations apply on top of it.
+// prepare reports whether it adopted such a database.
+func (m *Migrator) prepare() (bool, error) {
+	if m.db.Migrator().HasTable(&SchemaMigration{}) {
+		return false, nil
+	}
+
+	legacy := m.legacy()
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Migrator().CreateTable(&SchemaMigration{}); err != nil {
+			return err
+		}
+		if !legacy {
+			return nil
+		}
+
+		if err := tx.AutoMigrate(Models()...); err != nil {
+			return err
+		}
+		baseline := m.migrations[0]
+		return tx.Create(&SchemaMigration{Version: baseline.Version, Name: baseline.Name, AppliedAt: time.Now()}).Error
+	})
+	return legacy && err == nil, err
+}
+
+// legacy reports whether the database was created by AutoMigrate and not
+// adopted by prepare yet
+func (m *Migrator) legacy() bool {
+	return !m.db.Migrator().HasTable(&SchemaMigration{}) && m.db.Migrator().HasTable(&models.User{}) && len(m.migrations) > 0
+}
+
+// applied returns the schema_migrations rows by version, none if the table
+// does not exist yet
+func (m *Migrator) applied() (map[int]SchemaMigration, error) {
+	applied := make(map[int]SchemaMigration)
+	if !m.db.Migrator().HasTable(&SchemaMigration{}) {
+		return applied, nil
+	}
+
+	var rows []SchemaMigration
+	if err := m.db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+func (m *Migrator) find(version int) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// splitStatements splits a script into statements, dropping comment lines
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
+
+// CheckSchema returns an error unless db is at exactly the latest migration
+func CheckSchema(db *gorm.DB) error {
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	return migrator.Check()
+}
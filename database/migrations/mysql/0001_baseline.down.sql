@@ -0,0 +1,10 @@
+DROP TABLE `webhook_deliveries`;
+DROP TABLE `outbox_events`;
+DROP TABLE `webhook_subscriptions`;
+DROP TABLE `api_keys`;
+DROP TABLE `refresh_tokens`;
+DROP TABLE `scheduled_transfer_runs`;
+DROP TABLE `scheduled_transfers`;
+DROP TABLE `point_ledgers`;
+DROP TABLE `transfers`;
+DROP TABLE `users`;
@@ -0,0 +1,22 @@
+-- Baseline: the schema as AutoMigrate created it before versioned
+-- migrations. Databases created by AutoMigrate are adopted at this version.
+
+CREATE TABLE `users` (`id` bigint unsigned AUTO_INCREMENT,`name` longtext NOT NULL,`email` varchar(191) NOT NULL,`phone` longtext,`address` longtext,`avatar` longtext,`points` bigint NOT NULL DEFAULT 0,`held_points` bigint NOT NULL DEFAULT 0,`password_hash` longtext,`role` varchar(16) NOT NULL DEFAULT 'member',`created_at` datetime(6) NULL,`updated_at` datetime(6) NULL,`deleted_at` datetime(6) NULL,PRIMARY KEY (`id`),INDEX `idx_users_deleted_at` (`deleted_at`),CONSTRAINT `uni_users_email` UNIQUE (`email`));
+
+CREATE TABLE `transfers` (`id` bigint unsigned AUTO_INCREMENT,`from_user_id` bigint unsigned NOT NULL,`to_user_id` bigint unsigned NOT NULL,`amount` bigint NOT NULL,`status` text NOT NULL,`note` text,`idempotency_key` varchar(128) NOT NULL,`created_at` datetime(6) NULL,`updated_at` datetime(6) NULL,`completed_at` datetime(6) NULL,`hold_expires_at` datetime(6) NULL,`fail_reason` text,`reason` text,`deleted_at` datetime(6) NULL,PRIMARY KEY (`id`),INDEX `idx_transfers_from` (`from_user_id`),INDEX `idx_transfers_to` (`to_user_id`),UNIQUE INDEX `idx_transfers_idempotency_key` (`idempotency_key`),INDEX `idx_transfers_created` (`created_at`),INDEX `idx_transfers_hold_expires` (`hold_expires_at`),INDEX `idx_transfers_deleted_at` (`deleted_at`),CONSTRAINT `chk_transfers_amount` CHECK (amount > 0));
+
+CREATE TABLE `point_ledgers` (`id` bigint unsigned AUTO_INCREMENT,`user_id` bigint unsigned NOT NULL,`change` bigint NOT NULL,`balance_after` bigint NOT NULL,`event_type` text NOT NULL,`transfer_id` bigint unsigned,`reference` text,`metadata` text,`created_at` datetime(6) NULL,`prev_hash` varchar(64),`hash` varchar(64),PRIMARY KEY (`id`),INDEX `idx_ledger_user` (`user_id`),INDEX `idx_ledger_transfer` (`transfer_id`),INDEX `idx_ledger_created` (`created_at`));
+
+CREATE TABLE `scheduled_transfers` (`id` bigint unsigned AUTO_INCREMENT,`from_user_id` bigint unsigned NOT NULL,`to_user_id` bigint unsigned NOT NULL,`amount` bigint NOT NULL,`note` text,`frequency` text NOT NULL,`start_at` datetime(6) NOT NULL,`status` text NOT NULL,`on_insufficient` text NOT NULL,`max_retries` bigint NOT NULL DEFAULT 0,`occurrence` bigint NOT NULL DEFAULT 0,`attempt` bigint NOT NULL DEFAULT 0,`next_run_at` datetime(6) NULL,`last_run_at` datetime(6) NULL,`created_at` datetime(6) NULL,`updated_at` datetime(6) NULL,`deleted_at` datetime(6) NULL,PRIMARY KEY (`id`),INDEX `idx_schedules_from` (`from_user_id`),INDEX `idx_schedules_next_run` (`next_run_at`),INDEX `idx_scheduled_transfers_deleted_at` (`deleted_at`),CONSTRAINT `chk_scheduled_transfers_amount` CHECK (amount > 0));
+
+CREATE TABLE `scheduled_transfer_runs` (`id` bigint unsigned AUTO_INCREMENT,`schedule_id` bigint unsigned NOT NULL,`occurrence` bigint NOT NULL,`attempt` bigint NOT NULL,`scheduled_for` datetime(6) NOT NULL,`status` text NOT NULL,`transfer_id` bigint unsigned,`transfer_key` varchar(128),`error` text,`created_at` datetime(6) NULL,PRIMARY KEY (`id`),INDEX `idx_schedule_runs_schedule` (`schedule_id`));
+
+CREATE TABLE `refresh_tokens` (`id` bigint unsigned AUTO_INCREMENT,`user_id` bigint unsigned NOT NULL,`token_hash` varchar(64) NOT NULL,`expires_at` datetime(6) NOT NULL,`revoked_at` datetime(6) NULL,`created_at` datetime(6) NULL,PRIMARY KEY (`id`),INDEX `idx_refresh_tokens_user` (`user_id`),UNIQUE INDEX `idx_refresh_tokens_token_hash` (`token_hash`));
+
+CREATE TABLE `api_keys` (`id` bigint unsigned AUTO_INCREMENT,`name` longtext NOT NULL,`prefix` varchar(16) NOT NULL,`key_hash` varchar(64) NOT NULL,`owner_id` bigint unsigned NOT NULL,`source_user_id` bigint unsigned,`scopes` longtext NOT NULL,`last_used_at` datetime(6) NULL,`expires_at` datetime(6) NULL,`revoked_at` datetime(6) NULL,`created_at` datetime(6) NULL,PRIMARY KEY (`id`),UNIQUE INDEX `idx_api_keys_key_hash` (`key_hash`),INDEX `idx_api_keys_owner` (`owner_id`));
+
+CREATE TABLE `webhook_subscriptions` (`id` bigint unsigned AUTO_INCREMENT,`owner_id` bigint unsigned NOT NULL,`url` longtext NOT NULL,`secret` longtext NOT NULL,`events` longtext NOT NULL,`all_users` boolean NOT NULL DEFAULT false,`created_at` datetime(6) NULL,`updated_at` datetime(6) NULL,`deleted_at` datetime(6) NULL,PRIMARY KEY (`id`),INDEX `idx_webhook_subscriptions_owner` (`owner_id`),INDEX `idx_webhook_subscriptions_deleted_at` (`deleted_at`));
+
+CREATE TABLE `outbox_events` (`id` bigint unsigned AUTO_INCREMENT,`event_type` text NOT NULL,`transfer_id` bigint unsigned NOT NULL,`from_user_id` bigint unsigned NOT NULL,`to_user_id` bigint unsigned NOT NULL,`payload` text NOT NULL,`dispatched_at` datetime(6) NULL,`created_at` datetime(6) NULL,PRIMARY KEY (`id`),INDEX `idx_outbox_events_dispatched` (`dispatched_at`));
+
+CREATE TABLE `webhook_deliveries` (`id` bigint unsigned AUTO_INCREMENT,`subscription_id` bigint unsigned NOT NULL,`event_id` bigint unsigned NOT NULL,`event_type` text NOT NULL,`status` varchar(16) NOT NULL,`attempts` bigint NOT NULL DEFAULT 0,`next_attempt_at` datetime(6) NULL,`last_status_code` bigint,`last_error` text,`delivered_at` datetime(6) NULL,`created_at` datetime(6) NULL,`updated_at` datetime(6) NULL,PRIMARY KEY (`id`),INDEX `idx_webhook_deliveries_subscription` (`subscription_id`),INDEX `idx_webhook_deliveries_due` (`status`,`next_attempt_at`));
@@ -0,0 +1,7 @@
+ALTER TABLE `point_ledgers` DROP FOREIGN KEY `fk_point_ledgers_transfer`;
+ALTER TABLE `point_ledgers` DROP FOREIGN KEY `fk_point_ledgers_user`;
+ALTER TABLE `point_ledgers` DROP CHECK `chk_point_ledgers_event_type`;
+
+ALTER TABLE `transfers` DROP FOREIGN KEY `fk_transfers_to_user`;
+ALTER TABLE `transfers` DROP FOREIGN KEY `fk_transfers_from_user`;
+ALTER TABLE `transfers` DROP CHECK `chk_transfers_status`;
@@ -0,0 +1,9 @@
+-- Status and event type enums and foreign keys to users and transfers
+
+ALTER TABLE `transfers` ADD CONSTRAINT `chk_transfers_status` CHECK (status IN ('pending','processing','completed','failed','cancelled','reversed'));
+ALTER TABLE `transfers` ADD CONSTRAINT `fk_transfers_from_user` FOREIGN KEY (`from_user_id`) REFERENCES `users`(`id`);
+ALTER TABLE `transfers` ADD CONSTRAINT `fk_transfers_to_user` FOREIGN KEY (`to_user_id`) REFERENCES `users`(`id`);
+
+ALTER TABLE `point_ledgers` ADD CONSTRAINT `chk_point_ledgers_event_type` CHECK (event_type IN ('transfer_out','transfer_in','adjust','earn','redeem'));
+ALTER TABLE `point_ledgers` ADD CONSTRAINT `fk_point_ledgers_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`);
+ALTER TABLE `point_ledgers` ADD CONSTRAINT `fk_point_ledgers_transfer` FOREIGN KEY (`transfer_id`) REFERENCES `transfers`(`id`);
@@ -0,0 +1,6 @@
+-- The ledger chain head: one row naming the newest entry, which every
+-- append moves, so two transactions cannot chain onto the same entry.
+-- AutoMigrate already creates the table when it adopts a legacy database.
+
+CREATE TABLE IF NOT EXISTS `ledger_heads` (`id` bigint unsigned,`entry_id` bigint unsigned NOT NULL,`hash` varchar(64) NOT NULL,PRIMARY KEY (`id`));
+INSERT INTO `ledger_heads` (`id`,`entry_id`,`hash`) SELECT 1,`id`,COALESCE(`hash`,'') FROM `point_ledgers` ORDER BY `id` DESC LIMIT 1;
@@ -0,0 +1,45 @@
+-- Baseline: the schema as AutoMigrate created it before versioned
+-- migrations. Databases created by AutoMigrate are adopted at this version.
+
+CREATE TABLE "users" ("id" bigserial,"name" text NOT NULL,"email" text NOT NULL,"phone" text,"address" text,"avatar" text,"points" bigint NOT NULL DEFAULT 0,"held_points" bigint NOT NULL DEFAULT 0,"password_hash" text,"role" varchar(16) NOT NULL DEFAULT 'member',"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"),CONSTRAINT "uni_users_email" UNIQUE ("email"));
+CREATE INDEX IF NOT EXISTS "idx_users_deleted_at" ON "users" ("deleted_at");
+
+CREATE TABLE "transfers" ("id" bigserial,"from_user_id" bigint NOT NULL,"to_user_id" bigint NOT NULL,"amount" bigint NOT NULL,"status" text NOT NULL,"note" text,"idempotency_key" varchar(128) NOT NULL,"created_at" timestamptz,"updated_at" timestamptz,"completed_at" timestamptz,"hold_expires_at" timestamptz,"fail_reason" text,"reason" text,"deleted_at" timestamptz,PRIMARY KEY ("id"),CONSTRAINT "chk_transfers_amount" CHECK (amount > 0));
+CREATE INDEX IF NOT EXISTS "idx_transfers_deleted_at" ON "transfers" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_transfers_hold_expires" ON "transfers" ("hold_expires_at");
+CREATE INDEX IF NOT EXISTS "idx_transfers_created" ON "transfers" ("created_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_transfers_idempotency_key" ON "transfers" ("idempotency_key");
+CREATE INDEX IF NOT EXISTS "idx_transfers_to" ON "transfers" ("to_user_id");
+CREATE INDEX IF NOT EXISTS "idx_transfers_from" ON "transfers" ("from_user_id");
+
+CREATE TABLE "point_ledgers" ("id" bigserial,"user_id" bigint NOT NULL,"change" bigint NOT NULL,"balance_after" bigint NOT NULL,"event_type" text NOT NULL,"transfer_id" bigint,"reference" text,"metadata" text,"created_at" timestamptz,"prev_hash" varchar(64),"hash" varchar(64),PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_ledger_created" ON "point_ledgers" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_ledger_transfer" ON "point_ledgers" ("transfer_id");
+CREATE INDEX IF NOT EXISTS "idx_ledger_user" ON "point_ledgers" ("user_id");
+
+CREATE TABLE "scheduled_transfers" ("id" bigserial,"from_user_id" bigint NOT NULL,"to_user_id" bigint NOT NULL,"amount" bigint NOT NULL,"note" text,"frequency" text NOT NULL,"start_at" timestamptz NOT NULL,"status" text NOT NULL,"on_insufficient" text NOT NULL,"max_retries" bigint NOT NULL DEFAULT 0,"occurrence" bigint NOT NULL DEFAULT 0,"attempt" bigint NOT NULL DEFAULT 0,"next_run_at" timestamptz,"last_run_at" timestamptz,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"),CONSTRAINT "chk_scheduled_transfers_amount" CHECK (amount > 0));
+CREATE INDEX IF NOT EXISTS "idx_scheduled_transfers_deleted_at" ON "scheduled_transfers" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_schedules_next_run" ON "scheduled_transfers" ("next_run_at");
+CREATE INDEX IF NOT EXISTS "idx_schedules_from" ON "scheduled_transfers" ("from_user_id");
+
+CREATE TABLE "scheduled_transfer_runs" ("id" bigserial,"schedule_id" bigint NOT NULL,"occurrence" bigint NOT NULL,"attempt" bigint NOT NULL,"scheduled_for" timestamptz NOT NULL,"status" text NOT NULL,"transfer_id" bigint,"transfer_key" varchar(128),"error" text,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_schedule_runs_schedule" ON "scheduled_transfer_runs" ("schedule_id");
+
+CREATE TABLE "refresh_tokens" ("id" bigserial,"user_id" bigint NOT NULL,"token_hash" varchar(64) NOT NULL,"expires_at" timestamptz NOT NULL,"revoked_at" timestamptz,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_refresh_tokens_token_hash" ON "refresh_tokens" ("token_hash");
+CREATE INDEX IF NOT EXISTS "idx_refresh_tokens_user" ON "refresh_tokens" ("user_id");
+
+CREATE TABLE "api_keys" ("id" bigserial,"name" text NOT NULL,"prefix" varchar(16) NOT NULL,"key_hash" varchar(64) NOT NULL,"owner_id" bigint NOT NULL,"source_user_id" bigint,"scopes" text NOT NULL,"last_used_at" timestamptz,"expires_at" timestamptz,"revoked_at" timestamptz,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_api_keys_owner" ON "api_keys" ("owner_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_api_keys_key_hash" ON "api_keys" ("key_hash");
+
+CREATE TABLE "webhook_subscriptions" ("id" bigserial,"owner_id" bigint NOT NULL,"url" text NOT NULL,"secret" text NOT NULL,"events" text NOT NULL,"all_users" boolean NOT NULL DEFAULT false,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_webhook_subscriptions_deleted_at" ON "webhook_subscriptions" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_webhook_subscriptions_owner" ON "webhook_subscriptions" ("owner_id");
+
+CREATE TABLE "outbox_events" ("id" bigserial,"event_type" text NOT NULL,"transfer_id" bigint NOT NULL,"from_user_id" bigint NOT NULL,"to_user_id" bigint NOT NULL,"payload" text NOT NULL,"dispatched_at" timestamptz,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_outbox_events_dispatched" ON "outbox_events" ("dispatched_at");
+
+CREATE TABLE "webhook_deliveries" ("id" bigserial,"subscription_id" bigint NOT NULL,"event_id" bigint NOT NULL,"event_type" text NOT NULL,"status" varchar(16) NOT NULL,"attempts" bigint NOT NULL DEFAULT 0,"next_attempt_at" timestamptz,"last_status_code" bigint,"last_error" text,"delivered_at" timestamptz,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_due" ON "webhook_deliveries" ("status","next_attempt_at");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_subscription" ON "webhook_deliveries" ("subscription_id");
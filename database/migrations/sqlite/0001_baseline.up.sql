@@ -0,0 +1,45 @@
+-- Baseline: the schema as AutoMigrate created it before versioned
+-- migrations. Databases created by AutoMigrate are adopted at this version.
+
+CREATE TABLE `users` (`id` integer PRIMARY KEY AUTOINCREMENT,`name` text NOT NULL,`email` text NOT NULL,`phone` text,`address` text,`avatar` text,`points` integer NOT NULL DEFAULT 0,`held_points` integer NOT NULL DEFAULT 0,`password_hash` text,`role` text NOT NULL DEFAULT "member",`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,CONSTRAINT `uni_users_email` UNIQUE (`email`));
+CREATE INDEX `idx_users_deleted_at` ON `users`(`deleted_at`);
+
+CREATE TABLE `transfers` (`id` integer PRIMARY KEY AUTOINCREMENT,`from_user_id` integer NOT NULL,`to_user_id` integer NOT NULL,`amount` integer NOT NULL,`status` text NOT NULL,`note` text,`idempotency_key` text NOT NULL,`created_at` datetime,`updated_at` datetime,`completed_at` datetime,`hold_expires_at` datetime,`fail_reason` text,`reason` text,`deleted_at` datetime,CONSTRAINT `chk_transfers_amount` CHECK (amount > 0));
+CREATE INDEX `idx_transfers_deleted_at` ON `transfers`(`deleted_at`);
+CREATE INDEX `idx_transfers_hold_expires` ON `transfers`(`hold_expires_at`);
+CREATE INDEX `idx_transfers_created` ON `transfers`(`created_at`);
+CREATE UNIQUE INDEX `idx_transfers_idempotency_key` ON `transfers`(`idempotency_key`);
+CREATE INDEX `idx_transfers_to` ON `transfers`(`to_user_id`);
+CREATE INDEX `idx_transfers_from` ON `transfers`(`from_user_id`);
+
+CREATE TABLE `point_ledgers` (`id` integer PRIMARY KEY AUTOINCREMENT,`user_id` integer NOT NULL,`change` integer NOT NULL,`balance_after` integer NOT NULL,`event_type` text NOT NULL,`transfer_id` integer,`reference` text,`metadata` text,`created_at` datetime,`prev_hash` text,`hash` text);
+CREATE INDEX `idx_ledger_created` ON `point_ledgers`(`created_at`);
+CREATE INDEX `idx_ledger_transfer` ON `point_ledgers`(`transfer_id`);
+CREATE INDEX `idx_ledger_user` ON `point_ledgers`(`user_id`);
+
+CREATE TABLE `scheduled_transfers` (`id` integer PRIMARY KEY AUTOINCREMENT,`from_user_id` integer NOT NULL,`to_user_id` integer NOT NULL,`amount` integer NOT NULL,`note` text,`frequency` text NOT NULL,`start_at` datetime NOT NULL,`status` text NOT NULL,`on_insufficient` text NOT NULL,`max_retries` integer NOT NULL DEFAULT 0,`occurrence` integer NOT NULL DEFAULT 0,`attempt` integer NOT NULL DEFAULT 0,`next_run_at` datetime,`last_run_at` datetime,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,CONSTRAINT `chk_scheduled_transfers_amount` CHECK (amount > 0));
+CREATE INDEX `idx_scheduled_transfers_deleted_at` ON `scheduled_transfers`(`deleted_at`);
+CREATE INDEX `idx_schedules_next_run` ON `scheduled_transfers`(`next_run_at`);
+CREATE INDEX `idx_schedules_from` ON `scheduled_transfers`(`from_user_id`);
+
+CREATE TABLE `scheduled_transfer_runs` (`id` integer PRIMARY KEY AUTOINCREMENT,`schedule_id` integer NOT NULL,`occurrence` integer NOT NULL,`attempt` integer NOT NULL,`scheduled_for` datetime NOT NULL,`status` text NOT NULL,`transfer_id` integer,`transfer_key` text,`error` text,`created_at` datetime);
+CREATE INDEX `idx_schedule_runs_schedule` ON `scheduled_transfer_runs`(`schedule_id`);
+
+CREATE TABLE `refresh_tokens` (`id` integer PRIMARY KEY AUTOINCREMENT,`user_id` integer NOT NULL,`token_hash` text NOT NULL,`expires_at` datetime NOT NULL,`revoked_at` datetime,`created_at` datetime);
+CREATE UNIQUE INDEX `idx_refresh_tokens_token_hash` ON `refresh_tokens`(`token_hash`);
+CREATE INDEX `idx_refresh_tokens_user` ON `refresh_tokens`(`user_id`);
+
+CREATE TABLE `api_keys` (`id` integer PRIMARY KEY AUTOINCREMENT,`name` text NOT NULL,`prefix` text NOT NULL,`key_hash` text NOT NULL,`owner_id` integer NOT NULL,`source_user_id` integer,`scopes` text NOT NULL,`last_used_at` datetime,`expires_at` datetime,`revoked_at` datetime,`created_at` datetime);
+CREATE INDEX `idx_api_keys_owner` ON `api_keys`(`owner_id`);
+CREATE UNIQUE INDEX `idx_api_keys_key_hash` ON `api_keys`(`key_hash`);
+
+CREATE TABLE `webhook_subscriptions` (`id` integer PRIMARY KEY AUTOINCREMENT,`owner_id` integer NOT NULL,`url` text NOT NULL,`secret` text NOT NULL,`events` text NOT NULL,`all_users` numeric NOT NULL DEFAULT false,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime);
+CREATE INDEX `idx_webhook_subscriptions_deleted_at` ON `webhook_subscriptions`(`deleted_at`);
+CREATE INDEX `idx_webhook_subscriptions_owner` ON `webhook_subscriptions`(`owner_id`);
+
+CREATE TABLE `outbox_events` (`id` integer PRIMARY KEY AUTOINCREMENT,`event_type` text NOT NULL,`transfer_id` integer NOT NULL,`from_user_id` integer NOT NULL,`to_user_id` integer NOT NULL,`payload` text NOT NULL,`dispatched_at` datetime,`created_at` datetime);
+CREATE INDEX `idx_outbox_events_dispatched` ON `outbox_events`(`dispatched_at`);
+
+CREATE TABLE `webhook_deliveries` (`id` integer PRIMARY KEY AUTOINCREMENT,`subscription_id` integer NOT NULL,`event_id` integer NOT NULL,`event_type` text NOT NULL,`status` text NOT NULL,`attempts` integer NOT NULL DEFAULT 0,`next_attempt_at` datetime,`last_status_code` integer,`last_error` text,`delivered_at` datetime,`created_at` datetime,`updated_at` datetime);
+CREATE INDEX `idx_webhook_deliveries_due` ON `webhook_deliveries`(`status`,`next_attempt_at`);
+CREATE INDEX `idx_webhook_deliveries_subscription` ON `webhook_deliveries`(`subscription_id`);
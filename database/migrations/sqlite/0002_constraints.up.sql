@@ -0,0 +1,22 @@
+-- Status and event type enums and foreign keys to users and transfers.
+-- SQLite cannot add constraints to an existing table, so both tables are
+-- rebuilt with them and their rows copied over.
+
+CREATE TABLE `transfers_new` (`id` integer PRIMARY KEY AUTOINCREMENT,`from_user_id` integer NOT NULL,`to_user_id` integer NOT NULL,`amount` integer NOT NULL,`status` text NOT NULL,`note` text,`idempotency_key` text NOT NULL,`created_at` datetime,`updated_at` datetime,`completed_at` datetime,`hold_expires_at` datetime,`fail_reason` text,`reason` text,`deleted_at` datetime,CONSTRAINT `chk_transfers_amount` CHECK (amount > 0),CONSTRAINT `chk_transfers_status` CHECK (status IN ('pending','processing','completed','failed','cancelled','reversed')),CONSTRAINT `fk_transfers_from_user` FOREIGN KEY (`from_user_id`) REFERENCES `users`(`id`),CONSTRAINT `fk_transfers_to_user` FOREIGN KEY (`to_user_id`) REFERENCES `users`(`id`));
+INSERT INTO `transfers_new` (`id`,`from_user_id`,`to_user_id`,`amount`,`status`,`note`,`idempotency_key`,`created_at`,`updated_at`,`completed_at`,`hold_expires_at`,`fail_reason`,`reason`,`deleted_at`) SELECT `id`,`from_user_id`,`to_user_id`,`amount`,`status`,`note`,`idempotency_key`,`created_at`,`updated_at`,`completed_at`,`hold_expires_at`,`fail_reason`,`reason`,`deleted_at` FROM `transfers`;
+DROP TABLE `transfers`;
+ALTER TABLE `transfers_new` RENAME TO `transfers`;
+CREATE INDEX `idx_transfers_deleted_at` ON `transfers`(`deleted_at`);
+CREATE INDEX `idx_transfers_hold_expires` ON `transfers`(`hold_expires_at`);
+CREATE INDEX `idx_transfers_created` ON `transfers`(`created_at`);
+CREATE UNIQUE INDEX `idx_transfers_idempotency_key` ON `transfers`(`idempotency_key`);
+CREATE INDEX `idx_transfers_to` ON `transfers`(`to_user_id`);
+CREATE INDEX `idx_transfers_from` ON `transfers`(`from_user_id`);
+
+CREATE TABLE `point_ledgers_new` (`id` integer PRIMARY KEY AUTOINCREMENT,`user_id` integer NOT NULL,`change` integer NOT NULL,`balance_after` integer NOT NULL,`event_type` text NOT NULL,`transfer_id` integer,`reference` text,`metadata` text,`created_at` datetime,`prev_hash` text,`hash` text,CONSTRAINT `chk_point_ledgers_event_type` CHECK (event_type IN ('transfer_out','transfer_in','adjust','earn','redeem')),CONSTRAINT `fk_point_ledgers_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`),CONSTRAINT `fk_point_ledgers_transfer` FOREIGN KEY (`transfer_id`) REFERENCES `transfers`(`id`));
+INSERT INTO `point_ledgers_new` (`id`,`user_id`,`change`,`balance_after`,`event_type`,`transfer_id`,`reference`,`metadata`,`created_at`,`prev_hash`,`hash`) SELECT `id`,`user_id`,`change`,`balance_after`,`event_type`,`transfer_id`,`reference`,`metadata`,`created_at`,`prev_hash`,`hash` FROM `point_ledgers`;
+DROP TABLE `point_ledgers`;
+ALTER TABLE `point_ledgers_new` RENAME TO `point_ledgers`;
+CREATE INDEX `idx_ledger_created` ON `point_ledgers`(`created_at`);
+CREATE INDEX `idx_ledger_transfer` ON `point_ledgers`(`transfer_id`);
+CREATE INDEX `idx_ledger_user` ON `point_ledgers`(`user_id`);
@@ -0,0 +1,255 @@
+// Package events is the in-process pub/sub that streams ledger and transfer
+// changes to SSE and WebSocket clients. Services publish only after their
+// transaction has committed, see Collect.
+package events
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"class-go-ai/models"
+)
+
+// MessageType tells what a message carries
+type MessageType string
+
+const (
+	MessageLedger   MessageType = "ledger"   // a new point_ledgers row
+	MessageTransfer MessageType = "transfer" // a transfer changed status
+)
+
+// Message is one committed change
+type Message struct {
+	Type     MessageType         `json:"type"`
+	Ledger   *models.PointLedger `json:"ledger,omitempty"`
+	Transfer *models.Transfer    `json:"transfer,omitempty"`
+}
+
+// UserIDs returns the users a message concerns
+func (m Message) UserIDs() []uint {
+	switch {
+	case m.Ledger != nil:
+		return []uint{m.Ledger.UserID}
+	case m.Transfer != nil:
+		return []uint{m.Transfer.FromUserID, m.Transfer.ToUserID}
+	}
+	return nil
+}
+
+// Publisher receives committed changes
+type Publisher interface {
+	Publish(messages ...Message)
+}
+
+// Publishers hands every message to each of its publishers in turn
+type Publishers []Publisher
+
+// Publish implements Publisher
+func (p Publishers) Publish(messages ...Message) {
+	for _, publisher := range p {
+		publisher.Publish(messages...)
+	}
+}
+
+// Broker fans messages out to subscriptions by user ID. Publishing never
+// blocks: a subscription whose buffer is full misses the message and counts
+// it as dropped.
+type Broker struct {
+	mu            sync.RWMutex
+	subscriptions map[uint]map[*Subscription]struct{}
+	open          atomic.Int64 // subscriptions not yet closed
+	dropped       atomic.Uint64
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewBroker creates a broker without subscriptions
+func NewBroker() *Broker {
+	return &Broker{
+		subscriptions: make(map[uint]map[*Subscription]struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+// Close tells every stream to finish by closing Done. It is safe to call
+// more than once.
+func (b *Broker) Close() {
+	b.closeOnce.Do(func() { close(b.done) })
+}
+
+// Done is closed once the broker is closed; streams stop when it is
+func (b *Broker) Done() <-chan struct{} {
+	return b.done
+}
+
+// Wait waits until every subscription is closed or ctx is done, and returns
+// how many are still open
+func (b *Broker) Wait(ctx context.Context) int {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		open := int(b.open.Load())
+		if open == 0 {
+			return 0
+		}
+		select {
+		case <-ctx.Done():
+			return open
+		case <-ticker.C:
+		}
+	}
+}
+
+// Subscribe returns a subscription to the messages of userIDs with room for
+// buffer undelivered messages. With types, only messages of those types
+// are delivered.
+func (b *Broker) Subscribe(userIDs []uint, buffer int, types ...MessageType) *Subscription {
+	sub := &Subscription{
+		broker:   b,
+		types:    types,
+		messages: make(chan Message, buffer),
+	}
+	b.open.Add(1)
+	sub.Add(userIDs...)
+	return sub
+}
+
+// Publish delivers messages to every matching subscription without blocking
+func (b *Broker) Publish(messages ...Message) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, message := range messages {
+		// A transfer between two watched users reaches a subscription once
+		sent := make(map[*Subscription]bool)
+		for _, userID := range message.UserIDs() {
+			for sub := range b.subscriptions[userID] {
+				if sent[sub] || !sub.wants(message.Type) {
+					continue
+				}
+				sent[sub] = true
+
+				select {
+				case sub.messages <- message:
+				default:
+					sub.dropped.Add(1)
+					b.dropped.Add(1)
+				}
+			}
+		}
+	}
+}
+
+// Dropped returns how many messages were dropped across all subscriptions
+func (b *Broker) Dropped() uint64 {
+	return b.dropped.Load()
+}
+
+// Subscribers returns the number of open subscriptions
+func (b *Broker) Subscribers() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	seen := make(map[*Subscription]bool)
+	for _, subs := range b.subscriptions {
+		for sub := range subs {
+			seen[sub] = true
+		}
+	}
+	return len(seen)
+}
+
+// Subscription receives the messages of some users
+type Subscription struct {
+	broker   *Broker
+	userIDs  []uint
+	types    []MessageType
+	messages chan Message
+	dropped  atomic.Uint64
+	once     sync.Once
+}
+
+// Messages returns the channel messages arrive on. It is never closed;
+// consumers stop reading after Close.
+func (s *Subscription) Messages() <-chan Message {
+	return s.messages
+}
+
+// TakeDropped returns how many messages were dropped since the last call,
+// so the consumer can signal or fill the gap
+func (s *Subscription) TakeDropped() uint64 {
+	return s.dropped.Swap(0)
+}
+
+// Add starts delivering the messages of userIDs. Users already watched
+// are ignored.
+func (s *Subscription) Add(userIDs ...uint) {
+	b := s.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, userID := range userIDs {
+		if b.subscriptions[userID] == nil {
+			b.subscriptions[userID] = make(map[*Subscription]struct{})
+		}
+		if _, ok := b.subscriptions[userID][s]; ok {
+			continue
+		}
+		b.subscriptions[userID][s] = struct{}{}
+		s.userIDs = append(s.userIDs, userID)
+	}
+}
+
+// Remove stops delivering the messages of userIDs. Messages already
+// buffered are still received.
+func (s *Subscription) Remove(userIDs ...uint) {
+	b := s.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, userID := range userIDs {
+		s.remove(userID)
+	}
+}
+
+// UserIDs returns the users the subscription currently watches
+func (s *Subscription) UserIDs() []uint {
+	s.broker.mu.RLock()
+	defer s.broker.mu.RUnlock()
+	return append([]uint(nil), s.userIDs...)
+}
+
+// Close unsubscribes from every user. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.broker.mu.Lock()
+		defer s.broker.mu.Unlock()
+		for len(s.userIDs) > 0 {
+			s.remove(s.userIDs[0])
+		}
+		s.broker.open.Add(-1)
+	})
+}
+
+func (s *Subscription) wants(messageType MessageType) bool {
+	return len(s.types) == 0 || slices.Contains(s.types, messageType)
+}
+
+// remove unsubscribes from one user; the caller holds the broker lock
+func (s *Subscription) remove(userID uint) {
+	b := s.broker
+	if _, ok := b.subscriptions[userID][s]; !ok {
+		return
+	}
+
+	delete(b.subscriptions[userID], s)
+	if len(b.subscriptions[userID]) == 0 {
+		delete(b.subscriptions, userID)
+	}
+	s.userIDs = slices.DeleteFunc(s.userIDs, func(id uint) bool { return id == userID })
+}
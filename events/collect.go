@@ -0,0 +1,59 @@
+package events
+
+import (
+	"context"
+	"sync"
+
+	"class-go-ai/models"
+)
+
+type collectorKey struct{}
+
+// collector buffers the messages of one transaction until it commits
+type collector struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// WithCollector returns a context that buffers messages added with
+// CollectLedger and CollectTransfer, and a function returning them. Run the
+// transaction with the context and publish the result only after commit,
+// so rolled back changes are never seen.
+func WithCollector(ctx context.Context) (context.Context, func() []Message) {
+	c := &collector{}
+	return context.WithValue(ctx, collectorKey{}, c), func() []Message {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.messages
+	}
+}
+
+// CollectLedger buffers a copy of entry if ctx has a collector
+func CollectLedger(ctx context.Context, entry *models.PointLedger) {
+	if c := collectorFrom(ctx); c != nil {
+		snapshot := *entry
+		c.add(Message{Type: MessageLedger, Ledger: &snapshot})
+	}
+}
+
+// CollectTransfer buffers a copy of transfer if ctx has a collector
+func CollectTransfer(ctx context.Context, transfer *models.Transfer) {
+	if c := collectorFrom(ctx); c != nil {
+		snapshot := *transfer
+		c.add(Message{Type: MessageTransfer, Transfer: &snapshot})
+	}
+}
+
+func collectorFrom(ctx context.Context) *collector {
+	if ctx == nil {
+		return nil
+	}
+	c, _ := ctx.Value(collectorKey{}).(*collector)
+	return c
+}
+
+func (c *collector) add(message Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = append(c.messages, message)
+}
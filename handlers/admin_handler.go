@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"errors"
+
+	"class-go-ai/models"
+	"class-go-ai/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AdminHandler serves the /admin routes
+type AdminHandler struct {
+	reconcile *services.ReconcileService
+	ledger    *services.LedgerService
+	auth      *services.AuthService
+}
+
+// NewAdminHandler creates an AdminHandler
+func NewAdminHandler(reconcile *services.ReconcileService, ledger *services.LedgerService, auth *services.AuthService) *AdminHandler {
+	return &AdminHandler{reconcile: reconcile, ledger: ledger, auth: auth}
+}
+
+// GetReconcileReport handles GET /admin/reconcile
+func (h *AdminHandler) GetReconcileReport(c *fiber.Ctx) error {
+	return h.runReconcile(c, false)
+}
+
+// RepairReconcile handles POST /admin/reconcile?repair=true
+func (h *AdminHandler) RepairReconcile(c *fiber.Ctx) error {
+	return h.runReconcile(c, c.QueryBool("repair"))
+}
+
+func (h *AdminHandler) runReconcile(c *fiber.Ctx, repair bool) error {
+	report, err := h.reconcile.Run(repair)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error":   "INTERNAL_ERROR",
+			"message": "Failed to reconcile ledger",
+		})
+	}
+
+	return c.JSON(report)
+}
+
+// VerifyLedger handles GET /admin/ledger/verify
+func (h *AdminHandler) VerifyLedger(c *fiber.Ctx) error {
+	result, err := h.ledger.VerifyChain()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error":   "INTERNAL_ERROR",
+			"message": "Failed to verify ledger",
+		})
+	}
+
+	// Report a broken chain as a conflict so monitoring can alert on the status
+	if !result.Valid {
+		return c.Status(409).JSON(result)
+	}
+
+	return c.JSON(result)
+}
+
+// SetUserRole handles PUT /admin/users/{id}/role
+func (h *AdminHandler) SetUserRole(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return invalidUserID(c)
+	}
+
+	req := new(models.RoleUpdateRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Invalid input format",
+		})
+	}
+
+	user, err := h.auth.SetRole(uint(userID), req.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidRole):
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "VALIDATION_ERROR",
+				"message": err.Error(),
+			})
+		case errors.Is(err, services.ErrUserNotFound):
+			return c.Status(404).JSON(fiber.Map{
+				"error":   "USER_NOT_FOUND",
+				"message": "User not found",
+			})
+		case errors.Is(err, services.ErrLastAdmin):
+			return c.Status(409).JSON(fiber.Map{
+				"error":   "LAST_ADMIN",
+				"message": "Cannot remove the last admin",
+			})
+		default:
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "INTERNAL_ERROR",
+				"message": "Failed to update role",
+			})
+		}
+	}
+
+	return c.JSON(user)
+}
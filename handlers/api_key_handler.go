@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"errors"
+
+	"class-go-ai/middleware"
+	"class-go-ai/models"
+	"class-go-ai/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// APIKeyHandler serves the /api-keys routes
+type APIKeyHandler struct {
+	keys *services.APIKeyService
+}
+
+// NewAPIKeyHandler creates an APIKeyHandler
+func NewAPIKeyHandler(keys *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{keys: keys}
+}
+
+// CreateAPIKey handles POST /api-keys
+func (h *APIKeyHandler) CreateAPIKey(c *fiber.Ctx) error {
+	req := new(models.APIKeyCreateRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Invalid input format",
+		})
+	}
+
+	if req.Name == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "name is required",
+		})
+	}
+
+	// Keys that debit another account (e.g. a treasury) need points:write
+	callerID := middleware.UserID(c)
+	if req.SourceUserID != nil && *req.SourceUserID != callerID &&
+		!middleware.Can(c, middleware.PermPointsWrite) {
+		return forbidden(c, "Cannot create a key for another user's account")
+	}
+
+	key, plaintext, err := h.keys.Create(callerID, req)
+	if err != nil {
+		return apiKeyError(c, err, "Failed to create API key")
+	}
+
+	return c.Status(201).JSON(models.APIKeyResponse{
+		APIKey: key,
+		Key:    plaintext,
+	})
+}
+
+// ListAPIKeys handles GET /api-keys
+func (h *APIKeyHandler) ListAPIKeys(c *fiber.Ctx) error {
+	keys, err := h.keys.List(middleware.UserID(c))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error":   "INTERNAL_ERROR",
+			"message": "Failed to fetch API keys",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": keys,
+	})
+}
+
+// RevokeAPIKey handles DELETE /api-keys/{id}
+func (h *APIKeyHandler) RevokeAPIKey(c *fiber.Ctx) error {
+	id, ok, err := h.authorizeAPIKey(c)
+	if !ok {
+		return err
+	}
+
+	key, err := h.keys.Revoke(id)
+	if err != nil {
+		return apiKeyError(c, err, "Failed to revoke API key")
+	}
+
+	return c.JSON(key)
+}
+
+// RotateAPIKey handles POST /api-keys/{id}/rotate
+func (h *APIKeyHandler) RotateAPIKey(c *fiber.Ctx) error {
+	id, ok, err := h.authorizeAPIKey(c)
+	if !ok {
+		return err
+	}
+
+	key, plaintext, err := h.keys.Rotate(id)
+	if err != nil {
+		return apiKeyError(c, err, "Failed to rotate API key")
+	}
+
+	return c.Status(201).JSON(models.APIKeyResponse{
+		APIKey: key,
+		Key:    plaintext,
+	})
+}
+
+// authorizeAPIKey checks that the authenticated user owns the key named by
+// the id param. When ok is false the error response has already been written.
+func (h *APIKeyHandler) authorizeAPIKey(c *fiber.Ctx) (uint, bool, error) {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return 0, false, c.Status(400).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "API key ID must be a valid positive integer",
+		})
+	}
+
+	key, err := h.keys.Get(uint(id))
+	if err != nil {
+		return 0, false, apiKeyError(c, err, "Failed to fetch API key")
+	}
+
+	// Other users' keys are reported as missing
+	if key.OwnerID != middleware.UserID(c) {
+		return 0, false, apiKeyError(c, services.ErrAPIKeyNotFound, "")
+	}
+
+	return key.ID, true, nil
+}
+
+// apiKeyError writes the standard error response for an API key service error
+func apiKeyError(c *fiber.Ctx, err error, fallback string) error {
+	switch {
+	case errors.Is(err, services.ErrAPIKeyNotFound):
+		return c.Status(404).JSON(fiber.Map{
+			"error":   "NOT_FOUND",
+			"message": "API key not found",
+		})
+	case errors.Is(err, services.ErrInvalidScope):
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": err.Error(),
+		})
+	case errors.Is(err, services.ErrUserNotFound):
+		return c.Status(404).JSON(fiber.Map{
+			"error":   "USER_NOT_FOUND",
+			"message": "Source user not found",
+		})
+	case errors.Is(err, services.ErrInvalidAPIKey):
+		return c.Status(409).JSON(fiber.Map{
+			"error":   "INVALID_STATUS",
+			"message": "API key is revoked",
+		})
+	default:
+		return c.Status(500).JSON(fiber.Map{
+			"error":   "INTERNAL_ERROR",
+			"message": fallback,
+		})
+	}
+}
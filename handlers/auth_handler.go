@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"errors"
+
+	"class-go-ai/models"
+	"class-go-ai/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AuthHandler serves the /auth routes
+type AuthHandler struct {
+	auth *services.AuthService
+}
+
+// NewAuthHandler creates a AuthHandler
+func NewAuthHandler(auth *services.AuthService) *AuthHandler {
+	return &AuthHandler{auth: auth}
+}
+
+// Register handles POST /auth/register
+func (h *AuthHandler) Register(c *fiber.Ctx) error {
+	req := new(models.RegisterRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Invalid input format",
+		})
+	}
+
+	if req.Name == "" || req.Email == "" || req.Password == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "name, email and password are required",
+		})
+	}
+
+	tokens, err := h.auth.Register(req)
+	if err != nil {
+		return authError(c, err, "Failed to register user")
+	}
+
+	return c.Status(201).JSON(tokens)
+}
+
+// Login handles POST /auth/login
+func (h *AuthHandler) Login(c *fiber.Ctx) error {
+	req := new(models.LoginRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Invalid input format",
+		})
+	}
+
+	if req.Email == "" || req.Password == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "email and password are required",
+		})
+	}
+
+	tokens, err := h.auth.Login(req.Email, req.Password)
+	if err != nil {
+		return authError(c, err, "Failed to log in")
+	}
+
+	return c.JSON(tokens)
+}
+
+// Refresh handles POST /auth/refresh
+func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
+	req := new(models.RefreshRequest)
+	if err := c.BodyParser(req); err != nil || req.RefreshToken == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "refreshToken is required",
+		})
+	}
+
+	tokens, err := h.auth.Refresh(req.RefreshToken)
+	if err != nil {
+		return authError(c, err, "Failed to refresh session")
+	}
+
+	return c.JSON(tokens)
+}
+
+// Logout handles POST /auth/logout
+func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	req := new(models.RefreshRequest)
+	if err := c.BodyParser(req); err != nil || req.RefreshToken == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "refreshToken is required",
+		})
+	}
+
+	if err := h.auth.Logout(req.RefreshToken); err != nil {
+		return authError(c, err, "Failed to log out")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Logged out successfully",
+	})
+}
+
+// forbidden writes the standard response for an action on another user's data
+func forbidden(c *fiber.Ctx, message string) error {
+	return c.Status(403).JSON(fiber.Map{
+		"error":   "FORBIDDEN",
+		"message": message,
+	})
+}
+
+// authError writes the standard error response for an auth service error
+func authError(c *fiber.Ctx, err error, fallback string) error {
+	switch {
+	case errors.Is(err, services.ErrEmailTaken):
+		return c.Status(409).JSON(fiber.Map{
+			"error":   "EMAIL_TAKEN",
+			"message": "Email is already registered",
+		})
+	case errors.Is(err, services.ErrWeakPassword):
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "password must be at least 8 characters",
+		})
+	case errors.Is(err, services.ErrPasswordTooLong):
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "password must be at most 72 bytes",
+		})
+	case errors.Is(err, services.ErrInvalidCredentials):
+		return c.Status(401).JSON(fiber.Map{
+			"error":   "INVALID_CREDENTIALS",
+			"message": "Invalid email or password",
+		})
+	case errors.Is(err, services.ErrInvalidToken):
+		return c.Status(401).JSON(fiber.Map{
+			"error":   "UNAUTHORIZED",
+			"message": "Invalid or expired token",
+		})
+	default:
+		return c.Status(500).JSON(fiber.Map{
+			"error":   "INTERNAL_ERROR",
+			"message": fallback,
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"class-go-ai/events"
+	"class-go-ai/models"
+	"class-go-ai/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// streamBuffer is how many messages a slow stream may fall behind
+	// before it misses some and has to catch up from the ledger
+	streamBuffer = 64
+
+	// streamHeartbeat keeps idle connections open through proxies
+	streamHeartbeat = 15 * time.Second
+)
+
+// EventsHandler serves the live ledger event stream
+type EventsHandler struct {
+	broker *events.Broker
+	ledger *services.LedgerService
+}
+
+// NewEventsHandler creates an EventsHandler
+func NewEventsHandler(broker *events.Broker, ledger *services.LedgerService) *EventsHandler {
+	return &EventsHandler{broker: broker, ledger: ledger}
+}
+
+// LedgerEvent is the data of one SSE event
+type LedgerEvent struct {
+	Entry   models.PointLedger `json:"entry"`
+	Balance int                `json:"balance"`
+}
+
+// StreamUserEvents handles GET /users/{id}/events as a Server-Sent Events
+// stream of the user's new ledger entries. Each event's id is the ledger
+// entry id, so a client reconnecting with Last-Event-ID (or ?lastEventId=)
+// first receives every entry it missed.
+func (h *EventsHandler) StreamUserEvents(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return invalidUserID(c)
+	}
+
+	lastEventID := c.Get("Last-Event-ID", c.Query("lastEventId"))
+	resume := lastEventID != ""
+
+	var lastID uint
+	if resume {
+		id, err := strconv.ParseUint(lastEventID, 10, 32)
+		if err != nil {
+			return ledgerValidationError(c, "Last-Event-ID must be a valid ledger entry id")
+		}
+		lastID = uint(id)
+	}
+
+	// Subscribe before reading the ledger so nothing committed in between
+	// is missed; entries seen twice are skipped by id
+	sub := h.broker.Subscribe([]uint{uint(userID)}, streamBuffer, events.MessageLedger)
+
+	if !resume {
+		lastID, err = h.ledger.LatestEntryID(uint(userID))
+		if err != nil {
+			sub.Close()
+			return ledgerError(c, err)
+		}
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	stream := &ledgerStream{
+		ledger: h.ledger,
+		userID: uint(userID),
+		lastID: lastID,
+	}
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer sub.Close()
+		stream.w = w
+
+		// The comment line commits the response headers right away
+		if _, err := fmt.Fprint(w, ": connected\n\n"); err != nil || w.Flush() != nil {
+			return
+		}
+		if resume && stream.catchUp() != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-h.broker.Done():
+				// Shutting down; the client resumes elsewhere with Last-Event-ID
+				return
+			case message := <-sub.Messages():
+				// Missed messages are newer than lastID, so the ledger has them
+				if sub.TakeDropped() > 0 {
+					if stream.catchUp() != nil {
+						return
+					}
+				}
+				if stream.deliver(*message.Ledger) != nil {
+					return
+				}
+			case <-heartbeat.C:
+				if sub.TakeDropped() > 0 {
+					if stream.catchUp() != nil {
+						return
+					}
+				}
+				if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil || w.Flush() != nil {
+					return
+				}
+			}
+		}
+	})
+
+	return nil
+}
+
+// ledgerStream writes one user's ledger entries as SSE events, each at
+// most once and in id order
+type ledgerStream struct {
+	w      *bufio.Writer
+	ledger *services.LedgerService
+	userID uint
+	lastID uint
+	// balance is the balance after lastID, nil until an entry was sent
+	balance *int
+}
+
+// deliver sends a live entry. Entries are committed in id order but may be
+// published out of order, so one that does not continue from the balance
+// last sent may have overtaken an earlier entry; the stream then catches up
+// from the ledger, which has both, instead.
+func (s *ledgerStream) deliver(entry models.PointLedger) error {
+	if entry.ID <= s.lastID {
+		return nil
+	}
+	if s.balance == nil || entry.BalanceAfter-entry.Change != *s.balance {
+		return s.catchUp()
+	}
+	return s.send(entry)
+}
+
+// catchUp sends every entry after lastID from the ledger
+func (s *ledgerStream) catchUp() error {
+	for {
+		page, err := s.ledger.ListEntries(services.LedgerQuery{
+			UserID: s.userID,
+			Cursor: s.lastID,
+			Limit:  200,
+		})
+		if err != nil {
+			return err
+		}
+		for _, entry := range page.Data {
+			if err := s.send(entry); err != nil {
+				return err
+			}
+		}
+		if page.NextCursor == nil {
+			return nil
+		}
+	}
+}
+
+// send writes entry unless it was already sent, and flushes so the client
+// sees it immediately. A flush error means the client went away.
+func (s *ledgerStream) send(entry models.PointLedger) error {
+	if entry.ID <= s.lastID {
+		return nil
+	}
+
+	data, err := json.Marshal(LedgerEvent{Entry: entry, Balance: entry.BalanceAfter})
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(s.w, "id: %d\nevent: %s\ndata: %s\n\n", entry.ID, entry.EventType, data); err != nil {
+		return err
+	}
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+
+	s.lastID = entry.ID
+	s.balance = &entry.BalanceAfter
+	return nil
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"class-go-ai/buildinfo"
+	"class-go-ai/database"
+	"class-go-ai/models"
+	"class-go-ai/workers"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// readyTimeout bounds the database checks of one readiness probe
+const readyTimeout = 2 * time.Second
+
+// HealthHandler serves the probes and build details orchestrators use
+type HealthHandler struct {
+	db       *gorm.DB
+	workers  *workers.Group
+	draining *atomic.Bool
+}
+
+// NewHealthHandler creates a HealthHandler. Readiness fails once draining
+// is set.
+func NewHealthHandler(db *gorm.DB, workers *workers.Group, draining *atomic.Bool) *HealthHandler {
+	return &HealthHandler{db: db, workers: workers, draining: draining}
+}
+
+// Healthz handles GET /healthz: the process is up and serving requests
+func (h *HealthHandler) Healthz(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+// Readyz handles GET /readyz. It answers 200 while this instance should get
+// traffic: the database responds, its schema is the version this build
+// expects, every background worker has a recent heartbeat and no shutdown
+// has begun. Otherwise it answers 503 naming the failed checks; the causes
+// are logged rather than returned.
+func (h *HealthHandler) Readyz(c *fiber.Ctx) error {
+	response := models.ReadinessResponse{Status: models.ReadinessReady, Checks: map[string]string{}}
+	check := func(name, failure string) {
+		if failure == "" {
+			response.Checks[name] = "ok"
+			return
+		}
+		response.Checks[name] = failure
+		response.Status = models.ReadinessUnavailable
+	}
+
+	ctx, cancel := context.WithTimeout(c.UserContext(), readyTimeout)
+	defer cancel()
+
+	if h.draining.Load() {
+		check("shutdown", "draining")
+	} else {
+		check("shutdown", "")
+	}
+
+	sqlDB, err := h.db.DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	if err != nil {
+		log.Println("Readiness: database ping failed:", err)
+		check("database", "unreachable")
+		check("schema", "unknown")
+	} else {
+		check("database", "")
+		check("schema", schemaFailure(database.CheckSchema(h.db.WithContext(ctx))))
+	}
+
+	if stale := h.workers.Stale(time.Now()); len(stale) > 0 {
+		check("workers", "stale: "+strings.Join(stale, ", "))
+	} else {
+		check("workers", "")
+	}
+
+	if response.Status != models.ReadinessReady {
+		return c.Status(503).JSON(response)
+	}
+	return c.JSON(response)
+}
+
+// schemaFailure describes a schema check error for the readiness response
+func schemaFailure(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, database.ErrSchemaOutdated):
+		return "migrations pending"
+	case errors.Is(err, database.ErrSchemaTooNew):
+		return "newer than this build"
+	default:
+		log.Println("Readiness: schema check failed:", err)
+		return "unreadable"
+	}
+}
+
+// Version handles GET /version with the build's commit and build time and
+// the database schema version
+func (h *HealthHandler) Version(c *fiber.Ctx) error {
+	response := models.VersionResponse{Info: buildinfo.Get()}
+
+	migrator, err := database.NewMigrator(h.db.WithContext(c.UserContext()))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error":   "INTERNAL_ERROR",
+			"message": "Failed to load migrations",
+		})
+	}
+	response.LatestSchema = migrator.Latest()
+	if version, err := migrator.Version(); err == nil {
+		response.SchemaVersion = &version
+	} else {
+		log.Println("Version: failed to read the schema version:", err)
+	}
+
+	return c.JSON(response)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"class-go-ai/models"
+	"class-go-ai/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// LedgerHandler serves the ledger routes
+type LedgerHandler struct {
+	ledger *services.LedgerService
+}
+
+// NewLedgerHandler creates a LedgerHandler
+func NewLedgerHandler(ledger *services.LedgerService) *LedgerHandler {
+	return &LedgerHandler{ledger: ledger}
+}
+
+// GetUserLedger handles GET /users/{id}/ledger?eventType=&from=&to=&transferId=&cursor=&limit=&asOf=
+func (h *LedgerHandler) GetUserLedger(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return invalidUserID(c)
+	}
+
+	query := services.LedgerQuery{UserID: uint(userID)}
+
+	if eventType := models.EventType(c.Query("eventType")); eventType != "" {
+		switch eventType {
+		case models.EventTypeTransferOut, models.EventTypeTransferIn,
+			models.EventTypeAdjust, models.EventTypeEarn, models.EventTypeRedeem:
+			query.EventType = eventType
+		default:
+			return ledgerValidationError(c, "eventType must be one of transfer_out, transfer_in, adjust, earn, redeem")
+		}
+	}
+
+	if query.From, err = parseTimeQuery(c, "from"); err != nil {
+		return ledgerValidationError(c, "from must be an RFC 3339 timestamp")
+	}
+	if query.To, err = parseTimeQuery(c, "to"); err != nil {
+		return ledgerValidationError(c, "to must be an RFC 3339 timestamp")
+	}
+
+	if value := c.Query("transferId"); value != "" {
+		transferID, err := strconv.ParseUint(value, 10, 32)
+		if err != nil || transferID == 0 {
+			return ledgerValidationError(c, "transferId must be a valid positive integer")
+		}
+		id := uint(transferID)
+		query.TransferID = &id
+	}
+
+	if value := c.Query("cursor"); value != "" {
+		cursor, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return ledgerValidationError(c, "cursor must be a valid ledger entry id")
+		}
+		query.Cursor = uint(cursor)
+	}
+
+	query.Limit, _ = strconv.Atoi(c.Query("limit", "50"))
+
+	asOf, err := parseTimeQuery(c, "asOf")
+	if err != nil {
+		return ledgerValidationError(c, "asOf must be an RFC 3339 timestamp")
+	}
+
+	result, err := h.ledger.ListEntries(query)
+	if err != nil {
+		return ledgerError(c, err)
+	}
+
+	if asOf != nil {
+		balance, err := h.ledger.BalanceAsOf(uint(userID), *asOf)
+		if err != nil {
+			return ledgerError(c, err)
+		}
+		result.AsOf = asOf
+		result.BalanceAsOf = balance
+	}
+
+	return c.JSON(result)
+}
+
+// parseTimeQuery parses an optional RFC 3339 query parameter. Times are
+// converted to the server's zone, which is how ledger timestamps are stored.
+func parseTimeQuery(c *fiber.Ctx, key string) (*time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+
+	t = t.Local()
+	return &t, nil
+}
+
+func ledgerValidationError(c *fiber.Ctx, message string) error {
+	return c.Status(400).JSON(fiber.Map{
+		"error":   "VALIDATION_ERROR",
+		"message": message,
+	})
+}
+
+func ledgerError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, services.ErrUserNotFound) {
+		return c.Status(404).JSON(fiber.Map{
+			"error":   "USER_NOT_FOUND",
+			"message": "User not found",
+		})
+	}
+	return c.Status(500).JSON(fiber.Map{
+		"error":   "INTERNAL_ERROR",
+		"message": "Failed to fetch ledger",
+	})
+}
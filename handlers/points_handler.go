@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"errors"
+
+	"class-go-ai/models"
+	"class-go-ai/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// PointsHandler serves the earn, redeem and adjust routes
+type PointsHandler struct {
+	points *services.PointsService
+}
+
+// NewPointsHandler creates a PointsHandler
+func NewPointsHandler(points *services.PointsService) *PointsHandler {
+	return &PointsHandler{points: points}
+}
+
+// EarnPoints handles POST /users/{id}/points/earn
+func (h *PointsHandler) EarnPoints(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return invalidUserID(c)
+	}
+
+	req := new(models.PointsEarnRequest)
+	if err := c.BodyParser(req); err != nil {
+		return invalidPointsInput(c)
+	}
+
+	entry, err := h.points.Earn(uint(userID), req.Amount, req.Source)
+	if err != nil {
+		return pointsError(c, err, "Failed to credit points")
+	}
+
+	return c.Status(201).JSON(models.PointsResponse{
+		Entry:   entry,
+		Balance: entry.BalanceAfter,
+	})
+}
+
+// RedeemPoints handles POST /users/{id}/points/redeem
+func (h *PointsHandler) RedeemPoints(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return invalidUserID(c)
+	}
+
+	req := new(models.PointsRedeemRequest)
+	if err := c.BodyParser(req); err != nil {
+		return invalidPointsInput(c)
+	}
+
+	entry, err := h.points.Redeem(uint(userID), req.Amount, req.Reference)
+	if err != nil {
+		return pointsError(c, err, "Failed to redeem points")
+	}
+
+	return c.Status(201).JSON(models.PointsResponse{
+		Entry:   entry,
+		Balance: entry.BalanceAfter,
+	})
+}
+
+// AdjustPoints handles POST /users/{id}/points/adjust
+func (h *PointsHandler) AdjustPoints(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return invalidUserID(c)
+	}
+
+	req := new(models.PointsAdjustRequest)
+	if err := c.BodyParser(req); err != nil {
+		return invalidPointsInput(c)
+	}
+
+	entry, err := h.points.Adjust(uint(userID), req.Change, req.Reason)
+	if err != nil {
+		return pointsError(c, err, "Failed to adjust points")
+	}
+
+	return c.Status(201).JSON(models.PointsResponse{
+		Entry:   entry,
+		Balance: entry.BalanceAfter,
+	})
+}
+
+func invalidUserID(c *fiber.Ctx) error {
+	return c.Status(400).JSON(fiber.Map{
+		"error":   "VALIDATION_ERROR",
+		"message": "User ID must be a valid positive integer",
+	})
+}
+
+func invalidPointsInput(c *fiber.Ctx) error {
+	return c.Status(400).JSON(fiber.Map{
+		"error":   "VALIDATION_ERROR",
+		"message": "Invalid input format",
+	})
+}
+
+// pointsError writes the standard error response for a points service error
+func pointsError(c *fiber.Ctx, err error, fallback string) error {
+	switch {
+	case errors.Is(err, services.ErrReasonRequired),
+		errors.Is(err, services.ErrSourceRequired),
+		errors.Is(err, services.ErrInvalidChange):
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": err.Error(),
+		})
+	case errors.Is(err, services.ErrUserNotFound):
+		return c.Status(404).JSON(fiber.Map{
+			"error":   "USER_NOT_FOUND",
+			"message": "User not found",
+		})
+	case errors.Is(err, services.ErrInsufficientPoints):
+		return c.Status(409).JSON(fiber.Map{
+			"error":   "INSUFFICIENT_POINTS",
+			"message": "User does not have enough available points",
+		})
+	default:
+		return transferError(c, err, fallback)
+	}
+}
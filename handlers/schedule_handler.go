@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"class-go-ai/middleware"
+	"class-go-ai/models"
+	"class-go-ai/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ScheduleHandler serves the /scheduled-transfers routes
+type ScheduleHandler struct {
+	schedules *services.ScheduleService
+}
+
+// NewScheduleHandler creates a ScheduleHandler
+func NewScheduleHandler(schedules *services.ScheduleService) *ScheduleHandler {
+	return &ScheduleHandler{schedules: schedules}
+}
+
+// CreateScheduledTransfer handles POST /scheduled-transfers
+func (h *ScheduleHandler) CreateScheduledTransfer(c *fiber.Ctx) error {
+	req := new(models.ScheduledTransferCreateRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Invalid input format",
+		})
+	}
+
+	// Scheduled transfers always debit the authenticated user
+	callerID := middleware.UserID(c)
+	if req.FromUserID != 0 && req.FromUserID != callerID {
+		return forbidden(c, "fromUserId must be the authenticated user")
+	}
+	req.FromUserID = callerID
+
+	// Validate required fields
+	if req.ToUserID == 0 || req.Amount <= 0 || req.Frequency == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "toUserId, amount and frequency are required",
+		})
+	}
+
+	schedule, err := h.schedules.CreateSchedule(req)
+	if err != nil {
+		return scheduleError(c, err, "Failed to create scheduled transfer")
+	}
+
+	return c.Status(201).JSON(schedule)
+}
+
+// ListScheduledTransfers handles GET /scheduled-transfers?userId=X
+func (h *ScheduleHandler) ListScheduledTransfers(c *fiber.Ctx) error {
+	// userId is optional and defaults to the authenticated user
+	userID := uint64(middleware.UserID(c))
+	if value := c.Query("userId"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 32)
+		if err != nil || parsed == 0 {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "VALIDATION_ERROR",
+				"message": "userId query parameter must be a valid positive integer",
+			})
+		}
+		if parsed != userID {
+			return forbidden(c, "Cannot list another user's scheduled transfers")
+		}
+	}
+
+	schedules, err := h.schedules.ListSchedules(uint(userID))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error":   "INTERNAL_ERROR",
+			"message": "Failed to fetch scheduled transfers",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": schedules,
+	})
+}
+
+// ListScheduledTransferRuns handles GET /scheduled-transfers/{id}/runs
+func (h *ScheduleHandler) ListScheduledTransferRuns(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return invalidScheduleID(c)
+	}
+	if ok, err := h.authorizeSchedule(c, uint(id)); !ok {
+		return err
+	}
+
+	runs, err := h.schedules.ListRuns(uint(id))
+	if err != nil {
+		return scheduleError(c, err, "Failed to fetch scheduled transfer runs")
+	}
+
+	return c.JSON(fiber.Map{
+		"data": runs,
+	})
+}
+
+// PauseScheduledTransfer handles POST /scheduled-transfers/{id}/pause
+func (h *ScheduleHandler) PauseScheduledTransfer(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return invalidScheduleID(c)
+	}
+	if ok, err := h.authorizeSchedule(c, uint(id)); !ok {
+		return err
+	}
+
+	schedule, err := h.schedules.PauseSchedule(uint(id))
+	if err != nil {
+		return scheduleError(c, err, "Failed to pause scheduled transfer")
+	}
+
+	return c.JSON(schedule)
+}
+
+// ResumeScheduledTransfer handles POST /scheduled-transfers/{id}/resume
+func (h *ScheduleHandler) ResumeScheduledTransfer(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return invalidScheduleID(c)
+	}
+	if ok, err := h.authorizeSchedule(c, uint(id)); !ok {
+		return err
+	}
+
+	schedule, err := h.schedules.ResumeSchedule(uint(id))
+	if err != nil {
+		return scheduleError(c, err, "Failed to resume scheduled transfer")
+	}
+
+	return c.JSON(schedule)
+}
+
+// DeleteScheduledTransfer handles DELETE /scheduled-transfers/{id}
+func (h *ScheduleHandler) DeleteScheduledTransfer(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return invalidScheduleID(c)
+	}
+	if ok, err := h.authorizeSchedule(c, uint(id)); !ok {
+		return err
+	}
+
+	if err := h.schedules.DeleteSchedule(uint(id)); err != nil {
+		return scheduleError(c, err, "Failed to delete scheduled transfer")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Scheduled transfer deleted successfully",
+	})
+}
+
+// authorizeSchedule checks that the authenticated user owns the schedule.
+// When it returns false the error response has already been written.
+func (h *ScheduleHandler) authorizeSchedule(c *fiber.Ctx, id uint) (bool, error) {
+	schedule, err := h.schedules.GetSchedule(id)
+	if err != nil {
+		return false, scheduleError(c, err, "Failed to fetch scheduled transfer")
+	}
+
+	// Other users' schedules are reported as missing
+	if schedule.FromUserID != middleware.UserID(c) {
+		return false, scheduleError(c, services.ErrScheduleNotFound, "")
+	}
+
+	return true, nil
+}
+
+func invalidScheduleID(c *fiber.Ctx) error {
+	return c.Status(400).JSON(fiber.Map{
+		"error":   "VALIDATION_ERROR",
+		"message": "Scheduled transfer ID must be a valid positive integer",
+	})
+}
+
+// scheduleError writes the standard error response for a schedule service error
+func scheduleError(c *fiber.Ctx, err error, fallback string) error {
+	switch {
+	case errors.Is(err, services.ErrScheduleNotFound):
+		return c.Status(404).JSON(fiber.Map{
+			"error":   "NOT_FOUND",
+			"message": "Scheduled transfer not found",
+		})
+	case errors.Is(err, services.ErrScheduleStatus):
+		return c.Status(409).JSON(fiber.Map{
+			"error":   "INVALID_STATUS",
+			"message": "Scheduled transfer status does not allow this operation",
+		})
+	case errors.Is(err, services.ErrInvalidFrequency),
+		errors.Is(err, services.ErrInvalidPolicy),
+		errors.Is(err, services.ErrInvalidRetries):
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": err.Error(),
+		})
+	default:
+		return transferError(c, err, fallback)
+	}
+}
@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"class-go-ai/events"
+	"class-go-ai/middleware"
+	"class-go-ai/models"
+	"class-go-ai/services"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Socket message types. Clients send auth, subscribe and unsubscribe; the
+// server sends the rest.
+const (
+	SocketAuth        = "auth"        // {"type":"auth","token":"<access token>"}
+	SocketSubscribe   = "subscribe"   // {"type":"subscribe","userIds":[1,2]}
+	SocketUnsubscribe = "unsubscribe" // {"type":"unsubscribe","userIds":[2]}
+	SocketReady       = "ready"       // authenticated as userId
+	SocketSubscribed  = "subscribed"  // userIds now watched
+	SocketTransfer    = "transfer"    // a transfer changed status
+	SocketGap         = "gap"         // missed transfer messages were dropped
+	SocketError       = "error"       // a request was rejected
+)
+
+const (
+	// socketAuthWait is how long an unauthenticated connection may stay open
+	socketAuthWait = 10 * time.Second
+
+	// socketWriteWait bounds a single write to a slow client
+	socketWriteWait = 10 * time.Second
+
+	// socketPingPeriod is how often the server pings; a client that does
+	// not answer within socketPongWait is disconnected
+	socketPingPeriod = 30 * time.Second
+	socketPongWait   = 60 * time.Second
+
+	// socketTokenExpired closes a socket whose access token expired or no
+	// longer authenticates its user; the client reconnects with a new one
+	socketTokenExpired = 4001
+
+	socketUserKey  = "socketUser"
+	socketTokenKey = "socketToken"
+)
+
+// SocketMessage is a message on the transfer WebSocket, in either direction
+type SocketMessage struct {
+	Type     string           `json:"type"`
+	Token    string           `json:"token,omitempty"`
+	UserID   uint             `json:"userId,omitempty"`
+	UserIDs  []uint           `json:"userIds,omitempty"`
+	Transfer *models.Transfer `json:"transfer,omitempty"`
+	Missed   uint64           `json:"missed,omitempty"`
+	Error    string           `json:"error,omitempty"`
+	Message  string           `json:"message,omitempty"`
+}
+
+// SocketHandler serves the transfer WebSocket
+type SocketHandler struct {
+	auth   *services.AuthService
+	broker *events.Broker
+}
+
+// NewSocketHandler creates a SocketHandler
+func NewSocketHandler(auth *services.AuthService, broker *events.Broker) *SocketHandler {
+	return &SocketHandler{auth: auth, broker: broker}
+}
+
+// UpgradeTransferSocket handles the upgrade request for GET /ws/transfers.
+// Clients that can set headers may authenticate with a bearer token here;
+// browsers send an auth message after connecting instead.
+func (h *SocketHandler) UpgradeTransferSocket(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return c.Status(426).JSON(fiber.Map{
+			"error":   "UPGRADE_REQUIRED",
+			"message": "This endpoint only accepts WebSocket connections",
+		})
+	}
+
+	if header := c.Get(fiber.HeaderAuthorization); header != "" {
+		token, _ := strings.CutPrefix(header, "Bearer ")
+		user, err := h.auth.Authenticate(token)
+		if err != nil {
+			return authError(c, err, "Failed to authenticate")
+		}
+		c.Locals(socketUserKey, user)
+		c.Locals(socketTokenKey, token)
+	}
+
+	return c.Next()
+}
+
+// TransferSocket serves an upgraded transfer WebSocket. Once authenticated,
+// the client subscribes to users it may see (itself, or anyone with
+// transfers:read) and receives a transfer message each time a transfer
+// involving them changes status. The user is reloaded before each
+// subscription and each message about other users, so losing transfers:read
+// drops those subscriptions; the socket closes with 4001 when its token
+// expires. Publishing never waits for the socket: a client that falls behind
+// misses messages and is sent a gap message with the number missed, after
+// which it should re-fetch GET /transfers.
+func (h *SocketHandler) TransferSocket(conn *websocket.Conn) {
+	socket := &transferSocket{
+		conn: conn,
+		auth: h.auth,
+		sub:  h.broker.Subscribe(nil, streamBuffer, events.MessageTransfer),
+	}
+	defer socket.sub.Close()
+	defer socket.stopExpiry()
+
+	// Only this goroutine writes; the reader hands messages over
+	incoming := make(chan []byte)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			incoming <- data
+		}
+	}()
+	defer func() {
+		conn.Close()
+		// Unblock the reader so it can see the closed connection
+		for {
+			select {
+			case <-incoming:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	})
+
+	authTimeout := time.NewTimer(socketAuthWait)
+	defer authTimeout.Stop()
+	if user, ok := conn.Locals(socketUserKey).(*models.User); ok {
+		if socket.authenticated(user, conn.Locals(socketTokenKey).(string)) != nil {
+			return
+		}
+		authTimeout.Stop()
+	}
+
+	ping := time.NewTicker(socketPingPeriod)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-h.broker.Done():
+			// Shutting down; tell the client to reconnect
+			socket.close(websocket.CloseGoingAway, "server shutting down")
+			return
+		case <-socket.expired:
+			socket.close(socketTokenExpired, "token expired")
+			return
+		case data := <-incoming:
+			if !socket.handle(data) {
+				return
+			}
+		case message := <-socket.sub.Messages():
+			if socket.forward(message) != nil {
+				return
+			}
+		case <-ping.C:
+			if conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteWait)) != nil {
+				return
+			}
+		case <-authTimeout.C:
+			if socket.user == nil {
+				socket.fail("UNAUTHORIZED", "Authentication timed out")
+				return
+			}
+		}
+	}
+}
+
+// transferSocket is the state of one connection
+type transferSocket struct {
+	conn  *websocket.Conn
+	auth  *services.AuthService
+	sub   *events.Subscription
+	user  *models.User
+	token string
+
+	// expired fires when the token expires; nil until authenticated
+	expiry  *time.Timer
+	expired <-chan time.Time
+}
+
+// handle processes a client message and reports whether to stay connected
+func (s *transferSocket) handle(data []byte) bool {
+	var request SocketMessage
+	if err := json.Unmarshal(data, &request); err != nil {
+		return s.fail("VALIDATION_ERROR", "Messages must be JSON objects") == nil
+	}
+
+	if s.user == nil {
+		if request.Type != SocketAuth {
+			s.fail("UNAUTHORIZED", "Send an auth message first")
+			return false
+		}
+		user, err := s.auth.Authenticate(request.Token)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidToken) {
+				s.fail("UNAUTHORIZED", "Invalid or expired token")
+			} else {
+				s.fail("INTERNAL_ERROR", "Failed to authenticate")
+			}
+			return false
+		}
+		return s.authenticated(user, request.Token) == nil
+	}
+
+	switch request.Type {
+	case SocketAuth:
+		return s.fail("VALIDATION_ERROR", "Already authenticated") == nil
+	case SocketSubscribe:
+		if len(request.UserIDs) == 0 {
+			return s.fail("VALIDATION_ERROR", "userIds must not be empty") == nil
+		}
+		if s.refresh() != nil {
+			return false
+		}
+		// All or nothing, so a rejected request changes nothing
+		for _, userID := range request.UserIDs {
+			if userID != s.user.ID && !middleware.RoleCan(s.user.Role, middleware.PermTransfersRead) {
+				return s.fail("FORBIDDEN", "You may only subscribe to your own transfers") == nil
+			}
+		}
+		s.sub.Add(request.UserIDs...)
+	case SocketUnsubscribe:
+		s.sub.Remove(request.UserIDs...)
+	default:
+		return s.fail("VALIDATION_ERROR", "Unknown message type") == nil
+	}
+
+	return s.write(SocketMessage{Type: SocketSubscribed, UserIDs: s.sub.UserIDs()}) == nil
+}
+
+func (s *transferSocket) authenticated(user *models.User, token string) error {
+	expiresAt, err := s.auth.AccessTokenExpiry(token)
+	if err != nil {
+		s.fail("UNAUTHORIZED", "Invalid or expired token")
+		return err
+	}
+
+	s.user, s.token = user, token
+	s.expiry = time.NewTimer(time.Until(expiresAt))
+	s.expired = s.expiry.C
+	return s.write(SocketMessage{Type: SocketReady, UserID: user.ID})
+}
+
+func (s *transferSocket) stopExpiry() {
+	if s.expiry != nil {
+		s.expiry.Stop()
+	}
+}
+
+// refresh reloads the user, so a deleted user or changed role takes effect
+// on an open socket. Without transfers:read, subscriptions to other users
+// are dropped and the client is sent what remains. A token that no longer
+// authenticates closes the socket.
+func (s *transferSocket) refresh() error {
+	user, err := s.auth.Authenticate(s.token)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidToken) {
+			s.close(socketTokenExpired, "token expired")
+		} else {
+			s.fail("INTERNAL_ERROR", "Failed to authenticate")
+		}
+		return err
+	}
+	s.user = user
+
+	if middleware.RoleCan(user.Role, middleware.PermTransfersRead) {
+		return nil
+	}
+	var others []uint
+	for _, userID := range s.sub.UserIDs() {
+		if userID != user.ID {
+			others = append(others, userID)
+		}
+	}
+	if len(others) == 0 {
+		return nil
+	}
+	s.sub.Remove(others...)
+	return s.write(SocketMessage{Type: SocketSubscribed, UserIDs: s.sub.UserIDs()})
+}
+
+// forward sends a transfer message, then reports messages dropped since the
+// last one. Drops only happen while the buffer is full, so a later message
+// always follows and the gap is never left unreported. A transfer the user
+// is not part of is only sent while the user may still read it.
+func (s *transferSocket) forward(message events.Message) error {
+	if transfer := message.Transfer; transfer.FromUserID != s.user.ID && transfer.ToUserID != s.user.ID {
+		if err := s.refresh(); err != nil {
+			return err
+		}
+		if !middleware.RoleCan(s.user.Role, middleware.PermTransfersRead) {
+			return nil
+		}
+	}
+
+	if err := s.write(SocketMessage{Type: SocketTransfer, Transfer: message.Transfer}); err != nil {
+		return err
+	}
+
+	if missed := s.sub.TakeDropped(); missed > 0 {
+		return s.write(SocketMessage{Type: SocketGap, Missed: missed})
+	}
+	return nil
+}
+
+func (s *transferSocket) fail(code, message string) error {
+	return s.write(SocketMessage{Type: SocketError, Error: code, Message: message})
+}
+
+// close sends a close frame; the caller then drops the connection
+func (s *transferSocket) close(code int, text string) {
+	closing := websocket.FormatCloseMessage(code, text)
+	s.conn.WriteControl(websocket.CloseMessage, closing, time.Now().Add(socketWriteWait))
+}
+
+func (s *transferSocket) write(message SocketMessage) error {
+	s.conn.SetWriteDeadline(time.Now().Add(socketWriteWait))
+	return s.conn.WriteJSON(message)
+}
@@ -2,29 +2,40 @@ package handlers
 
 import (
 	"errors"
+	"fmt"
 	"strconv"
+	"strings"
 
-	"class-go-ai/database"
+	"class-go-ai/middleware"
 	"class-go-ai/models"
 	"class-go-ai/services"
 
 	"github.com/gofiber/fiber/v2"
-	"gorm.io/gorm"
 )
 
-var transferService *services.TransferService
+// MaxBatchSize is the largest number of items accepted by POST /transfers/batch
+const MaxBatchSize = 500
 
-// InitTransferService initializes the transfer service
-func InitTransferService() {
-	transferService = services.NewTransferService(database.DB)
+// batchKeyPrefix starts the idempotency keys derived for batch items, so
+// they never match a key a client sent for a single transfer
+const batchKeyPrefix = "batch:"
+
+// maxBatchKeyLength leaves room in the 128 character key column for the
+// prefix and the ":<index>" suffix
+const maxBatchKeyLength = 128 - len(batchKeyPrefix) - len(":499")
+
+// TransferHandler serves the /transfers routes
+type TransferHandler struct {
+	transfers *services.TransferService
 }
 
-// CreateTransfer handles POST /transfers
-func CreateTransfer(c *fiber.Ctx) error {
-	if transferService == nil {
-		InitTransferService()
-	}
+// NewTransferHandler creates a TransferHandler
+func NewTransferHandler(transfers *services.TransferService) *TransferHandler {
+	return &TransferHandler{transfers: transfers}
+}
 
+// CreateTransfer handles POST /transfers
+func (h *TransferHandler) CreateTransfer(c *fiber.Ctx) error {
 	req := new(models.TransferCreateRequest)
 	if err := c.BodyParser(req); err != nil {
 		return c.Status(400).JSON(fiber.Map{
@@ -33,39 +44,40 @@ func CreateTransfer(c *fiber.Ctx) error {
 		})
 	}
 
+	// Transfers always debit the authenticated user
+	callerID := middleware.UserID(c)
+	if req.FromUserID != 0 && req.FromUserID != callerID {
+		return forbidden(c, "fromUserId must be the authenticated user")
+	}
+	req.FromUserID = callerID
+
 	// Validate required fields
-	if req.FromUserID == 0 || req.ToUserID == 0 || req.Amount <= 0 {
+	if req.ToUserID == 0 || req.Amount <= 0 {
 		return c.Status(400).JSON(fiber.Map{
 			"error":   "VALIDATION_ERROR",
-			"message": "fromUserId, toUserId, and amount are required and must be greater than 0",
+			"message": "toUserId and amount are required and must be greater than 0",
 		})
 	}
 
-	transfer, err := transferService.CreateTransfer(req)
+	// Optional client-supplied Idempotency-Key for safe retries
+	req.IdempotencyKey = c.Get("Idempotency-Key")
+	if len(req.IdempotencyKey) > 128 {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Idempotency-Key must be at most 128 characters",
+		})
+	}
+	if strings.HasPrefix(req.IdempotencyKey, batchKeyPrefix) {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Idempotency-Key must not start with " + batchKeyPrefix,
+		})
+	}
+
+	transfer, err := h.transfers.CreateTransfer(req)
 	
 	if err != nil {
-		switch {
-		case errors.Is(err, services.ErrSameUser):
-			return c.Status(422).JSON(fiber.Map{
-				"error":   "INVALID_OPERATION",
-				"message": "Cannot transfer to the same user",
-			})
-		case errors.Is(err, services.ErrUserNotFound):
-			return c.Status(404).JSON(fiber.Map{
-				"error":   "USER_NOT_FOUND",
-				"message": "One or both users not found",
-			})
-		case errors.Is(err, services.ErrInsufficientPoints):
-			return c.Status(409).JSON(fiber.Map{
-				"error":   "INSUFFICIENT_POINTS",
-				"message": "Sender does not have enough points",
-			})
-		default:
-			return c.Status(500).JSON(fiber.Map{
-				"error":   "INTERNAL_ERROR",
-				"message": "Failed to create transfer",
-			})
-		}
+		return transferError(c, err, "Failed to create transfer")
 	}
 
 	// Set Idempotency-Key header
@@ -76,12 +88,105 @@ func CreateTransfer(c *fiber.Ctx) error {
 	})
 }
 
-// GetTransfer handles GET /transfers/{id}
-func GetTransfer(c *fiber.Ctx) error {
-	if transferService == nil {
-		InitTransferService()
+// CreateTransferBatch handles POST /transfers/batch
+func (h *TransferHandler) CreateTransferBatch(c *fiber.Ctx) error {
+	req := new(models.TransferBatchRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Invalid input format",
+		})
+	}
+
+	if req.Mode == "" {
+		req.Mode = models.TransferBatchPartial
+	}
+	if req.Mode != models.TransferBatchAtomic && req.Mode != models.TransferBatchPartial {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "mode must be atomic or partial",
+		})
+	}
+
+	if len(req.Items) == 0 || len(req.Items) > MaxBatchSize {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": fmt.Sprintf("items must contain between 1 and %d transfers", MaxBatchSize),
+		})
+	}
+
+	// An Idempotency-Key on the batch is expanded to one key per item
+	batchKey := c.Get("Idempotency-Key")
+	if len(batchKey) > maxBatchKeyLength {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": fmt.Sprintf("Idempotency-Key must be at most %d characters for batches", maxBatchKeyLength),
+		})
+	}
+
+	callerID := middleware.UserID(c)
+	reqs := make([]*models.TransferCreateRequest, len(req.Items))
+	for i, item := range req.Items {
+		if item.FromUserID != 0 && item.FromUserID != callerID {
+			return forbidden(c, fmt.Sprintf("items[%d].fromUserId must be the authenticated user", i))
+		}
+		reqs[i] = &models.TransferCreateRequest{
+			FromUserID: callerID,
+			ToUserID:   item.ToUserID,
+			Amount:     item.Amount,
+			Note:       item.Note,
+		}
+		if batchKey != "" {
+			reqs[i].IdempotencyKey = fmt.Sprintf("%s%s:%d", batchKeyPrefix, batchKey, i)
+		}
+	}
+
+	atomic := req.Mode == models.TransferBatchAtomic
+	results, err := h.transfers.CreateTransferBatch(reqs, atomic)
+
+	response := models.TransferBatchResponse{
+		Mode:    req.Mode,
+		Results: make([]models.TransferBatchItemResult, len(results)),
+	}
+	for i, result := range results {
+		item := models.TransferBatchItemResult{
+			Index:    i,
+			Status:   "succeeded",
+			Transfer: result.Transfer,
+		}
+		if result.Err != nil {
+			item.Status = "failed"
+			if errors.Is(result.Err, services.ErrBatchRolledBack) {
+				item.Status = "rolled_back"
+				item.Error = "ROLLED_BACK"
+				item.Message = "Not applied because another item in the batch failed"
+			} else {
+				_, item.Error, item.Message = transferErrorStatus(result.Err)
+				if item.Message == "" {
+					item.Message = "Failed to create transfer"
+				}
+			}
+			response.Failed++
+		} else {
+			response.Succeeded++
+		}
+		response.Results[i] = item
+	}
+
+	// In atomic mode the failing item decides the status code
+	if atomic {
+		if err != nil {
+			status, _, _ := transferErrorStatus(err)
+			return c.Status(status).JSON(response)
+		}
+		return c.Status(201).JSON(response)
 	}
 
+	return c.JSON(response)
+}
+
+// GetTransfer handles GET /transfers/{id}
+func (h *TransferHandler) GetTransfer(c *fiber.Ctx) error {
 	idemKey := c.Params("id")
 	if idemKey == "" {
 		return c.Status(400).JSON(fiber.Map{
@@ -90,9 +195,9 @@ func GetTransfer(c *fiber.Ctx) error {
 		})
 	}
 
-	transfer, err := transferService.GetTransferByIdemKey(idemKey)
+	transfer, err := h.transfers.GetTransferByIdemKey(idemKey)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
+		if errors.Is(err, services.ErrTransferNotFound) {
 			return c.Status(404).JSON(fiber.Map{
 				"error":   "NOT_FOUND",
 				"message": "Transfer not found",
@@ -104,45 +209,219 @@ func GetTransfer(c *fiber.Ctx) error {
 		})
 	}
 
+	// Other users' transfers are reported as missing
+	if !isParty(c, transfer) && !middleware.Can(c, middleware.PermTransfersRead) {
+		return c.Status(404).JSON(fiber.Map{
+			"error":   "NOT_FOUND",
+			"message": "Transfer not found",
+		})
+	}
+
 	return c.JSON(models.TransferResponse{
 		Transfer: transfer,
 	})
 }
 
 // ListTransfers handles GET /transfers?userId=X&page=1&pageSize=20
-func ListTransfers(c *fiber.Ctx) error {
-	if transferService == nil {
-		InitTransferService()
+func (h *TransferHandler) ListTransfers(c *fiber.Ctx) error {
+	// userId is optional and defaults to the authenticated user
+	userID := uint64(middleware.UserID(c))
+	if userIDStr := c.Query("userId"); userIDStr != "" {
+		parsed, err := strconv.ParseUint(userIDStr, 10, 32)
+		if err != nil || parsed == 0 {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "VALIDATION_ERROR",
+				"message": "userId must be a valid positive integer",
+			})
+		}
+		if parsed != userID && !middleware.Can(c, middleware.PermTransfersRead) {
+			return forbidden(c, "Cannot list another user's transfers")
+		}
+		userID = parsed
+	}
+
+	// Get pagination parameters
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	pageSize, _ := strconv.Atoi(c.Query("pageSize", "20"))
+
+	result, err := h.transfers.GetTransfersByUserID(uint(userID), page, pageSize)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error":   "INTERNAL_ERROR",
+			"message": "Failed to fetch transfers",
+		})
 	}
 
-	// Get userId from query (required)
-	userIDStr := c.Query("userId")
-	if userIDStr == "" {
+	return c.JSON(result)
+}
+
+// ReverseTransfer handles POST /transfers/{id}/reverse
+func (h *TransferHandler) ReverseTransfer(c *fiber.Ctx) error {
+	req := new(models.TransferActionRequest)
+	if err := c.BodyParser(req); err != nil {
 		return c.Status(400).JSON(fiber.Map{
 			"error":   "VALIDATION_ERROR",
-			"message": "userId query parameter is required",
+			"message": "Invalid input format",
 		})
 	}
 
-	userID, err := strconv.ParseUint(userIDStr, 10, 32)
-	if err != nil || userID == 0 {
+	if req.Reason == "" {
 		return c.Status(400).JSON(fiber.Map{
 			"error":   "VALIDATION_ERROR",
-			"message": "userId must be a valid positive integer",
+			"message": "reason is required",
 		})
 	}
 
-	// Get pagination parameters
-	page, _ := strconv.Atoi(c.Query("page", "1"))
-	pageSize, _ := strconv.Atoi(c.Query("pageSize", "20"))
+	// Only the receiver (or an admin) can give the points back
+	if ok, err := h.authorizeTransfer(c, true); !ok {
+		return err
+	}
 
-	result, err := transferService.GetTransfersByUserID(uint(userID), page, pageSize)
+	transfer, err := h.transfers.ReverseTransfer(c.Params("id"), req.Reason)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error":   "INTERNAL_ERROR",
-			"message": "Failed to fetch transfers",
-		})
+		return transferError(c, err, "Failed to reverse transfer")
 	}
 
-	return c.JSON(result)
+	return c.JSON(models.TransferResponse{
+		Transfer: transfer,
+	})
+}
+
+// CancelTransfer handles POST /transfers/{id}/cancel
+func (h *TransferHandler) CancelTransfer(c *fiber.Ctx) error {
+	// Reason is optional for cancellation
+	req := new(models.TransferActionRequest)
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "VALIDATION_ERROR",
+				"message": "Invalid input format",
+			})
+		}
+	}
+
+	if ok, err := h.authorizeTransfer(c, false); !ok {
+		return err
+	}
+
+	transfer, err := h.transfers.CancelTransfer(c.Params("id"), req.Reason)
+	if err != nil {
+		return transferError(c, err, "Failed to cancel transfer")
+	}
+
+	return c.JSON(models.TransferResponse{
+		Transfer: transfer,
+	})
+}
+
+// CaptureTransfer handles POST /transfers/{id}/capture
+func (h *TransferHandler) CaptureTransfer(c *fiber.Ctx) error {
+	if ok, err := h.authorizeTransfer(c, false); !ok {
+		return err
+	}
+
+	transfer, err := h.transfers.CaptureTransfer(c.Params("id"))
+	if err != nil {
+		return transferError(c, err, "Failed to capture transfer")
+	}
+
+	return c.JSON(models.TransferResponse{
+		Transfer: transfer,
+	})
+}
+
+// VoidTransfer handles POST /transfers/{id}/void
+func (h *TransferHandler) VoidTransfer(c *fiber.Ctx) error {
+	// Reason is optional when voiding
+	req := new(models.TransferActionRequest)
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "VALIDATION_ERROR",
+				"message": "Invalid input format",
+			})
+		}
+	}
+
+	if ok, err := h.authorizeTransfer(c, false); !ok {
+		return err
+	}
+
+	transfer, err := h.transfers.VoidTransfer(c.Params("id"), req.Reason)
+	if err != nil {
+		return transferError(c, err, "Failed to void transfer")
+	}
+
+	return c.JSON(models.TransferResponse{
+		Transfer: transfer,
+	})
+}
+
+// isParty reports whether the authenticated user sent or receives transfer
+func isParty(c *fiber.Ctx, transfer *models.Transfer) bool {
+	callerID := middleware.UserID(c)
+	return transfer.FromUserID == callerID || transfer.ToUserID == callerID
+}
+
+// authorizeTransfer checks that the authenticated user may act on the
+// transfer named by the id param. Roles that can move points may act on any
+// transfer. When it returns false the error response has already been written.
+func (h *TransferHandler) authorizeTransfer(c *fiber.Ctx, receiverOnly bool) (bool, error) {
+	transfer, err := h.transfers.GetTransferByIdemKey(c.Params("id"))
+	if err != nil {
+		return false, transferError(c, err, "Failed to fetch transfer")
+	}
+
+	if middleware.Can(c, middleware.PermPointsWrite) {
+		return true, nil
+	}
+	if !isParty(c, transfer) {
+		return false, transferError(c, services.ErrTransferNotFound, "")
+	}
+	if receiverOnly && transfer.ToUserID != middleware.UserID(c) {
+		return false, forbidden(c, "Only the receiver can reverse a transfer")
+	}
+
+	return true, nil
+}
+
+// transferErrorStatus maps a transfer service error to its HTTP status,
+// error code and message
+func transferErrorStatus(err error) (int, string, string) {
+	switch {
+	case errors.Is(err, services.ErrSameUser):
+		return 422, "INVALID_OPERATION", "Cannot transfer to the same user"
+	case errors.Is(err, services.ErrInvalidAmount):
+		return 400, "VALIDATION_ERROR", "amount must be greater than 0"
+	case errors.Is(err, services.ErrUserNotFound):
+		return 404, "USER_NOT_FOUND", "One or both users not found"
+	case errors.Is(err, services.ErrInsufficientPoints):
+		return 409, "INSUFFICIENT_POINTS", "Sender does not have enough points"
+	case errors.Is(err, services.ErrIdempotencyKeyUsed):
+		return 422, "IDEMPOTENCY_KEY_REUSED", "Idempotency-Key was already used with a different request"
+	case errors.Is(err, services.ErrTransferNotFound):
+		return 404, "NOT_FOUND", "Transfer not found"
+	case errors.Is(err, services.ErrInvalidStatus):
+		return 409, "INVALID_STATUS", "Transfer status does not allow this operation"
+	case errors.Is(err, services.ErrReceiverNoFunds):
+		return 409, "INSUFFICIENT_POINTS", "Receiver does not have enough points to reverse"
+	case errors.Is(err, services.ErrNotHold):
+		return 422, "INVALID_OPERATION", "Transfer is not a hold"
+	case errors.Is(err, services.ErrHoldExpired):
+		return 409, "HOLD_EXPIRED", "Hold has expired and was voided"
+	default:
+		return 500, "INTERNAL_ERROR", ""
+	}
+}
+
+// transferError writes the standard error response for a transfer service error
+func transferError(c *fiber.Ctx, err error, fallback string) error {
+	status, code, message := transferErrorStatus(err)
+	if message == "" {
+		message = fallback
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"error":   code,
+		"message": message,
+	})
 }
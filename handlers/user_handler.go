@@ -1,18 +1,29 @@
 package handlers
 
 import (
-	"class-go-ai/database"
+	"errors"
+	"strings"
+
 	"class-go-ai/models"
+	"class-go-ai/repository"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// UserHandler serves the /users routes
+type UserHandler struct {
+	users repository.UserRepository
+}
+
+// NewUserHandler creates a UserHandler
+func NewUserHandler(users repository.UserRepository) *UserHandler {
+	return &UserHandler{users: users}
+}
+
 // GetUsers returns all users
-func GetUsers(c *fiber.Ctx) error {
-	var users []models.User
-	
-	result := database.DB.Order("id DESC").Find(&users)
-	if result.Error != nil {
+func (h *UserHandler) GetUsers(c *fiber.Ctx) error {
+	users, err := h.users.List()
+	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to fetch users",
 		})
@@ -22,12 +33,11 @@ func GetUsers(c *fiber.Ctx) error {
 }
 
 // GetUser returns a single user by ID
-func GetUser(c *fiber.Ctx) error {
-	id := c.Params("id")
-	var user models.User
+func (h *UserHandler) GetUser(c *fiber.Ctx) error {
+	id, _ := c.ParamsInt("id")
 
-	result := database.DB.First(&user, id)
-	if result.Error != nil {
+	user, err := h.users.FindByID(uint(id))
+	if err != nil {
 		return c.Status(404).JSON(fiber.Map{
 			"error": "User not found",
 		})
@@ -37,7 +47,7 @@ func GetUser(c *fiber.Ctx) error {
 }
 
 // CreateUser creates a new user
-func CreateUser(c *fiber.Ctx) error {
+func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
 	input := new(models.UserInput)
 
 	if err := c.BodyParser(input); err != nil {
@@ -46,23 +56,36 @@ func CreateUser(c *fiber.Ctx) error {
 		})
 	}
 
+	email := normalizeEmail(input.Email)
+
 	// Validate required fields
-	if input.Name == "" || input.Email == "" {
+	if input.Name == "" || email == "" {
 		return c.Status(400).JSON(fiber.Map{
 			"error": "Name and email are required",
 		})
 	}
 
+	taken, err := h.emailTaken(email, 0)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to create user",
+		})
+	}
+	if taken {
+		return c.Status(409).JSON(fiber.Map{
+			"error": "Email is already registered",
+		})
+	}
+
 	user := models.User{
 		Name:    input.Name,
-		Email:   input.Email,
+		Email:   email,
 		Phone:   input.Phone,
 		Address: input.Address,
 		Avatar:  input.Avatar,
 	}
 
-	result := database.DB.Create(&user)
-	if result.Error != nil {
+	if err := h.users.Create(&user); err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to create user",
 		})
@@ -72,13 +95,12 @@ func CreateUser(c *fiber.Ctx) error {
 }
 
 // UpdateUser updates an existing user
-func UpdateUser(c *fiber.Ctx) error {
-	id := c.Params("id")
-	var user models.User
+func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
+	id, _ := c.ParamsInt("id")
 
 	// Check if user exists
-	result := database.DB.First(&user, id)
-	if result.Error != nil {
+	user, err := h.users.FindByID(uint(id))
+	if err != nil {
 		return c.Status(404).JSON(fiber.Map{
 			"error": "User not found",
 		})
@@ -91,44 +113,48 @@ func UpdateUser(c *fiber.Ctx) error {
 		})
 	}
 
-	// Update user fields
-	updates := map[string]interface{}{
-		"name":    input.Name,
-		"email":   input.Email,
-		"phone":   input.Phone,
-		"address": input.Address,
-		"avatar":  input.Avatar,
+	email := normalizeEmail(input.Email)
+	taken, err := h.emailTaken(email, user.ID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to update user",
+		})
 	}
+	if taken {
+		return c.Status(409).JSON(fiber.Map{
+			"error": "Email is already registered",
+		})
+	}
+
+	// Update user fields
+	user.Name = input.Name
+	user.Email = email
+	user.Phone = input.Phone
+	user.Address = input.Address
+	user.Avatar = input.Avatar
 
-	result = database.DB.Model(&user).Updates(updates)
-	if result.Error != nil {
+	if err := h.users.UpdateProfile(user); err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to update user",
 		})
 	}
 
-	// Fetch updated user
-	database.DB.First(&user, id)
-
 	return c.JSON(user)
 }
 
 // DeleteUser deletes a user by ID
-func DeleteUser(c *fiber.Ctx) error {
-	id := c.Params("id")
-	var user models.User
+func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
+	id, _ := c.ParamsInt("id")
 
 	// Check if user exists
-	result := database.DB.First(&user, id)
-	if result.Error != nil {
+	if _, err := h.users.FindByID(uint(id)); err != nil {
 		return c.Status(404).JSON(fiber.Map{
 			"error": "User not found",
 		})
 	}
 
 	// Delete user
-	result = database.DB.Delete(&user)
-	if result.Error != nil {
+	if err := h.users.Delete(uint(id)); err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to delete user",
 		})
@@ -138,3 +164,21 @@ func DeleteUser(c *fiber.Ctx) error {
 		"message": "User deleted successfully",
 	})
 }
+
+// emailTaken reports whether email belongs to a user other than userID,
+// deleted users included
+func (h *UserHandler) emailTaken(email string, userID uint) (bool, error) {
+	other, err := h.users.FindByEmail(email)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return other.ID != userID, nil
+}
+
+// normalizeEmail stores emails the way Register and Login look them up
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
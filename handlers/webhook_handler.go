@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"errors"
+
+	"class-go-ai/middleware"
+	"class-go-ai/models"
+	"class-go-ai/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// WebhookHandler serves the /webhooks routes
+type WebhookHandler struct {
+	webhooks *services.WebhookService
+}
+
+// NewWebhookHandler creates a WebhookHandler
+func NewWebhookHandler(webhooks *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{webhooks: webhooks}
+}
+
+// CreateWebhook handles POST /webhooks
+func (h *WebhookHandler) CreateWebhook(c *fiber.Ctx) error {
+	req := new(models.WebhookCreateRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Invalid input format",
+		})
+	}
+
+	// Roles that can read every transfer receive every transfer's events
+	allUsers := middleware.Can(c, middleware.PermTransfersRead)
+
+	subscription, secret, err := h.webhooks.CreateSubscription(middleware.UserID(c), req, allUsers)
+	if err != nil {
+		return webhookError(c, err, "Failed to create webhook")
+	}
+
+	return c.Status(201).JSON(models.WebhookCreateResponse{
+		Subscription: subscription,
+		Secret:       secret,
+	})
+}
+
+// ListWebhooks handles GET /webhooks
+func (h *WebhookHandler) ListWebhooks(c *fiber.Ctx) error {
+	subscriptions, err := h.webhooks.ListSubscriptions(middleware.UserID(c))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error":   "INTERNAL_ERROR",
+			"message": "Failed to fetch webhooks",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": subscriptions,
+	})
+}
+
+// DeleteWebhook handles DELETE /webhooks/{id}
+func (h *WebhookHandler) DeleteWebhook(c *fiber.Ctx) error {
+	id, ok, err := h.authorizeWebhook(c)
+	if !ok {
+		return err
+	}
+
+	if err := h.webhooks.DeleteSubscription(id); err != nil {
+		return webhookError(c, err, "Failed to delete webhook")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Webhook deleted successfully",
+	})
+}
+
+// ListWebhookDeliveries handles GET /webhooks/{id}/deliveries
+func (h *WebhookHandler) ListWebhookDeliveries(c *fiber.Ctx) error {
+	id, ok, err := h.authorizeWebhook(c)
+	if !ok {
+		return err
+	}
+
+	deliveries, err := h.webhooks.ListDeliveries(id, 100)
+	if err != nil {
+		return webhookError(c, err, "Failed to fetch webhook deliveries")
+	}
+
+	return c.JSON(fiber.Map{
+		"data": deliveries,
+	})
+}
+
+// RedeliverWebhook handles POST /webhooks/{id}/deliveries/{deliveryId}/redeliver
+func (h *WebhookHandler) RedeliverWebhook(c *fiber.Ctx) error {
+	id, ok, err := h.authorizeWebhook(c)
+	if !ok {
+		return err
+	}
+
+	deliveryID, err := c.ParamsInt("deliveryId")
+	if err != nil || deliveryID <= 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Delivery ID must be a valid positive integer",
+		})
+	}
+
+	delivery, err := h.webhooks.Redeliver(id, uint(deliveryID))
+	if err != nil {
+		return webhookError(c, err, "Failed to redeliver webhook")
+	}
+
+	return c.Status(202).JSON(delivery)
+}
+
+// authorizeWebhook checks that the authenticated user owns the subscription
+// named by the id param. When ok is false the error response has already
+// been written.
+func (h *WebhookHandler) authorizeWebhook(c *fiber.Ctx) (uint, bool, error) {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return 0, false, c.Status(400).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Webhook ID must be a valid positive integer",
+		})
+	}
+
+	subscription, err := h.webhooks.GetSubscription(uint(id))
+	if err != nil {
+		return 0, false, webhookError(c, err, "Failed to fetch webhook")
+	}
+
+	// Other users' subscriptions are reported as missing
+	if subscription.OwnerID != middleware.UserID(c) {
+		return 0, false, webhookError(c, services.ErrWebhookNotFound, "")
+	}
+
+	return subscription.ID, true, nil
+}
+
+// webhookError writes the standard error response for a webhook service error
+func webhookError(c *fiber.Ctx, err error, fallback string) error {
+	switch {
+	case errors.Is(err, services.ErrWebhookNotFound):
+		return c.Status(404).JSON(fiber.Map{
+			"error":   "NOT_FOUND",
+			"message": "Webhook not found",
+		})
+	case errors.Is(err, services.ErrDeliveryNotFound):
+		return c.Status(404).JSON(fiber.Map{
+			"error":   "NOT_FOUND",
+			"message": "Webhook delivery not found",
+		})
+	case errors.Is(err, services.ErrInvalidURL),
+		errors.Is(err, services.ErrPrivateURL),
+		errors.Is(err, services.ErrInvalidEvent):
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": err.Error(),
+		})
+	default:
+		return c.Status(500).JSON(fiber.Map{
+			"error":   "INTERNAL_ERROR",
+			"message": fallback,
+		})
+	}
+}
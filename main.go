@@ -1,35 +1,145 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"class-go-ai/commands"
+	"class-go-ai/config"
+	"class-go-ai/container"
 	"class-go-ai/database"
+	"class-go-ai/middleware"
 	"class-go-ai/routes"
+	"class-go-ai/workers"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 )
 
 func main() {
+	// Subcommands, e.g. `go run . reconcile -repair`
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		if err := commands.Run(os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Defaults, then -config/CONFIG_FILE, then the environment, then flags
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Initialize database connection
-	if err := database.Connect(); err != nil {
+	if err := database.Connect(cfg.Database); err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
+	// Refuse to serve on a schema the migrations have not brought up to date
+	if err := database.CheckSchema(database.DB); err != nil {
+		log.Fatal(err, " (run `go run . migrate up`)")
+	}
+
+	// Services, repositories and the event broker of this server
+	c := container.New(cfg, database.DB)
+
+	// Background workers, stopped on shutdown after their current run
+	c.Workers.Go("hold expiry", time.Minute, func(ctx context.Context) {
+		workers.RunHoldExpiry(ctx, c.Transfers, time.Minute)
+	})
+	c.Workers.Go("scheduler", time.Minute, func(ctx context.Context) {
+		workers.RunScheduler(ctx, c.Schedules, time.Minute)
+	})
+	c.Workers.Go("webhook delivery", 5*time.Second, func(ctx context.Context) {
+		workers.RunWebhookDelivery(ctx, c.Webhooks, 5*time.Second)
+	})
+
 	// Create new Fiber app
 	app := fiber.New(fiber.Config{
-		AppName: "User Management API v1.0",
+		AppName:   "User Management API v1.0",
+		BodyLimit: cfg.Server.BodyLimit,
 	})
 
 	// Middlewares
+	inFlight := middleware.NewInFlight()
+	app.Use(inFlight.Handler())
 	app.Use(logger.New())
-	app.Use(cors.New())
 
 	// Setup routes
-	routes.SetupRoutes(app)
+	routes.SetupRoutes(app, c)
+
+	// SIGINT or SIGTERM starts a graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// Start server
-	log.Println("Server starting on port 3000...")
-	log.Fatal(app.Listen(":3000"))
+	log.Printf("Server starting on port %d...", cfg.Server.Port)
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- app.Listen(fmt.Sprintf(":%d", cfg.Server.Port))
+	}()
+
+	select {
+	case err := <-listenErr:
+		log.Fatal(err)
+	case <-ctx.Done():
+	}
+	// A second signal kills the process right away
+	stop()
+
+	// Fail readiness first and keep serving while the orchestrator notices
+	c.Draining.Store(true)
+	if delay := time.Duration(cfg.Server.ShutdownDelay); delay > 0 {
+		log.Printf("Draining, still serving for %s...", delay)
+		time.Sleep(delay)
+	}
+
+	timeout := time.Duration(cfg.Server.ShutdownTimeout)
+	log.Printf("Shutting down, waiting up to %s for requests and workers...", timeout)
+	if abandoned := shutdown(app, c, inFlight, timeout); len(abandoned) > 0 {
+		log.Fatalf("Shutdown timed out, abandoned: %s", strings.Join(abandoned, ", "))
+	}
+	log.Println("Shutdown complete")
+}
+
+// shutdown stops accepting connections and lets streams, in-flight requests
+// and workers finish until timeout, then closes the database. It returns
+// what was still running when time ran out.
+func shutdown(app *fiber.App, c *container.Container, inFlight *middleware.InFlight, timeout time.Duration) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// SSE and WebSocket streams never end on their own, so they are told to
+	// before the server waits for its connections
+	c.Broker.Close()
+	c.Workers.Stop()
+
+	var abandoned []string
+	if err := app.ShutdownWithContext(ctx); err != nil {
+		for _, request := range inFlight.Requests() {
+			abandoned = append(abandoned, "request "+request)
+		}
+	}
+	if open := c.Broker.Wait(ctx); open > 0 {
+		abandoned = append(abandoned, fmt.Sprintf("%d event stream(s)", open))
+	}
+	for _, name := range c.Workers.Wait(ctx) {
+		abandoned = append(abandoned, name+" worker")
+	}
+
+	sqlDB, err := c.DB.DB()
+	if err == nil {
+		err = sqlDB.Close()
+	}
+	if err != nil {
+		log.Println("Failed to close the database:", err)
+	}
+	return abandoned
 }
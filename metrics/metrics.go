@@ -0,0 +1,188 @@
+// Package metrics exposes the Prometheus metrics of one application
+// instance: HTTP latency per route, transfer outcomes and the points they
+// moved, ledger entries, transfer transaction durations, the database
+// connection pool and the event broker
+package metrics
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"class-go-ai/events"
+	"class-go-ai/models"
+	"class-go-ai/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+)
+
+// namespace prefixes every metric of the application
+const namespace = "points"
+
+// Failure reasons of points_transfers_total
+const (
+	ReasonInsufficientPoints = "insufficient_points"
+	ReasonUserNotFound       = "user_not_found"
+	ReasonOther              = "other"
+)
+
+// RouteUnmatched is the route label of requests no route matched
+const RouteUnmatched = "unmatched"
+
+// Metrics holds the collectors of an app on a registry of its own, so apps
+// in one process (such as tests) do not share counts. It is an
+// events.Publisher: transfer and ledger counts come from committed changes,
+// so a rolled back batch is never counted.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests       *prometheus.HistogramVec
+	transfers      *prometheus.CounterVec
+	pointsMoved    prometheus.Counter
+	ledgerEntries  *prometheus.CounterVec
+	transferTxTime *prometheus.HistogramVec
+}
+
+// New registers the metrics of an app whose database is db and whose live
+// streams go through broker
+func New(db *gorm.DB, broker *events.Broker) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to handle HTTP requests, by route pattern.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		transfers: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "transfers_total",
+			Help:      "Transfers that reached a final status, with the reason of failed ones.",
+		}, []string{"status", "reason"}),
+		pointsMoved: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "transfer_points_moved_total",
+			Help:      "Points moved by completed transfers.",
+		}),
+		ledgerEntries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "ledger_entries_total",
+			Help:      "Committed point ledger entries, by event type.",
+		}, []string{"event_type"}),
+		transferTxTime: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "transfer_transaction_duration_seconds",
+			Help:      "Time taken by the database transaction of a transfer.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"outcome"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests, m.transfers, m.pointsMoved, m.ledgerEntries, m.transferTxTime,
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "events_dropped_total",
+			Help:      "Live stream messages dropped because a subscriber fell behind.",
+		}, func() float64 { return float64(broker.Dropped()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "events_subscribers",
+			Help:      "Open live stream subscriptions.",
+		}, func() float64 { return float64(broker.Subscribers()) }),
+	)
+	if sqlDB, err := db.DB(); err == nil {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(sqlDB, namespace))
+	}
+
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (m *Metrics) Handler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+}
+
+// Middleware times every request under the pattern of the route that
+// handled it, such as /transfers/:id, so the label stays bounded
+func (m *Metrics) Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		started := time.Now()
+		own := c.Route()
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			// The error handler has not written the response yet
+			status = fiber.StatusInternalServerError
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				status = fiberErr.Code
+			}
+		}
+
+		route := RouteUnmatched
+		if c.Route() != own {
+			route = c.Route().Path
+		}
+		// The method is copied since fiber reuses its memory
+		m.requests.WithLabelValues(utils.CopyString(c.Method()), route, strconv.Itoa(status)).Observe(time.Since(started).Seconds())
+		return err
+	}
+}
+
+// Publish implements events.Publisher, counting transfers that reached a
+// final status and ledger entries
+func (m *Metrics) Publish(messages ...events.Message) {
+	for _, message := range messages {
+		switch {
+		case message.Ledger != nil:
+			m.ledgerEntries.WithLabelValues(string(message.Ledger.EventType)).Inc()
+		case message.Transfer != nil:
+			m.countTransfer(message.Transfer)
+		}
+	}
+}
+
+// countTransfer counts a committed transfer change
+func (m *Metrics) countTransfer(transfer *models.Transfer) {
+	switch transfer.Status {
+	case models.TransferStatusCompleted:
+		m.transfers.WithLabelValues(string(transfer.Status), "").Inc()
+		m.pointsMoved.Add(float64(transfer.Amount))
+	case models.TransferStatusFailed:
+		reason := ReasonOther
+		if transfer.FailReason == services.FailReasonInsufficientPoints {
+			reason = ReasonInsufficientPoints
+		}
+		m.transfers.WithLabelValues(string(transfer.Status), reason).Inc()
+	case models.TransferStatusCancelled, models.TransferStatusReversed:
+		m.transfers.WithLabelValues(string(transfer.Status), "").Inc()
+	}
+}
+
+// TransferRejected implements services.TransferMetrics. A rejected transfer
+// counts as failed.
+func (m *Metrics) TransferRejected(err error) {
+	reason := ReasonOther
+	if errors.Is(err, services.ErrUserNotFound) {
+		reason = ReasonUserNotFound
+	}
+	m.transfers.WithLabelValues(string(models.TransferStatusFailed), reason).Inc()
+}
+
+// TransferTransaction implements services.TransferMetrics
+func (m *Metrics) TransferTransaction(elapsed time.Duration, err error) {
+	outcome := "committed"
+	if err != nil {
+		outcome = "rolled_back"
+	}
+	m.transferTxTime.WithLabelValues(outcome).Observe(elapsed.Seconds())
+}
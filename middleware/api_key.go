@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+
+	"class-go-ai/models"
+	"class-go-ai/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// HeaderAPIKey carries an API key instead of a bearer token
+const HeaderAPIKey = "X-API-Key"
+
+// apiKeyKey is the Locals key holding the API key of a key request
+const apiKeyKey = "apiKey"
+
+// RequireAuthOrAPIKey accepts either a bearer token, like RequireAuth, or an
+// X-API-Key granted scope. Key requests act as the key's source user with
+// member permissions, so keys never inherit the owner's role.
+func RequireAuthOrAPIKey(auth *services.AuthService, keys *services.APIKeyService, scope models.APIKeyScope) fiber.Handler {
+	session := RequireAuth(auth)
+
+	return func(c *fiber.Ctx) error {
+		plaintext := c.Get(HeaderAPIKey)
+		if plaintext == "" {
+			return session(c)
+		}
+
+		key, err := keys.Authenticate(plaintext, func(role models.Role) bool {
+			return RoleCan(role, PermPointsWrite)
+		})
+		if errors.Is(err, services.ErrInvalidAPIKey) {
+			return c.Status(401).JSON(fiber.Map{
+				"error":   "UNAUTHORIZED",
+				"message": "Invalid, expired or revoked API key",
+			})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "INTERNAL_ERROR",
+				"message": "Failed to authenticate",
+			})
+		}
+
+		if !key.HasScope(scope) {
+			return c.Status(403).JSON(fiber.Map{
+				"error":   "FORBIDDEN",
+				"message": fmt.Sprintf("API key is missing the %s scope", scope),
+			})
+		}
+
+		SetUser(c, key.ActingUserID(), models.RoleMember)
+		c.Locals(apiKeyKey, key)
+		return c.Next()
+	}
+}
+
+// APIKey returns the API key of the request, or nil for sessions
+func APIKey(c *fiber.Ctx) *models.APIKey {
+	key, _ := c.Locals(apiKeyKey).(*models.APIKey)
+	return key
+}
@@ -0,0 +1,69 @@
+// Package middleware contains the Fiber middleware applied in routes.SetupRoutes
+package middleware
+
+import (
+	"errors"
+	"strings"
+
+	"class-go-ai/models"
+	"class-go-ai/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Locals keys holding the authenticated user
+const (
+	userIDKey = "userID"
+	roleKey   = "role"
+)
+
+// RequireAuth rejects requests without a valid "Authorization: Bearer"
+// access token and stores the caller's user ID and role for UserID and Role
+func RequireAuth(auth *services.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		header := c.Get(fiber.HeaderAuthorization)
+		token, found := strings.CutPrefix(header, "Bearer ")
+		if !found || token == "" {
+			return c.Status(401).JSON(fiber.Map{
+				"error":   "UNAUTHORIZED",
+				"message": "Missing bearer token",
+			})
+		}
+
+		user, err := auth.Authenticate(token)
+		if errors.Is(err, services.ErrInvalidToken) {
+			return c.Status(401).JSON(fiber.Map{
+				"error":   "UNAUTHORIZED",
+				"message": "Invalid or expired token",
+			})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "INTERNAL_ERROR",
+				"message": "Failed to authenticate",
+			})
+		}
+
+		SetUser(c, user.ID, user.Role)
+		return c.Next()
+	}
+}
+
+// SetUser records the authenticated caller of a request, as RequireAuth does
+// once the token checks out
+func SetUser(c *fiber.Ctx, userID uint, role models.Role) {
+	c.Locals(userIDKey, userID)
+	c.Locals(roleKey, role)
+}
+
+// UserID returns the authenticated user ID, or 0 outside RequireAuth
+func UserID(c *fiber.Ctx) uint {
+	userID, _ := c.Locals(userIDKey).(uint)
+	return userID
+}
+
+// Role returns the authenticated user's role, or "" outside RequireAuth
+func Role(c *fiber.Ctx) models.Role {
+	role, _ := c.Locals(roleKey).(models.Role)
+	return role
+}
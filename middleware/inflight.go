@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// InFlight tracks the requests being handled, so a shutdown can report the
+// ones it had to abandon
+type InFlight struct {
+	mu       sync.Mutex
+	next     uint64
+	requests map[uint64]string
+}
+
+// NewInFlight creates an empty tracker
+func NewInFlight() *InFlight {
+	return &InFlight{requests: make(map[uint64]string)}
+}
+
+// Handler tracks each request until the rest of the chain returns.
+// Response bodies streamed afterwards, such as SSE, are not included.
+func (f *InFlight) Handler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// Concatenating copies the path, whose memory fiber reuses later
+		request := c.Method() + " " + c.Path()
+
+		f.mu.Lock()
+		f.next++
+		id := f.next
+		f.requests[id] = request
+		f.mu.Unlock()
+
+		defer func() {
+			f.mu.Lock()
+			delete(f.requests, id)
+			f.mu.Unlock()
+		}()
+		return c.Next()
+	}
+}
+
+// Requests returns "METHOD /path" for every request still being handled
+func (f *InFlight) Requests() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	requests := make([]string, 0, len(f.requests))
+	for _, request := range f.requests {
+		requests = append(requests, request)
+	}
+	slices.Sort(requests)
+	return requests
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"class-go-ai/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Permission names an operation guarded by role
+type Permission string
+
+const (
+	PermUsersRead      Permission = "users:read"      // list and read any user
+	PermUsersWrite     Permission = "users:write"     // create, update and delete any user
+	PermLedgerRead     Permission = "ledger:read"     // read any user's ledger
+	PermTransfersRead  Permission = "transfers:read"  // read any user's transfers
+	PermPointsWrite    Permission = "points:write"    // earn, adjust and redeem for any user, reverse any transfer
+	PermAuditRead      Permission = "audit:read"      // reconcile report and chain verification
+	PermRolesWrite     Permission = "roles:write"     // change user roles
+	PermReconcileWrite Permission = "reconcile:write" // post repair entries
+)
+
+// rolePermissions is the permission matrix. Members have none of these and
+// can only act on their own account.
+var rolePermissions = map[models.Role]map[Permission]bool{
+	models.RoleMember: {},
+	models.RoleSupport: {
+		PermUsersRead:     true,
+		PermLedgerRead:    true,
+		PermTransfersRead: true,
+		PermAuditRead:     true,
+	},
+	models.RoleAdmin: {
+		PermUsersRead:      true,
+		PermUsersWrite:     true,
+		PermLedgerRead:     true,
+		PermTransfersRead:  true,
+		PermPointsWrite:    true,
+		PermAuditRead:      true,
+		PermRolesWrite:     true,
+		PermReconcileWrite: true,
+	},
+}
+
+// Can reports whether the authenticated user's role grants perm
+func Can(c *fiber.Ctx, perm Permission) bool {
+	return RoleCan(Role(c), perm)
+}
+
+// RoleCan reports whether role grants perm, for callers outside a request
+// such as WebSocket connections
+func RoleCan(role models.Role, perm Permission) bool {
+	return rolePermissions[role][perm]
+}
+
+// Require rejects requests whose role does not grant perm
+func Require(perm Permission) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !Can(c, perm) {
+			return forbidden(c)
+		}
+		return c.Next()
+	}
+}
+
+// RequireSelfOr lets users act on their own :id, and everyone else only
+// with perm
+func RequireSelfOr(perm Permission) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err == nil && id > 0 && uint(id) == UserID(c) {
+			return c.Next()
+		}
+		if !Can(c, perm) {
+			return forbidden(c)
+		}
+		return c.Next()
+	}
+}
+
+func forbidden(c *fiber.Ctx) error {
+	return c.Status(403).JSON(fiber.Map{
+		"error":   "FORBIDDEN",
+		"message": "You do not have permission to perform this action",
+	})
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"class-go-ai/signing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DefaultSignatureWindow is how far a signed timestamp may be from now
+const DefaultSignatureWindow = 5 * time.Minute
+
+// SignatureConfig configures RequireSignature
+type SignatureConfig struct {
+	// Secret is the shared HMAC secret
+	Secret []byte
+	// Window bounds the clock skew and how long nonces are remembered,
+	// DefaultSignatureWindow if zero
+	Window time.Duration
+	// Nonces records seen nonces, a new store if nil
+	Nonces *signing.NonceStore
+	// Next skips verification when it returns true
+	Next func(c *fiber.Ctx) bool
+	// Now returns the current time, time.Now if nil
+	Now func() time.Time
+}
+
+// RequireSignature rejects requests whose HMAC signature over method, path,
+// timestamp, nonce and body is missing or wrong, whose timestamp is outside
+// the window, or whose nonce was already used
+func RequireSignature(config SignatureConfig) fiber.Handler {
+	if config.Window == 0 {
+		config.Window = DefaultSignatureWindow
+	}
+	if config.Nonces == nil {
+		config.Nonces = signing.NewNonceStore()
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+
+	return func(c *fiber.Ctx) error {
+		if config.Next != nil && config.Next(c) {
+			return c.Next()
+		}
+
+		timestamp := c.Get(signing.HeaderTimestamp)
+		nonce := c.Get(signing.HeaderNonce)
+		signature := c.Get(signing.HeaderSignature)
+		if timestamp == "" || nonce == "" || signature == "" {
+			return signatureError(c, "SIGNATURE_REQUIRED", "Request must be signed")
+		}
+
+		if !signing.Verify(config.Secret, c.Method(), c.OriginalURL(), timestamp, nonce, c.Body(), signature) {
+			return signatureError(c, "INVALID_SIGNATURE", "Request signature does not match")
+		}
+
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return signatureError(c, "INVALID_SIGNATURE", "Signature timestamp must be unix seconds")
+		}
+
+		now := config.Now()
+		signedAt := time.Unix(seconds, 0)
+		if signedAt.Before(now.Add(-config.Window)) || signedAt.After(now.Add(config.Window)) {
+			return signatureError(c, "REQUEST_EXPIRED", "Signature timestamp is outside the allowed window")
+		}
+
+		// Checked last so a forged request cannot burn a partner's nonce
+		if !config.Nonces.Use(nonce, now, signedAt.Add(config.Window)) {
+			return signatureError(c, "REPLAYED_REQUEST", "Request was already processed")
+		}
+
+		return c.Next()
+	}
+}
+
+func signatureError(c *fiber.Ctx, code, message string) error {
+	return c.Status(401).JSON(fiber.Map{
+		"error":   code,
+		"message": message,
+	})
+}
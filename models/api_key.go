@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// APIKeyScope names a group of routes an API key may call
+type APIKeyScope string
+
+const (
+	ScopeTransfersRead  APIKeyScope = "transfers:read"  // GET /transfers, GET /transfers/{id}
+	ScopeTransfersWrite APIKeyScope = "transfers:write" // create, batch, cancel, capture and void transfers
+	ScopeLedgerRead     APIKeyScope = "ledger:read"     // GET /users/{id}/ledger
+)
+
+// Valid reports whether s is a known scope
+func (s APIKeyScope) Valid() bool {
+	switch s {
+	case ScopeTransfersRead, ScopeTransfersWrite, ScopeLedgerRead:
+		return true
+	}
+	return false
+}
+
+// APIKey lets a server call the API without a user session. Only the
+// SHA-256 of the key is stored; Prefix identifies it in listings.
+type APIKey struct {
+	ID           uint          `gorm:"primaryKey" json:"id"`
+	Name         string        `gorm:"not null" json:"name"`
+	Prefix       string        `gorm:"not null;size:16" json:"prefix"`
+	KeyHash      string        `gorm:"uniqueIndex;not null;size:64" json:"-"`
+	OwnerID      uint          `gorm:"not null;index:idx_api_keys_owner" json:"ownerId"`
+	SourceUserID *uint         `json:"sourceUserId,omitempty"` // the only account the key may debit, defaults to the owner
+	Scopes       []APIKeyScope `gorm:"serializer:json;not null" json:"scopes"`
+	LastUsedAt   *time.Time    `json:"lastUsedAt,omitempty"`
+	ExpiresAt    *time.Time    `json:"expiresAt,omitempty"`
+	RevokedAt    *time.Time    `json:"revokedAt,omitempty"`
+	CreatedAt    time.Time     `json:"createdAt"`
+}
+
+// ActingUserID returns the user the key acts as
+func (k *APIKey) ActingUserID() uint {
+	if k.SourceUserID != nil {
+		return *k.SourceUserID
+	}
+	return k.OwnerID
+}
+
+// HasScope reports whether the key was granted scope
+func (k *APIKey) HasScope(scope APIKeyScope) bool {
+	for _, granted := range k.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyCreateRequest for POST /api-keys
+type APIKeyCreateRequest struct {
+	Name         string        `json:"name"`
+	Scopes       []APIKeyScope `json:"scopes"`
+	SourceUserID *uint         `json:"sourceUserId"`
+	ExpiresAt    *time.Time    `json:"expiresAt"`
+}
+
+// APIKeyResponse returns a key's plaintext, which is shown only once
+type APIKeyResponse struct {
+	APIKey *APIKey `json:"apiKey"`
+	Key    string  `json:"key"`
+}
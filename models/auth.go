@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// RefreshToken is a long-lived session token exchanged for new access
+// tokens. Only its SHA-256 hash is stored.
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index:idx_refresh_tokens_user" json:"userId"`
+	TokenHash string     `gorm:"uniqueIndex;not null;size:64" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expiresAt"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// RegisterRequest for POST /auth/register
+type RegisterRequest struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Phone    string `json:"phone"`
+	Address  string `json:"address"`
+	Avatar   string `json:"avatar"`
+}
+
+// LoginRequest for POST /auth/login
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// RefreshRequest for POST /auth/refresh and POST /auth/logout
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// TokenResponse is returned by register, login and refresh
+type TokenResponse struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	TokenType    string `json:"tokenType"`
+	ExpiresIn    int    `json:"expiresIn"` // access token lifetime in seconds
+	User         *User  `json:"user"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "class-go-ai/buildinfo"
+
+// Readiness statuses
+const (
+	ReadinessReady       = "ready"
+	ReadinessUnavailable = "unavailable"
+)
+
+// ReadinessResponse reports each readiness check as "ok" or what failed
+type ReadinessResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// VersionResponse describes the running build and its database schema
+type VersionResponse struct {
+	buildinfo.Info
+	SchemaVersion *int `json:"schemaVersion,omitempty"` // applied; left out if the database cannot be read
+	LatestSchema  int  `json:"latestSchemaVersion"`     // the version this build expects
+}
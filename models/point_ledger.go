@@ -26,4 +26,61 @@ type PointLedger struct {
 	Reference    string    `gorm:"type:text" json:"reference,omitempty"`
 	Metadata     string    `gorm:"type:text" json:"metadata,omitempty"` // JSON text
 	CreatedAt    time.Time `gorm:"index:idx_ledger_created" json:"createdAt"`
+	PrevHash     string    `gorm:"size:64" json:"prevHash,omitempty"` // Hash of the previous row
+	Hash         string    `gorm:"size:64" json:"hash,omitempty"`     // sha256 over PrevHash + row content
+}
+
+// LedgerHeadID is the id of the only LedgerHead row
+const LedgerHeadID = 1
+
+// LedgerHead names the newest entry of the hash chain. Every append moves it
+// from the hash it chained onto, so two transactions can never both extend
+// the same entry.
+type LedgerHead struct {
+	ID      uint   `gorm:"primaryKey;autoIncrement:false" json:"id"`
+	EntryID uint   `gorm:"not null" json:"entryId"`
+	Hash    string `gorm:"size:64;not null" json:"hash"`
+}
+
+// PointsEarnRequest for crediting earned points
+type PointsEarnRequest struct {
+	Amount int    `json:"amount" binding:"required,min=1"`
+	Source string `json:"source" binding:"required"` // e.g. order or campaign reference
+}
+
+// PointsRedeemRequest for debiting points on redemption
+type PointsRedeemRequest struct {
+	Amount    int    `json:"amount" binding:"required,min=1"`
+	Reference string `json:"reference"` // e.g. reward or voucher code
+}
+
+// PointsAdjustRequest for operator adjustments
+type PointsAdjustRequest struct {
+	Change int    `json:"change" binding:"required"` // signed
+	Reason string `json:"reason" binding:"required"`
+}
+
+// PointsResponse wraps the ledger entry written by a points operation
+type PointsResponse struct {
+	Entry   *PointLedger `json:"entry"`
+	Balance int          `json:"balance"`
+}
+
+// LedgerVerifyResult reports the outcome of walking the ledger hash chain
+type LedgerVerifyResult struct {
+	Valid          bool   `json:"valid"`
+	Checked        int    `json:"checked"`
+	Unsealed       int    `json:"unsealed"` // legacy rows written before the chain started
+	FirstInvalidID *uint  `json:"firstInvalidId,omitempty"`
+	Reason         string `json:"reason,omitempty"`
+	HeadID         uint   `json:"headId,omitempty"`
+	HeadHash       string `json:"headHash,omitempty"` // record externally to detect truncation
+}
+
+// LedgerListResponse for cursor-paginated ledger queries
+type LedgerListResponse struct {
+	Data        []PointLedger `json:"data"`
+	NextCursor  *uint         `json:"nextCursor,omitempty"` // pass as ?cursor= for the next page
+	AsOf        *time.Time    `json:"asOf,omitempty"`
+	BalanceAsOf *int          `json:"balanceAsOf,omitempty"` // omitted when no entry at or before asOf records it
 }
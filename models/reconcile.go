@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// UserDiscrepancy describes a user whose balance does not match the ledger
+type UserDiscrepancy struct {
+	UserID           uint     `json:"userId"`
+	Points           int      `json:"points"`
+	LedgerSum        int      `json:"ledgerSum"`
+	LastBalanceAfter *int     `json:"lastBalanceAfter,omitempty"`
+	Issues           []string `json:"issues"`
+	RepairEntryID    *uint    `json:"repairEntryId,omitempty"` // adjust entry posted in repair mode
+}
+
+// TransferDiscrepancy describes a transfer whose ledger rows are wrong
+type TransferDiscrepancy struct {
+	TransferID     uint           `json:"transferId"`
+	IdempotencyKey string         `json:"idemKey,omitempty"`
+	Status         TransferStatus `json:"status,omitempty"`
+	Amount         int            `json:"amount,omitempty"`
+	Issues         []string       `json:"issues"`
+}
+
+// ReconcileReport is the result of a ledger/balance reconciliation run
+type ReconcileReport struct {
+	GeneratedAt      time.Time             `json:"generatedAt"`
+	Repair           bool                  `json:"repair"`
+	UsersChecked     int                   `json:"usersChecked"`
+	TransfersChecked int                   `json:"transfersChecked"`
+	Users            []UserDiscrepancy     `json:"users"`
+	Transfers        []TransferDiscrepancy `json:"transfers"`
+}
+
+// Clean reports whether no discrepancies were found
+func (r *ReconcileReport) Clean() bool {
+	return len(r.Users) == 0 && len(r.Transfers) == 0
+}
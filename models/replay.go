@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// BalanceDiff is a user whose balance changes when the ledger is replayed
+type BalanceDiff struct {
+	UserID   uint `json:"userId"`
+	Current  int  `json:"current"`
+	Replayed int  `json:"replayed"`
+	Delta    int  `json:"delta"`
+}
+
+// BalanceAfterMismatch is a ledger entry whose BalanceAfter disagrees with
+// the running balance of the entries before it
+type BalanceAfterMismatch struct {
+	EntryID      uint `json:"entryId"`
+	UserID       uint `json:"userId"`
+	BalanceAfter int  `json:"balanceAfter"`
+	Expected     int  `json:"expected"`
+}
+
+// UnrecordedBalance is a user with points but no ledger entries to rebuild
+// them from, such as an opening balance written straight to the users table
+type UnrecordedBalance struct {
+	UserID uint `json:"userId"`
+	Points int  `json:"points"`
+}
+
+// ReplayReport is the result of rebuilding balances from the ledger
+type ReplayReport struct {
+	DryRun          bool                   `json:"dryRun"`
+	AsOf            *time.Time             `json:"asOf,omitempty"`
+	EntriesReplayed int                    `json:"entriesReplayed"`
+	UsersChecked    int                    `json:"usersChecked"`
+	Changes         []BalanceDiff          `json:"changes"`
+	Mismatches      []BalanceAfterMismatch `json:"balanceAfterMismatches"`
+	Unrecorded      []UnrecordedBalance    `json:"unrecordedBalances"`
+}
+
+// Clean reports whether the ledger accounts for every balance, so replaying
+// it loses nothing
+func (r *ReplayReport) Clean() bool {
+	return len(r.Mismatches) == 0 && len(r.Unrecorded) == 0
+}
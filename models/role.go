@@ -0,0 +1,24 @@
+package models
+
+// Role controls which operations a user may perform
+type Role string
+
+const (
+	RoleMember  Role = "member"  // own account, transfers and ledger
+	RoleSupport Role = "support" // read access to every account, cannot move points
+	RoleAdmin   Role = "admin"   // everything, including point adjustments
+)
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	switch r {
+	case RoleMember, RoleSupport, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+// RoleUpdateRequest for PUT /admin/users/{id}/role
+type RoleUpdateRequest struct {
+	Role Role `json:"role"`
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ScheduleFrequency represents how often a scheduled transfer runs
+type ScheduleFrequency string
+
+const (
+	ScheduleFrequencyOnce    ScheduleFrequency = "once"
+	ScheduleFrequencyDaily   ScheduleFrequency = "daily"
+	ScheduleFrequencyWeekly  ScheduleFrequency = "weekly"
+	ScheduleFrequencyMonthly ScheduleFrequency = "monthly"
+)
+
+// ScheduleStatus represents the status of a scheduled transfer
+type ScheduleStatus string
+
+const (
+	ScheduleStatusActive    ScheduleStatus = "active"
+	ScheduleStatusPaused    ScheduleStatus = "paused"
+	ScheduleStatusCompleted ScheduleStatus = "completed"
+)
+
+// InsufficientPolicy decides what happens when the sender cannot cover a run
+type InsufficientPolicy string
+
+const (
+	InsufficientPolicyRetry InsufficientPolicy = "retry" // retry later, then skip
+	InsufficientPolicySkip  InsufficientPolicy = "skip"  // skip this occurrence
+)
+
+// ScheduleRunStatus represents the outcome of a single scheduled run
+type ScheduleRunStatus string
+
+const (
+	ScheduleRunSucceeded ScheduleRunStatus = "succeeded"
+	ScheduleRunRetrying  ScheduleRunStatus = "retrying"
+	ScheduleRunSkipped   ScheduleRunStatus = "skipped"
+	ScheduleRunFailed    ScheduleRunStatus = "failed"
+)
+
+// ScheduledTransfer represents a one-off or recurring transfer
+type ScheduledTransfer struct {
+	ID             uint               `gorm:"primaryKey" json:"id"`
+	FromUserID     uint               `gorm:"not null;index:idx_schedules_from" json:"fromUserId"`
+	ToUserID       uint               `gorm:"not null" json:"toUserId"`
+	Amount         int                `gorm:"not null;check:amount > 0" json:"amount"`
+	Note           string             `gorm:"type:text" json:"note,omitempty"`
+	Frequency      ScheduleFrequency  `gorm:"not null;type:text" json:"frequency"`
+	StartAt        time.Time          `gorm:"not null" json:"startAt"` // first occurrence, anchors the recurrence
+	Status         ScheduleStatus     `gorm:"not null;type:text" json:"status"`
+	OnInsufficient InsufficientPolicy `gorm:"not null;type:text" json:"onInsufficient"`
+	MaxRetries     int                `gorm:"not null;default:0" json:"maxRetries"`
+	Occurrence     int                `gorm:"not null;default:0" json:"occurrence"` // index of the next occurrence
+	Attempt        int                `gorm:"not null;default:0" json:"attempt"`    // retries used for the next occurrence
+	NextRunAt      *time.Time         `gorm:"index:idx_schedules_next_run" json:"nextRunAt,omitempty"`
+	LastRunAt      *time.Time         `json:"lastRunAt,omitempty"`
+	CreatedAt      time.Time          `json:"createdAt"`
+	UpdatedAt      time.Time          `json:"updatedAt"`
+	DeletedAt      gorm.DeletedAt     `gorm:"index" json:"-"`
+}
+
+// ScheduledTransferRun records one execution of a scheduled transfer
+type ScheduledTransferRun struct {
+	ID           uint              `gorm:"primaryKey" json:"id"`
+	ScheduleID   uint              `gorm:"not null;index:idx_schedule_runs_schedule" json:"scheduleId"`
+	Occurrence   int               `gorm:"not null" json:"occurrence"`
+	Attempt      int               `gorm:"not null" json:"attempt"`
+	ScheduledFor time.Time         `gorm:"not null" json:"scheduledFor"`
+	Status       ScheduleRunStatus `gorm:"not null;type:text" json:"status"`
+	TransferID   *uint             `json:"transferId,omitempty"`
+	TransferKey  string            `gorm:"size:128" json:"transferKey,omitempty"` // idemKey for GET /transfers/{id}
+	Error        string            `gorm:"type:text" json:"error,omitempty"`
+	CreatedAt    time.Time         `json:"createdAt"`
+}
+
+// ScheduledTransferCreateRequest for creating a scheduled transfer
+type ScheduledTransferCreateRequest struct {
+	FromUserID     uint               `json:"fromUserId" binding:"required,min=1"`
+	ToUserID       uint               `json:"toUserId" binding:"required,min=1"`
+	Amount         int                `json:"amount" binding:"required,min=1"`
+	Note           string             `json:"note" binding:"max=512"`
+	Frequency      ScheduleFrequency  `json:"frequency" binding:"required"`
+	StartAt        time.Time          `json:"startAt"`        // defaults to now
+	OnInsufficient InsufficientPolicy `json:"onInsufficient"` // defaults to skip
+	MaxRetries     int                `json:"maxRetries"`
+}
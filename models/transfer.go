@@ -30,7 +30,9 @@ type Transfer struct {
 	CreatedAt      time.Time       `gorm:"index:idx_transfers_created" json:"createdAt"`
 	UpdatedAt      time.Time       `json:"updatedAt"`
 	CompletedAt    *time.Time      `json:"completedAt,omitempty"`
+	HoldExpiresAt  *time.Time      `gorm:"index:idx_transfers_hold_expires" json:"holdExpiresAt,omitempty"` // set only for holds
 	FailReason     string          `gorm:"type:text" json:"failReason,omitempty"`
+	Reason         string          `gorm:"type:text" json:"reason,omitempty"` // why it was cancelled/reversed
 	DeletedAt      gorm.DeletedAt  `gorm:"index" json:"-"`
 }
 
@@ -40,6 +42,54 @@ type TransferCreateRequest struct {
 	ToUserID   uint   `json:"toUserId" binding:"required,min=1"`
 	Amount     int    `json:"amount" binding:"required,min=1"`
 	Note       string `json:"note" binding:"max=512"`
+	Hold       bool   `json:"hold"` // reserve the points now, capture or void later
+
+	// IdempotencyKey is taken from the Idempotency-Key header, not the body
+	IdempotencyKey string `json:"-"`
+}
+
+// TransferBatchMode controls how a batch of transfers is applied
+type TransferBatchMode string
+
+const (
+	TransferBatchAtomic  TransferBatchMode = "atomic"  // all or nothing, one transaction
+	TransferBatchPartial TransferBatchMode = "partial" // best effort, item by item
+)
+
+// TransferBatchItem is one transfer in a batch request
+type TransferBatchItem struct {
+	FromUserID uint   `json:"fromUserId"`
+	ToUserID   uint   `json:"toUserId"`
+	Amount     int    `json:"amount"`
+	Note       string `json:"note"`
+}
+
+// TransferBatchRequest for POST /transfers/batch
+type TransferBatchRequest struct {
+	Mode  TransferBatchMode   `json:"mode"`
+	Items []TransferBatchItem `json:"items"`
+}
+
+// TransferBatchItemResult reports the outcome of one batch item
+type TransferBatchItemResult struct {
+	Index    int       `json:"index"`
+	Status   string    `json:"status"` // succeeded, failed or rolled_back
+	Transfer *Transfer `json:"transfer,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Message  string    `json:"message,omitempty"`
+}
+
+// TransferBatchResponse for POST /transfers/batch
+type TransferBatchResponse struct {
+	Mode      TransferBatchMode         `json:"mode"`
+	Succeeded int                       `json:"succeeded"`
+	Failed    int                       `json:"failed"`
+	Results   []TransferBatchItemResult `json:"results"`
+}
+
+// TransferActionRequest for cancel/reverse operations
+type TransferActionRequest struct {
+	Reason string `json:"reason" binding:"max=512"`
 }
 
 // TransferResponse wraps transfer data
@@ -8,16 +8,24 @@ import (
 
 // User represents a user in the system
 type User struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	Name      string         `gorm:"not null" json:"name"`
-	Email     string         `gorm:"unique;not null" json:"email"`
-	Phone     string         `json:"phone"`
-	Address   string         `json:"address"`
-	Avatar    string         `json:"avatar"`
-	Points    int            `gorm:"default:0;not null" json:"points"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID           uint           `gorm:"primaryKey" json:"id"`
+	Name         string         `gorm:"not null" json:"name"`
+	Email        string         `gorm:"unique;not null" json:"email"`
+	Phone        string         `json:"phone"`
+	Address      string         `json:"address"`
+	Avatar       string         `json:"avatar"`
+	Points       int            `gorm:"default:0;not null" json:"points"`
+	HeldPoints   int            `gorm:"default:0;not null" json:"heldPoints"` // reserved by pending holds
+	PasswordHash string         `json:"-"`                                    // bcrypt, empty for users that cannot log in
+	Role         Role           `gorm:"size:16;not null;default:member" json:"role"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// AvailablePoints returns the points that are not reserved by pending holds
+func (u *User) AvailablePoints() int {
+	return u.Points - u.HeldPoints
 }
 
 // UserInput for create/update operations (without ID and timestamps)
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// WebhookEvent is a transfer lifecycle event delivered to subscribers
+type WebhookEvent string
+
+const (
+	WebhookTransferCompleted WebhookEvent = "transfer.completed"
+	WebhookTransferFailed    WebhookEvent = "transfer.failed"
+	WebhookTransferReversed  WebhookEvent = "transfer.reversed"
+	WebhookTransferCancelled WebhookEvent = "transfer.cancelled"
+)
+
+// Valid reports whether e is a known event
+func (e WebhookEvent) Valid() bool {
+	switch e {
+	case WebhookTransferCompleted, WebhookTransferFailed, WebhookTransferReversed, WebhookTransferCancelled:
+		return true
+	}
+	return false
+}
+
+// WebhookSubscription sends transfer events to a URL. The secret signs each
+// delivery, so unlike API keys it is stored as is.
+type WebhookSubscription struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	OwnerID   uint           `gorm:"not null;index:idx_webhook_subscriptions_owner" json:"ownerId"`
+	URL       string         `gorm:"not null" json:"url"`
+	Secret    string         `gorm:"not null" json:"-"`
+	Events    []WebhookEvent `gorm:"serializer:json;not null" json:"events"` // empty means all events
+	AllUsers  bool           `gorm:"not null;default:false" json:"allUsers"` // requested every user's transfers; honoured while the owner's role allows it
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// Wants reports whether the subscription's filter includes event
+func (s *WebhookSubscription) Wants(event WebhookEvent) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, wanted := range s.Events {
+		if wanted == event {
+			return true
+		}
+	}
+	return false
+}
+
+// OutboxEvent is a transfer event written in the same transaction as the
+// change it describes. The delivery worker fans it out to subscriptions.
+type OutboxEvent struct {
+	ID           uint         `gorm:"primaryKey" json:"id"`
+	EventType    WebhookEvent `gorm:"not null;type:text" json:"eventType"`
+	TransferID   uint         `gorm:"not null" json:"transferId"`
+	FromUserID   uint         `gorm:"not null" json:"fromUserId"`
+	ToUserID     uint         `gorm:"not null" json:"toUserId"`
+	Payload      string       `gorm:"type:text;not null" json:"payload"` // JSON body sent to subscribers
+	DispatchedAt *time.Time   `gorm:"index:idx_outbox_events_dispatched" json:"dispatchedAt,omitempty"`
+	CreatedAt    time.Time    `json:"createdAt"`
+}
+
+// DeliveryStatus represents the state of a webhook delivery
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryDead      DeliveryStatus = "dead" // gave up after the last retry
+)
+
+// WebhookDelivery is one outbox event on its way to one subscription
+type WebhookDelivery struct {
+	ID             uint           `gorm:"primaryKey" json:"id"`
+	SubscriptionID uint           `gorm:"not null;index:idx_webhook_deliveries_subscription" json:"subscriptionId"`
+	EventID        uint           `gorm:"not null" json:"eventId"`
+	EventType      WebhookEvent   `gorm:"not null;type:text" json:"eventType"`
+	Status         DeliveryStatus `gorm:"not null;size:16;index:idx_webhook_deliveries_due,priority:1" json:"status"`
+	Attempts       int            `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt  time.Time      `gorm:"index:idx_webhook_deliveries_due,priority:2" json:"nextAttemptAt"`
+	LastStatusCode int            `json:"lastStatusCode,omitempty"`
+	LastError      string         `gorm:"type:text" json:"lastError,omitempty"`
+	DeliveredAt    *time.Time     `json:"deliveredAt,omitempty"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	UpdatedAt      time.Time      `json:"updatedAt"`
+}
+
+// WebhookPayload is the JSON body of a delivery
+type WebhookPayload struct {
+	ID        uint         `json:"id"` // outbox event ID, stable across retries
+	Type      WebhookEvent `json:"type"`
+	CreatedAt time.Time    `json:"createdAt"`
+	Transfer  *Transfer    `json:"transfer"`
+}
+
+// WebhookCreateRequest for POST /webhooks
+type WebhookCreateRequest struct {
+	URL    string         `json:"url"`
+	Secret string         `json:"secret"` // generated when empty
+	Events []WebhookEvent `json:"events"`
+}
+
+// WebhookCreateResponse returns the signing secret, which is shown only once
+type WebhookCreateResponse struct {
+	Subscription *WebhookSubscription `json:"subscription"`
+	Secret       string               `json:"secret"`
+}
@@ -0,0 +1,332 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"class-go-ai/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GormStore is the Store backed by a GORM database
+type GormStore struct {
+	db *gorm.DB
+}
+
+// NewGormStore creates a store on db, which may also be an open transaction
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{db: db}
+}
+
+// DB returns the underlying database handle
+func (s *GormStore) DB() *gorm.DB {
+	return s.db
+}
+
+func (s *GormStore) Users() UserRepository         { return gormUsers{s.db} }
+func (s *GormStore) Transfers() TransferRepository { return gormTransfers{s.db} }
+func (s *GormStore) Ledger() LedgerRepository      { return gormLedger{s.db} }
+func (s *GormStore) Outbox() OutboxRepository      { return gormOutbox{s.db} }
+func (s *GormStore) Context() context.Context      { return s.db.Statement.Context }
+
+func (s *GormStore) RefreshTokens() RefreshTokenRepository { return gormRefreshTokens{s.db} }
+func (s *GormStore) APIKeys() APIKeyRepository             { return gormAPIKeys{s.db} }
+
+// Transaction runs fn in a database transaction
+func (s *GormStore) Transaction(ctx context.Context, fn func(tx Store) error) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(NewGormStore(tx))
+	})
+}
+
+// ForUpdate makes a query inside a transaction lock the rows it reads
+// (SELECT ... FOR UPDATE) until commit, so balance checks cannot race.
+// SQLite has no row locks; its writers are serialized on the whole database.
+func ForUpdate(tx *gorm.DB) *gorm.DB {
+	if tx.Dialector.Name() == "sqlite" {
+		return tx
+	}
+	return tx.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate})
+}
+
+// notFound turns GORM's not found error into ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+type gormUsers struct{ db *gorm.DB }
+
+func (r gormUsers) List() ([]models.User, error) {
+	var users []models.User
+	err := r.db.Order("id DESC").Find(&users).Error
+	return users, err
+}
+
+func (r gormUsers) FindByID(id uint) (*models.User, error) {
+	var user models.User
+	if err := r.db.First(&user, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (r gormUsers) FindByEmail(email string) (*models.User, error) {
+	var user models.User
+	if err := r.db.Unscoped().Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (r gormUsers) LockByIDs(ids ...uint) ([]models.User, error) {
+	// Rows are locked in id order, so transactions locking the same users
+	// in a different order cannot deadlock
+	var users []models.User
+	err := ForUpdate(r.db).Where("id IN ?", ids).Order("id").Find(&users).Error
+	return users, err
+}
+
+func (r gormUsers) CountByRole(role models.Role) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.User{}).Where("role = ?", role).Count(&count).Error
+	return count, err
+}
+
+func (r gormUsers) Create(user *models.User) error {
+	return r.db.Create(user).Error
+}
+
+func (r gormUsers) Save(user *models.User) error {
+	return r.db.Save(user).Error
+}
+
+func (r gormUsers) UpdateProfile(user *models.User) error {
+	return r.db.Model(user).Select("name", "email", "phone", "address", "avatar").Updates(user).Error
+}
+
+func (r gormUsers) Delete(id uint) error {
+	return r.db.Delete(&models.User{}, id).Error
+}
+
+type gormTransfers struct{ db *gorm.DB }
+
+func (r gormTransfers) Create(transfer *models.Transfer) error {
+	return r.db.Create(transfer).Error
+}
+
+func (r gormTransfers) Save(transfer *models.Transfer) error {
+	return r.db.Save(transfer).Error
+}
+
+func (r gormTransfers) FindByIdemKey(key string) (*models.Transfer, error) {
+	var transfer models.Transfer
+	if err := r.db.Where("idempotency_key = ?", key).First(&transfer).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &transfer, nil
+}
+
+func (r gormTransfers) LockByIdemKey(key string) (*models.Transfer, error) {
+	return gormTransfers{ForUpdate(r.db)}.FindByIdemKey(key)
+}
+
+func (r gormTransfers) ListByUser(userID uint, offset, limit int) ([]models.Transfer, int64, error) {
+	var total int64
+	var transfers []models.Transfer
+
+	if err := r.db.Model(&models.Transfer{}).
+		Where("from_user_id = ? OR to_user_id = ?", userID, userID).
+		Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := r.db.Where("from_user_id = ? OR to_user_id = ?", userID, userID).
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&transfers).Error
+	return transfers, total, err
+}
+
+func (r gormTransfers) ExpiredHoldKeys(now time.Time) ([]string, error) {
+	var keys []string
+	err := r.db.Model(&models.Transfer{}).
+		Where("status = ? AND hold_expires_at IS NOT NULL AND hold_expires_at <= ?", models.TransferStatusPending, now).
+		Pluck("idempotency_key", &keys).Error
+	return keys, err
+}
+
+type gormLedger struct{ db *gorm.DB }
+
+func (r gormLedger) Create(entry *models.PointLedger) error {
+	return r.db.Create(entry).Error
+}
+
+func (r gormLedger) Last() (*models.PointLedger, error) {
+	var entry models.PointLedger
+	if err := r.db.Order("id DESC").First(&entry).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &entry, nil
+}
+
+func (r gormLedger) LockHead() (string, error) {
+	var head models.LedgerHead
+	err := ForUpdate(r.db).First(&head, models.LedgerHeadID).Error
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return head.Hash, err
+	}
+
+	// No head yet: create it at the newest entry. A concurrent creator wins
+	// the primary key and this one locks the row it committed.
+	head = models.LedgerHead{ID: models.LedgerHeadID}
+	last, err := r.Last()
+	switch {
+	case err == nil:
+		head.EntryID, head.Hash = last.ID, last.Hash
+	case !errors.Is(err, ErrNotFound):
+		return "", err
+	}
+	if err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&head).Error; err != nil {
+		return "", err
+	}
+	err = ForUpdate(r.db).First(&head, models.LedgerHeadID).Error
+	return head.Hash, err
+}
+
+func (r gormLedger) MoveHead(prev string, entry *models.PointLedger) error {
+	// The head is locked by LockHead, so this only fails for a writer that
+	// skipped the lock and chained onto an older head
+	result := r.db.Model(&models.LedgerHead{}).
+		Where("id = ? AND hash = ?", models.LedgerHeadID, prev).
+		Updates(map[string]any{"entry_id": entry.ID, "hash": entry.Hash})
+	if result.Error == nil && result.RowsAffected != 1 {
+		return ErrChainForked
+	}
+	return result.Error
+}
+
+func (r gormLedger) List(filter LedgerFilter) ([]models.PointLedger, error) {
+	query := r.db.Where("user_id = ?", filter.UserID)
+	if filter.EventType != "" {
+		query = query.Where("event_type = ?", filter.EventType)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+	if filter.TransferID != nil {
+		query = query.Where("transfer_id = ?", *filter.TransferID)
+	}
+	if filter.AfterID > 0 {
+		query = query.Where("id > ?", filter.AfterID)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	var entries []models.PointLedger
+	err := query.Order("id").Find(&entries).Error
+	return entries, err
+}
+
+func (r gormLedger) LastForUser(userID uint, asOf *time.Time) (*models.PointLedger, error) {
+	query := r.db.Where("user_id = ?", userID)
+	if asOf != nil {
+		query = query.Where("created_at <= ?", *asOf)
+	}
+
+	var entry models.PointLedger
+	if err := query.Order("id DESC").First(&entry).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &entry, nil
+}
+
+func (r gormLedger) Walk(batchSize int, fn func(entries []models.PointLedger) error) error {
+	var batch []models.PointLedger
+	return r.db.Order("id").FindInBatches(&batch, batchSize, func(*gorm.DB, int) error {
+		return fn(batch)
+	}).Error
+}
+
+type gormOutbox struct{ db *gorm.DB }
+
+func (r gormOutbox) Create(event *models.OutboxEvent) error {
+	return r.db.Create(event).Error
+}
+
+func (r gormOutbox) Save(event *models.OutboxEvent) error {
+	return r.db.Save(event).Error
+}
+
+type gormRefreshTokens struct{ db *gorm.DB }
+
+func (r gormRefreshTokens) Create(token *models.RefreshToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r gormRefreshTokens) FindByHash(hash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := r.db.Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &token, nil
+}
+
+func (r gormRefreshTokens) Revoke(hash string, at time.Time) (bool, error) {
+	result := r.db.Model(&models.RefreshToken{}).
+		Where("token_hash = ? AND revoked_at IS NULL", hash).
+		Update("revoked_at", at)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r gormRefreshTokens) RevokeForUser(userID uint, at time.Time) error {
+	return r.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", at).Error
+}
+
+type gormAPIKeys struct{ db *gorm.DB }
+
+func (r gormAPIKeys) Create(key *models.APIKey) error {
+	return r.db.Create(key).Error
+}
+
+func (r gormAPIKeys) FindByID(id uint) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.First(&key, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &key, nil
+}
+
+func (r gormAPIKeys) FindByHash(hash string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.Where("key_hash = ?", hash).First(&key).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &key, nil
+}
+
+func (r gormAPIKeys) ListByOwner(ownerID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := r.db.Where("owner_id = ?", ownerID).Order("id DESC").Find(&keys).Error
+	return keys, err
+}
+
+func (r gormAPIKeys) Revoke(id uint, at time.Time) error {
+	return r.db.Model(&models.APIKey{}).Where("id = ?", id).Update("revoked_at", at).Error
+}
+
+func (r gormAPIKeys) Touch(id uint, at time.Time) error {
+	return r.db.Model(&models.APIKey{}).Where("id = ?", id).UpdateColumn("last_used_at", at).Error
+}
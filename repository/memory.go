@@ -0,0 +1,707 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"class-go-ai/models"
+)
+
+// Fault decides whether the store operation op fails. op names the
+// repository and method, such as "Transfers.Create" or "Users.LockByIDs",
+// or is "Commit" for the end of a transaction. A nil error lets it run.
+type Fault func(op string) error
+
+// FailOn returns a Fault failing every op operation with err
+func FailOn(op string, err error) Fault {
+	return func(called string) error {
+		if called == op {
+			return err
+		}
+		return nil
+	}
+}
+
+// MemoryStore is a Store keeping everything in memory, for tests that
+// should not need a database. It is safe for concurrent use: transactions
+// run one at a time on a copy of the data, which replaces the data on
+// commit and is dropped on rollback. Calling the MemoryStore itself from
+// inside one of its transactions blocks forever.
+type MemoryStore struct {
+	mu   sync.Mutex // held by every operation and for a whole transaction
+	data *memoryData
+
+	faultMu sync.RWMutex
+	fault   Fault
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: &memoryData{
+		users:     map[uint]models.User{},
+		transfers: map[uint]models.Transfer{},
+		outbox:    map[uint]models.OutboxEvent{},
+
+		refreshTokens: map[uint]models.RefreshToken{},
+		apiKeys:       map[uint]models.APIKey{},
+	}}
+}
+
+// SetFault makes the store consult fault before every operation; nil
+// removes it
+func (s *MemoryStore) SetFault(fault Fault) {
+	s.faultMu.Lock()
+	defer s.faultMu.Unlock()
+	s.fault = fault
+}
+
+func (s *MemoryStore) fail(op string) error {
+	s.faultMu.RLock()
+	defer s.faultMu.RUnlock()
+	if s.fault == nil {
+		return nil
+	}
+	return s.fault(op)
+}
+
+// access runs a single operation outside any transaction
+func (s *MemoryStore) access(op string, fn func(d *memoryData) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.fail(op); err != nil {
+		return err
+	}
+	return fn(s.data)
+}
+
+func (s *MemoryStore) Users() UserRepository         { return memoryUsers{s.access} }
+func (s *MemoryStore) Transfers() TransferRepository { return memoryTransfers{s.access} }
+func (s *MemoryStore) Ledger() LedgerRepository      { return memoryLedger{s.access} }
+func (s *MemoryStore) Outbox() OutboxRepository      { return memoryOutbox{s.access} }
+func (s *MemoryStore) Context() context.Context      { return context.Background() }
+
+func (s *MemoryStore) RefreshTokens() RefreshTokenRepository { return memoryRefreshTokens{s.access} }
+func (s *MemoryStore) APIKeys() APIKeyRepository             { return memoryAPIKeys{s.access} }
+
+// Transaction runs fn on a copy of the data and keeps the copy if fn and
+// the commit succeed
+func (s *MemoryStore) Transaction(ctx context.Context, fn func(tx Store) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &memoryTx{store: s, data: s.data.clone(), ctx: ctx}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := s.fail("Commit"); err != nil {
+		return err
+	}
+	s.data = tx.data
+	return nil
+}
+
+// memoryTx is the Store handed to a MemoryStore transaction
+type memoryTx struct {
+	store *MemoryStore
+	data  *memoryData
+	ctx   context.Context
+}
+
+func (t *memoryTx) access(op string, fn func(d *memoryData) error) error {
+	if err := t.store.fail(op); err != nil {
+		return err
+	}
+	return fn(t.data)
+}
+
+func (t *memoryTx) Users() UserRepository         { return memoryUsers{t.access} }
+func (t *memoryTx) Transfers() TransferRepository { return memoryTransfers{t.access} }
+func (t *memoryTx) Ledger() LedgerRepository      { return memoryLedger{t.access} }
+func (t *memoryTx) Outbox() OutboxRepository      { return memoryOutbox{t.access} }
+func (t *memoryTx) Context() context.Context      { return t.ctx }
+
+func (t *memoryTx) RefreshTokens() RefreshTokenRepository { return memoryRefreshTokens{t.access} }
+func (t *memoryTx) APIKeys() APIKeyRepository             { return memoryAPIKeys{t.access} }
+
+// Transaction nests like a savepoint: a failing fn undoes only its own changes
+func (t *memoryTx) Transaction(ctx context.Context, fn func(tx Store) error) error {
+	nested := &memoryTx{store: t.store, data: t.data.clone(), ctx: ctx}
+	if err := fn(nested); err != nil {
+		return err
+	}
+	t.data = nested.data
+	return nil
+}
+
+// memoryData is one version of everything the store holds. Records are kept
+// by value, so callers only change the store through the repositories.
+type memoryData struct {
+	users     map[uint]models.User
+	transfers map[uint]models.Transfer
+	ledger    []models.PointLedger // in id order
+	outbox    map[uint]models.OutboxEvent
+	head      *models.LedgerHead // nil until the first MoveHead
+
+	refreshTokens map[uint]models.RefreshToken
+	apiKeys       map[uint]models.APIKey
+
+	lastUserID, lastTransferID, lastLedgerID, lastOutboxID uint
+	lastRefreshTokenID, lastAPIKeyID                       uint
+}
+
+func (d *memoryData) clone() *memoryData {
+	c := *d
+	c.users = maps.Clone(d.users)
+	c.transfers = maps.Clone(d.transfers)
+	c.ledger = slices.Clone(d.ledger)
+	c.outbox = maps.Clone(d.outbox)
+	c.refreshTokens = maps.Clone(d.refreshTokens)
+	c.apiKeys = maps.Clone(d.apiKeys)
+	return &c
+}
+
+// nextID assigns the next id to a record created with id 0, as an
+// auto-increment column would
+func nextID(id *uint, last *uint) {
+	if *id == 0 {
+		*last++
+		*id = *last
+	} else if *id > *last {
+		*last = *id
+	}
+}
+
+// touch sets the timestamps GORM fills in on save
+func touch(createdAt, updatedAt *time.Time) {
+	now := time.Now()
+	if createdAt.IsZero() {
+		*createdAt = now
+	}
+	*updatedAt = now
+}
+
+type memoryUsers struct {
+	access func(op string, fn func(d *memoryData) error) error
+}
+
+func (r memoryUsers) List() ([]models.User, error) {
+	var users []models.User
+	err := r.access("Users.List", func(d *memoryData) error {
+		users = slices.SortedFunc(maps.Values(d.users), func(a, b models.User) int {
+			return cmp.Compare(b.ID, a.ID)
+		})
+		return nil
+	})
+	return users, err
+}
+
+func (r memoryUsers) FindByID(id uint) (*models.User, error) {
+	var user models.User
+	err := r.access("Users.FindByID", func(d *memoryData) error {
+		found, ok := d.users[id]
+		if !ok {
+			return ErrNotFound
+		}
+		user = found
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r memoryUsers) FindByEmail(email string) (*models.User, error) {
+	var user models.User
+	err := r.access("Users.FindByEmail", func(d *memoryData) error {
+		for _, found := range d.users {
+			if found.Email == email {
+				user = found
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r memoryUsers) LockByIDs(ids ...uint) ([]models.User, error) {
+	// Transactions already run one at a time, so reading is locking
+	var users []models.User
+	err := r.access("Users.LockByIDs", func(d *memoryData) error {
+		for _, id := range slices.Sorted(slices.Values(ids)) {
+			if user, ok := d.users[id]; ok {
+				users = append(users, user)
+			}
+		}
+		return nil
+	})
+	return users, err
+}
+
+func (r memoryUsers) CountByRole(role models.Role) (int64, error) {
+	var count int64
+	err := r.access("Users.CountByRole", func(d *memoryData) error {
+		for _, user := range d.users {
+			if user.Role == role {
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (r memoryUsers) Create(user *models.User) error {
+	return r.access("Users.Create", func(d *memoryData) error {
+		return d.putUser(user, true)
+	})
+}
+
+func (r memoryUsers) Save(user *models.User) error {
+	return r.access("Users.Save", func(d *memoryData) error {
+		return d.putUser(user, user.ID == 0)
+	})
+}
+
+func (r memoryUsers) UpdateProfile(user *models.User) error {
+	return r.access("Users.UpdateProfile", func(d *memoryData) error {
+		stored, ok := d.users[user.ID]
+		if !ok {
+			// Like an UPDATE matching no row
+			return nil
+		}
+		stored.Name, stored.Email, stored.Phone = user.Name, user.Email, user.Phone
+		stored.Address, stored.Avatar = user.Address, user.Avatar
+		if err := d.putUser(&stored, false); err != nil {
+			return err
+		}
+		user.UpdatedAt = stored.UpdatedAt
+		return nil
+	})
+}
+
+func (r memoryUsers) Delete(id uint) error {
+	return r.access("Users.Delete", func(d *memoryData) error {
+		delete(d.users, id)
+		return nil
+	})
+}
+
+// putUser inserts or replaces user, keeping emails unique
+func (d *memoryData) putUser(user *models.User, create bool) error {
+	if _, ok := d.users[user.ID]; create && ok {
+		return ErrDuplicateKey
+	}
+	for id, other := range d.users {
+		if id != user.ID && other.Email == user.Email {
+			return ErrDuplicateKey
+		}
+	}
+
+	if create {
+		nextID(&user.ID, &d.lastUserID)
+		if user.Role == "" {
+			user.Role = models.RoleMember
+		}
+	}
+	touch(&user.CreatedAt, &user.UpdatedAt)
+	d.users[user.ID] = *user
+	return nil
+}
+
+type memoryTransfers struct {
+	access func(op string, fn func(d *memoryData) error) error
+}
+
+func (r memoryTransfers) Create(transfer *models.Transfer) error {
+	return r.access("Transfers.Create", func(d *memoryData) error {
+		return d.putTransfer(transfer, true)
+	})
+}
+
+func (r memoryTransfers) Save(transfer *models.Transfer) error {
+	return r.access("Transfers.Save", func(d *memoryData) error {
+		return d.putTransfer(transfer, transfer.ID == 0)
+	})
+}
+
+func (r memoryTransfers) FindByIdemKey(key string) (*models.Transfer, error) {
+	return r.find("Transfers.FindByIdemKey", key)
+}
+
+func (r memoryTransfers) LockByIdemKey(key string) (*models.Transfer, error) {
+	return r.find("Transfers.LockByIdemKey", key)
+}
+
+func (r memoryTransfers) find(op, key string) (*models.Transfer, error) {
+	var transfer models.Transfer
+	err := r.access(op, func(d *memoryData) error {
+		for _, stored := range d.transfers {
+			if stored.IdempotencyKey == key {
+				transfer = stored
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+func (r memoryTransfers) ListByUser(userID uint, offset, limit int) ([]models.Transfer, int64, error) {
+	var matching []models.Transfer
+	err := r.access("Transfers.ListByUser", func(d *memoryData) error {
+		for _, transfer := range d.transfers {
+			if transfer.FromUserID == userID || transfer.ToUserID == userID {
+				matching = append(matching, transfer)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	slices.SortFunc(matching, func(a, b models.Transfer) int {
+		return cmp.Or(b.CreatedAt.Compare(a.CreatedAt), cmp.Compare(b.ID, a.ID))
+	})
+	total := int64(len(matching))
+	offset = min(offset, len(matching))
+	end := min(offset+limit, len(matching))
+	return matching[offset:end], total, nil
+}
+
+func (r memoryTransfers) ExpiredHoldKeys(now time.Time) ([]string, error) {
+	var keys []string
+	err := r.access("Transfers.ExpiredHoldKeys", func(d *memoryData) error {
+		for _, transfer := range d.transfers {
+			if transfer.Status == models.TransferStatusPending && transfer.HoldExpiresAt != nil && !transfer.HoldExpiresAt.After(now) {
+				keys = append(keys, transfer.IdempotencyKey)
+			}
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// putTransfer inserts or replaces transfer, keeping idempotency keys unique
+func (d *memoryData) putTransfer(transfer *models.Transfer, create bool) error {
+	if _, ok := d.transfers[transfer.ID]; create && ok {
+		return ErrDuplicateKey
+	}
+	for id, other := range d.transfers {
+		if id != transfer.ID && other.IdempotencyKey == transfer.IdempotencyKey {
+			return ErrDuplicateKey
+		}
+	}
+
+	if create {
+		nextID(&transfer.ID, &d.lastTransferID)
+	}
+	touch(&transfer.CreatedAt, &transfer.UpdatedAt)
+	d.transfers[transfer.ID] = *transfer
+	return nil
+}
+
+type memoryLedger struct {
+	access func(op string, fn func(d *memoryData) error) error
+}
+
+func (r memoryLedger) Create(entry *models.PointLedger) error {
+	return r.access("Ledger.Create", func(d *memoryData) error {
+		if entry.ID != 0 {
+			return ErrDuplicateKey
+		}
+		nextID(&entry.ID, &d.lastLedgerID)
+		if entry.CreatedAt.IsZero() {
+			entry.CreatedAt = time.Now()
+		}
+		d.ledger = append(d.ledger, *entry)
+		return nil
+	})
+}
+
+func (r memoryLedger) Last() (*models.PointLedger, error) {
+	var entry models.PointLedger
+	err := r.access("Ledger.Last", func(d *memoryData) error {
+		if len(d.ledger) == 0 {
+			return ErrNotFound
+		}
+		entry = d.ledger[len(d.ledger)-1]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (r memoryLedger) LockHead() (string, error) {
+	// Transactions hold the store for their whole run, so nothing to lock
+	var hash string
+	err := r.access("Ledger.LockHead", func(d *memoryData) error {
+		switch {
+		case d.head != nil:
+			hash = d.head.Hash
+		case len(d.ledger) > 0:
+			hash = d.ledger[len(d.ledger)-1].Hash
+		}
+		return nil
+	})
+	return hash, err
+}
+
+func (r memoryLedger) MoveHead(prev string, entry *models.PointLedger) error {
+	return r.access("Ledger.MoveHead", func(d *memoryData) error {
+		if d.head != nil && d.head.Hash != prev {
+			return ErrChainForked
+		}
+		d.head = &models.LedgerHead{ID: models.LedgerHeadID, EntryID: entry.ID, Hash: entry.Hash}
+		return nil
+	})
+}
+
+func (r memoryLedger) List(filter LedgerFilter) ([]models.PointLedger, error) {
+	var entries []models.PointLedger
+	err := r.access("Ledger.List", func(d *memoryData) error {
+		for _, entry := range d.ledger {
+			if filter.Limit > 0 && len(entries) == filter.Limit {
+				break
+			}
+			if entry.UserID != filter.UserID ||
+				(filter.EventType != "" && entry.EventType != filter.EventType) ||
+				(filter.From != nil && entry.CreatedAt.Before(*filter.From)) ||
+				(filter.To != nil && !entry.CreatedAt.Before(*filter.To)) ||
+				(filter.TransferID != nil && (entry.TransferID == nil || *entry.TransferID != *filter.TransferID)) ||
+				entry.ID <= filter.AfterID {
+				continue
+			}
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+func (r memoryLedger) LastForUser(userID uint, asOf *time.Time) (*models.PointLedger, error) {
+	var entry models.PointLedger
+	err := r.access("Ledger.LastForUser", func(d *memoryData) error {
+		for i := len(d.ledger) - 1; i >= 0; i-- {
+			candidate := d.ledger[i]
+			if candidate.UserID == userID && (asOf == nil || !candidate.CreatedAt.After(*asOf)) {
+				entry = candidate
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (r memoryLedger) Walk(batchSize int, fn func(entries []models.PointLedger) error) error {
+	// Copy first so fn runs without holding the store
+	var all []models.PointLedger
+	if err := r.access("Ledger.Walk", func(d *memoryData) error {
+		all = slices.Clone(d.ledger)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for batch := range slices.Chunk(all, max(batchSize, 1)) {
+		if err := fn(batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type memoryOutbox struct {
+	access func(op string, fn func(d *memoryData) error) error
+}
+
+func (r memoryOutbox) Create(event *models.OutboxEvent) error {
+	return r.access("Outbox.Create", func(d *memoryData) error {
+		if _, ok := d.outbox[event.ID]; ok {
+			return ErrDuplicateKey
+		}
+		nextID(&event.ID, &d.lastOutboxID)
+		if event.CreatedAt.IsZero() {
+			event.CreatedAt = time.Now()
+		}
+		d.outbox[event.ID] = *event
+		return nil
+	})
+}
+
+func (r memoryOutbox) Save(event *models.OutboxEvent) error {
+	return r.access("Outbox.Save", func(d *memoryData) error {
+		nextID(&event.ID, &d.lastOutboxID)
+		if event.CreatedAt.IsZero() {
+			event.CreatedAt = time.Now()
+		}
+		d.outbox[event.ID] = *event
+		return nil
+	})
+}
+
+type memoryRefreshTokens struct {
+	access func(op string, fn func(d *memoryData) error) error
+}
+
+func (r memoryRefreshTokens) Create(token *models.RefreshToken) error {
+	return r.access("RefreshTokens.Create", func(d *memoryData) error {
+		for id, other := range d.refreshTokens {
+			if id == token.ID || other.TokenHash == token.TokenHash {
+				return ErrDuplicateKey
+			}
+		}
+		nextID(&token.ID, &d.lastRefreshTokenID)
+		if token.CreatedAt.IsZero() {
+			token.CreatedAt = time.Now()
+		}
+		d.refreshTokens[token.ID] = *token
+		return nil
+	})
+}
+
+func (r memoryRefreshTokens) FindByHash(hash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := r.access("RefreshTokens.FindByHash", func(d *memoryData) error {
+		for _, stored := range d.refreshTokens {
+			if stored.TokenHash == hash {
+				token = stored
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r memoryRefreshTokens) Revoke(hash string, at time.Time) (bool, error) {
+	var revoked bool
+	err := r.access("RefreshTokens.Revoke", func(d *memoryData) error {
+		for id, stored := range d.refreshTokens {
+			if stored.TokenHash == hash && stored.RevokedAt == nil {
+				stored.RevokedAt = &at
+				d.refreshTokens[id] = stored
+				revoked = true
+			}
+		}
+		return nil
+	})
+	return revoked, err
+}
+
+func (r memoryRefreshTokens) RevokeForUser(userID uint, at time.Time) error {
+	return r.access("RefreshTokens.RevokeForUser", func(d *memoryData) error {
+		for id, stored := range d.refreshTokens {
+			if stored.UserID == userID && stored.RevokedAt == nil {
+				stored.RevokedAt = &at
+				d.refreshTokens[id] = stored
+			}
+		}
+		return nil
+	})
+}
+
+type memoryAPIKeys struct {
+	access func(op string, fn func(d *memoryData) error) error
+}
+
+func (r memoryAPIKeys) Create(key *models.APIKey) error {
+	return r.access("APIKeys.Create", func(d *memoryData) error {
+		for id, other := range d.apiKeys {
+			if id == key.ID || other.KeyHash == key.KeyHash {
+				return ErrDuplicateKey
+			}
+		}
+		nextID(&key.ID, &d.lastAPIKeyID)
+		if key.CreatedAt.IsZero() {
+			key.CreatedAt = time.Now()
+		}
+		key.Scopes = slices.Clone(key.Scopes)
+		d.apiKeys[key.ID] = *key
+		return nil
+	})
+}
+
+func (r memoryAPIKeys) FindByID(id uint) (*models.APIKey, error) {
+	return r.find("APIKeys.FindByID", func(key *models.APIKey) bool { return key.ID == id })
+}
+
+func (r memoryAPIKeys) FindByHash(hash string) (*models.APIKey, error) {
+	return r.find("APIKeys.FindByHash", func(key *models.APIKey) bool { return key.KeyHash == hash })
+}
+
+func (r memoryAPIKeys) find(op string, match func(key *models.APIKey) bool) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.access(op, func(d *memoryData) error {
+		for _, stored := range d.apiKeys {
+			if match(&stored) {
+				key = stored
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+	key.Scopes = slices.Clone(key.Scopes)
+	return &key, nil
+}
+
+func (r memoryAPIKeys) ListByOwner(ownerID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := r.access("APIKeys.ListByOwner", func(d *memoryData) error {
+		for _, key := range d.apiKeys {
+			if key.OwnerID == ownerID {
+				key.Scopes = slices.Clone(key.Scopes)
+				keys = append(keys, key)
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(keys, func(a, b models.APIKey) int {
+		return cmp.Compare(b.ID, a.ID)
+	})
+	return keys, err
+}
+
+func (r memoryAPIKeys) Revoke(id uint, at time.Time) error {
+	return r.update("APIKeys.Revoke", id, func(key *models.APIKey) { key.RevokedAt = &at })
+}
+
+func (r memoryAPIKeys) Touch(id uint, at time.Time) error {
+	return r.update("APIKeys.Touch", id, func(key *models.APIKey) { key.LastUsedAt = &at })
+}
+
+// update changes the key with id in place; a missing key is left alone, like
+// an UPDATE matching no row
+func (r memoryAPIKeys) update(op string, id uint, change func(key *models.APIKey)) error {
+	return r.access(op, func(d *memoryData) error {
+		if key, ok := d.apiKeys[id]; ok {
+			change(&key)
+			d.apiKeys[id] = key
+		}
+		return nil
+	})
+}
@@ -0,0 +1,144 @@
+// Package repository defines the storage the services work against, so
+// they can be given a database-backed store in production and a different
+// one in tests
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"class-go-ai/models"
+)
+
+var (
+	// ErrNotFound is returned when a looked up record does not exist
+	ErrNotFound = errors.New("record not found")
+	// ErrDuplicateKey is returned by the MemoryStore when a write would
+	// break a unique key; database stores return the driver's error
+	ErrDuplicateKey = errors.New("duplicate key")
+	// ErrChainForked is returned when a ledger entry chained onto a head
+	// that another transaction has moved since
+	ErrChainForked = errors.New("ledger chain head moved; another entry was appended concurrently")
+)
+
+// Store gives access to the repositories. Inside Transaction, the store
+// handed to fn reads and writes within that transaction.
+type Store interface {
+	Users() UserRepository
+	Transfers() TransferRepository
+	Ledger() LedgerRepository
+	Outbox() OutboxRepository
+	RefreshTokens() RefreshTokenRepository
+	APIKeys() APIKeyRepository
+
+	// Transaction runs fn in a transaction carrying ctx. It commits if fn
+	// returns nil and rolls back every change otherwise.
+	Transaction(ctx context.Context, fn func(tx Store) error) error
+
+	// Context returns the context the store was bound to by Transaction
+	Context() context.Context
+}
+
+// UserRepository stores users
+type UserRepository interface {
+	// List returns every user, newest first
+	List() ([]models.User, error)
+	FindByID(id uint) (*models.User, error)
+	// FindByEmail returns the user with email, deleted ones included since
+	// their email stays taken
+	FindByEmail(email string) (*models.User, error)
+	// LockByIDs loads the users with the given ids in id order and locks
+	// them until the transaction ends. Missing ids are left out.
+	LockByIDs(ids ...uint) ([]models.User, error)
+	// CountByRole returns how many users have role
+	CountByRole(role models.Role) (int64, error)
+	Create(user *models.User) error
+	// Save writes every column of user
+	Save(user *models.User) error
+	// UpdateProfile writes the name, email, phone, address and avatar only
+	UpdateProfile(user *models.User) error
+	Delete(id uint) error
+}
+
+// TransferRepository stores transfers. Idempotency keys are unique; Create
+// fails for a key that is already used.
+type TransferRepository interface {
+	Create(transfer *models.Transfer) error
+	Save(transfer *models.Transfer) error
+	FindByIdemKey(key string) (*models.Transfer, error)
+	// LockByIdemKey is FindByIdemKey, locking the transfer until the
+	// transaction ends
+	LockByIdemKey(key string) (*models.Transfer, error)
+	// ListByUser returns a page of the transfers a user sent or received,
+	// newest first, and how many there are in total
+	ListByUser(userID uint, offset, limit int) ([]models.Transfer, int64, error)
+	// ExpiredHoldKeys returns the keys of pending holds that expired at or
+	// before now
+	ExpiredHoldKeys(now time.Time) ([]string, error)
+}
+
+// LedgerFilter selects ledger entries; zero fields do not filter
+type LedgerFilter struct {
+	UserID     uint
+	EventType  models.EventType
+	From       *time.Time // created_at >= From
+	To         *time.Time // created_at < To
+	TransferID *uint
+	AfterID    uint // only entries with id > AfterID
+	Limit      int
+}
+
+// LedgerRepository stores the append-only point ledger
+type LedgerRepository interface {
+	Create(entry *models.PointLedger) error
+	// Last returns the newest entry of the whole ledger
+	Last() (*models.PointLedger, error)
+	// LockHead returns the hash new entries chain onto and locks the chain
+	// head until the transaction ends, so appends run one at a time and in
+	// id order. A missing head is created at the newest entry.
+	LockHead() (string, error)
+	// MoveHead makes entry the chain head if the head is still prev, and
+	// returns ErrChainForked otherwise
+	MoveHead(prev string, entry *models.PointLedger) error
+	// List returns the entries matching filter in id order
+	List(filter LedgerFilter) ([]models.PointLedger, error)
+	// LastForUser returns a user's newest entry, written at or before asOf
+	// if it is set
+	LastForUser(userID uint, asOf *time.Time) (*models.PointLedger, error)
+	// Walk calls fn with every entry in id order, batchSize at a time,
+	// stopping at the first error
+	Walk(batchSize int, fn func(entries []models.PointLedger) error) error
+}
+
+// OutboxRepository stores webhook events until they are dispatched
+type OutboxRepository interface {
+	Create(event *models.OutboxEvent) error
+	Save(event *models.OutboxEvent) error
+}
+
+// RefreshTokenRepository stores refresh tokens by the hash of their value,
+// which is unique
+type RefreshTokenRepository interface {
+	Create(token *models.RefreshToken) error
+	FindByHash(hash string) (*models.RefreshToken, error)
+	// Revoke revokes the token with hash at at if it is still open, and
+	// reports whether it was
+	Revoke(hash string, at time.Time) (bool, error)
+	// RevokeForUser revokes every open token of a user at at
+	RevokeForUser(userID uint, at time.Time) error
+}
+
+// APIKeyRepository stores API keys by the hash of their value, which is
+// unique
+type APIKeyRepository interface {
+	Create(key *models.APIKey) error
+	FindByID(id uint) (*models.APIKey, error)
+	FindByHash(hash string) (*models.APIKey, error)
+	// ListByOwner returns the keys owned by a user, newest first
+	ListByOwner(ownerID uint) ([]models.APIKey, error)
+	// Revoke sets the revocation time of a key, leaving its other columns
+	Revoke(id uint, at time.Time) error
+	// Touch sets the time a key was last used, leaving its other columns
+	Touch(id uint, at time.Time) error
+}
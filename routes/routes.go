@@ -1,13 +1,31 @@
 package routes
 
 import (
+	"strings"
+
+	"class-go-ai/container"
 	"class-go-ai/handlers"
+	"class-go-ai/middleware"
+	"class-go-ai/models"
 
+	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cors"
 )
 
-// SetupRoutes configures all application routes
-func SetupRoutes(app *fiber.App) {
+// SetupRoutes configures all application routes on the services of c
+func SetupRoutes(app *fiber.App, c *container.Container) {
+	cfg := c.Config
+
+	// Every request below is timed under its route pattern
+	app.Use(c.Metrics.Middleware())
+
+	// Browsers may only call the API from the configured origins
+	app.Use(cors.New(cors.Config{
+		AllowOrigins:     strings.Join(cfg.CORS.AllowOrigins, ","),
+		AllowCredentials: cfg.CORS.AllowCredentials,
+	}))
+
 	// Root endpoint
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{
@@ -15,15 +33,111 @@ func SetupRoutes(app *fiber.App) {
 		})
 	})
 
+	// Probes, build details and metrics, open to the orchestrator and the
+	// scraper without a token
+	health := handlers.NewHealthHandler(c.DB, c.Workers, &c.Draining)
+	app.Get("/healthz", health.Healthz)
+	app.Get("/readyz", health.Readyz)
+	app.Get("/version", health.Version)
+	app.Get("/metrics", c.Metrics.Handler())
+
+	userHandler := handlers.NewUserHandler(c.Store.Users())
+	authHandler := handlers.NewAuthHandler(c.Auth)
+	pointsHandler := handlers.NewPointsHandler(c.Points)
+	ledgerHandler := handlers.NewLedgerHandler(c.Ledger)
+	eventsHandler := handlers.NewEventsHandler(c.Broker, c.Ledger)
+	transferHandler := handlers.NewTransferHandler(c.Transfers)
+	socketHandler := handlers.NewSocketHandler(c.Auth, c.Broker)
+	apiKeyHandler := handlers.NewAPIKeyHandler(c.APIKeys)
+	webhookHandler := handlers.NewWebhookHandler(c.Webhooks)
+	scheduleHandler := handlers.NewScheduleHandler(c.Schedules)
+	adminHandler := handlers.NewAdminHandler(c.Reconcile, c.Ledger, c.Auth)
+
+	// Auth routes
+	app.Post("/auth/register", authHandler.Register)
+	app.Post("/auth/login", authHandler.Login)
+	app.Post("/auth/refresh", authHandler.Refresh)
+	app.Post("/auth/logout", authHandler.Logout)
+
+	// Everything below requires a bearer access token. Routes on a user :id
+	// are open to that user, everyone else needs the listed permission.
+	// Routes declared withKey also accept an X-API-Key granted that scope.
+	requireAuth := middleware.RequireAuth(c.Auth)
+	withKey := func(scope models.APIKeyScope) fiber.Handler {
+		return middleware.RequireAuthOrAPIKey(c.Auth, c.APIKeys, scope)
+	}
+
 	// User routes
-	app.Get("/users", handlers.GetUsers)
-	app.Get("/users/:id", handlers.GetUser)
-	app.Post("/users", handlers.CreateUser)
-	app.Put("/users/:id", handlers.UpdateUser)
-	app.Delete("/users/:id", handlers.DeleteUser)
-
-	// Transfer routes
-	app.Post("/transfers", handlers.CreateTransfer)
-	app.Get("/transfers/:id", handlers.GetTransfer)
-	app.Get("/transfers", handlers.ListTransfers)
+	users := app.Group("/users")
+	users.Get("/", requireAuth, middleware.Require(middleware.PermUsersRead), userHandler.GetUsers)
+	users.Get("/:id", requireAuth, middleware.RequireSelfOr(middleware.PermUsersRead), userHandler.GetUser)
+	users.Post("/", requireAuth, middleware.Require(middleware.PermUsersWrite), userHandler.CreateUser)
+	users.Put("/:id", requireAuth, middleware.RequireSelfOr(middleware.PermUsersWrite), userHandler.UpdateUser)
+	users.Delete("/:id", requireAuth, middleware.Require(middleware.PermUsersWrite), userHandler.DeleteUser)
+
+	// Ledger and point operation routes
+	users.Get("/:id/ledger", withKey(models.ScopeLedgerRead), middleware.RequireSelfOr(middleware.PermLedgerRead), ledgerHandler.GetUserLedger)
+	users.Post("/:id/points/earn", requireAuth, middleware.Require(middleware.PermPointsWrite), pointsHandler.EarnPoints)
+	users.Post("/:id/points/redeem", requireAuth, middleware.RequireSelfOr(middleware.PermPointsWrite), pointsHandler.RedeemPoints)
+	users.Post("/:id/points/adjust", requireAuth, middleware.Require(middleware.PermPointsWrite), pointsHandler.AdjustPoints)
+
+	// Live ledger events (Server-Sent Events)
+	users.Get("/:id/events", requireAuth, middleware.RequireSelfOr(middleware.PermLedgerRead), eventsHandler.StreamUserEvents)
+
+	// Transfer routes. When a partner signing secret is configured, API key
+	// calls must also be HMAC signed; sessions are not affected. Nonces are
+	// remembered in memory, so replay protection is per process, not
+	// shared across replicas.
+	transfers := app.Group("/transfers")
+	if secret := cfg.Auth.PartnerSigningSecret; secret != "" {
+		transfers.Use(middleware.RequireSignature(middleware.SignatureConfig{
+			Secret: []byte(secret),
+			Next: func(c *fiber.Ctx) bool {
+				return c.Get(middleware.HeaderAPIKey) == ""
+			},
+		}))
+	}
+	transfers.Post("/", withKey(models.ScopeTransfersWrite), transferHandler.CreateTransfer)
+	transfers.Post("/batch", withKey(models.ScopeTransfersWrite), transferHandler.CreateTransferBatch)
+	transfers.Get("/:id", withKey(models.ScopeTransfersRead), transferHandler.GetTransfer)
+	transfers.Get("/", withKey(models.ScopeTransfersRead), transferHandler.ListTransfers)
+	transfers.Post("/:id/reverse", requireAuth, transferHandler.ReverseTransfer)
+	transfers.Post("/:id/cancel", withKey(models.ScopeTransfersWrite), transferHandler.CancelTransfer)
+	transfers.Post("/:id/capture", withKey(models.ScopeTransfersWrite), transferHandler.CaptureTransfer)
+	transfers.Post("/:id/void", withKey(models.ScopeTransfersWrite), transferHandler.VoidTransfer)
+
+	// Transfer notifications over WebSocket; authentication happens on the
+	// upgrade or with the first message
+	app.Get("/ws/transfers", socketHandler.UpgradeTransferSocket, websocket.New(socketHandler.TransferSocket))
+
+	// API key routes
+	apiKeys := app.Group("/api-keys", requireAuth)
+	apiKeys.Post("/", apiKeyHandler.CreateAPIKey)
+	apiKeys.Get("/", apiKeyHandler.ListAPIKeys)
+	apiKeys.Delete("/:id", apiKeyHandler.RevokeAPIKey)
+	apiKeys.Post("/:id/rotate", apiKeyHandler.RotateAPIKey)
+
+	// Webhook routes
+	webhooks := app.Group("/webhooks", requireAuth)
+	webhooks.Post("/", webhookHandler.CreateWebhook)
+	webhooks.Get("/", webhookHandler.ListWebhooks)
+	webhooks.Delete("/:id", webhookHandler.DeleteWebhook)
+	webhooks.Get("/:id/deliveries", webhookHandler.ListWebhookDeliveries)
+	webhooks.Post("/:id/deliveries/:deliveryId/redeliver", webhookHandler.RedeliverWebhook)
+
+	// Scheduled transfer routes
+	schedules := app.Group("/scheduled-transfers", requireAuth)
+	schedules.Post("/", scheduleHandler.CreateScheduledTransfer)
+	schedules.Get("/", scheduleHandler.ListScheduledTransfers)
+	schedules.Get("/:id/runs", scheduleHandler.ListScheduledTransferRuns)
+	schedules.Post("/:id/pause", scheduleHandler.PauseScheduledTransfer)
+	schedules.Post("/:id/resume", scheduleHandler.ResumeScheduledTransfer)
+	schedules.Delete("/:id", scheduleHandler.DeleteScheduledTransfer)
+
+	// Admin routes
+	admin := app.Group("/admin", requireAuth)
+	admin.Get("/reconcile", middleware.Require(middleware.PermAuditRead), adminHandler.GetReconcileReport)
+	admin.Post("/reconcile", middleware.Require(middleware.PermReconcileWrite), adminHandler.RepairReconcile)
+	admin.Get("/ledger/verify", middleware.Require(middleware.PermAuditRead), adminHandler.VerifyLedger)
+	admin.Put("/users/:id/role", middleware.Require(middleware.PermRolesWrite), adminHandler.SetUserRole)
 }
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"class-go-ai/models"
+	"class-go-ai/repository"
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrInvalidScope   = errors.New("scopes must be non-empty and one of transfers:read, transfers:write, ledger:read")
+	ErrInvalidAPIKey  = errors.New("invalid, expired or revoked api key")
+)
+
+const (
+	// apiKeyPrefix marks the plaintext so leaked keys are easy to recognise
+	apiKeyPrefix = "pk_"
+	// lastUsedResolution limits how often LastUsedAt is written
+	lastUsedResolution = time.Minute
+)
+
+// APIKeyService manages API keys for server-to-server integrations
+type APIKeyService struct {
+	store repository.Store
+}
+
+// NewAPIKeyService creates a new API key service
+func NewAPIKeyService(store repository.Store) *APIKeyService {
+	return &APIKeyService{store: store}
+}
+
+// Create issues a key owned by ownerID and returns it with its plaintext
+func (s *APIKeyService) Create(ownerID uint, req *models.APIKeyCreateRequest) (*models.APIKey, string, error) {
+	if len(req.Scopes) == 0 {
+		return nil, "", ErrInvalidScope
+	}
+	for _, scope := range req.Scopes {
+		if !scope.Valid() {
+			return nil, "", ErrInvalidScope
+		}
+	}
+
+	if req.SourceUserID != nil {
+		_, err := s.store.Users().FindByID(*req.SourceUserID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, "", ErrUserNotFound
+		}
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	key := &models.APIKey{
+		Name:         req.Name,
+		OwnerID:      ownerID,
+		SourceUserID: req.SourceUserID,
+		Scopes:       req.Scopes,
+		ExpiresAt:    req.ExpiresAt,
+	}
+
+	plaintext, err := s.insert(s.store, key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return key, plaintext, nil
+}
+
+// List returns the keys owned by a user, newest first
+func (s *APIKeyService) List(ownerID uint) ([]models.APIKey, error) {
+	return s.store.APIKeys().ListByOwner(ownerID)
+}
+
+// Get retrieves a key by ID
+func (s *APIKeyService) Get(id uint) (*models.APIKey, error) {
+	key, err := s.store.APIKeys().FindByID(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrAPIKeyNotFound
+	}
+	return key, err
+}
+
+// Revoke disables a key. Revoking a revoked key is a no-op.
+func (s *APIKeyService) Revoke(id uint) (*models.APIKey, error) {
+	key, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if key.RevokedAt == nil {
+		now := time.Now()
+		if err := s.store.APIKeys().Revoke(key.ID, now); err != nil {
+			return nil, err
+		}
+		key.RevokedAt = &now
+	}
+
+	return key, nil
+}
+
+// Rotate revokes a key and issues a replacement with the same name, owner,
+// source user, scopes and expiry
+func (s *APIKeyService) Rotate(id uint) (*models.APIKey, string, error) {
+	var replacement *models.APIKey
+	var plaintext string
+
+	err := s.store.Transaction(s.store.Context(), func(tx repository.Store) error {
+		key, err := tx.APIKeys().FindByID(id)
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrAPIKeyNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if key.RevokedAt != nil {
+			return ErrInvalidAPIKey
+		}
+
+		if err := tx.APIKeys().Revoke(key.ID, time.Now()); err != nil {
+			return err
+		}
+
+		replacement = &models.APIKey{
+			Name:         key.Name,
+			OwnerID:      key.OwnerID,
+			SourceUserID: key.SourceUserID,
+			Scopes:       key.Scopes,
+			ExpiresAt:    key.ExpiresAt,
+		}
+
+		plaintext, err = s.insert(tx, replacement)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return replacement, plaintext, nil
+}
+
+// Authenticate resolves a plaintext key, rejecting revoked and expired keys,
+// keys whose owner no longer exists, and keys bound to another user's
+// account once the owner's role fails debitOthers. It records when the key
+// was used.
+func (s *APIKeyService) Authenticate(plaintext string, debitOthers func(models.Role) bool) (*models.APIKey, error) {
+	key, err := s.store.APIKeys().FindByHash(hashToken(plaintext))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && now.After(*key.ExpiresAt)) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	owner, err := s.store.Users().FindByID(key.OwnerID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Checked on every use, so demoting the owner disables the key
+	if key.ActingUserID() != key.OwnerID && !debitOthers(owner.Role) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedResolution {
+		if err := s.store.APIKeys().Touch(key.ID, now); err != nil {
+			return nil, err
+		}
+		key.LastUsedAt = &now
+	}
+
+	return key, nil
+}
+
+// insert generates the key material for key and stores it
+func (s *APIKeyService) insert(tx repository.Store, key *models.APIKey) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+
+	plaintext := apiKeyPrefix + hex.EncodeToString(raw)
+	key.Prefix = plaintext[:len(apiKeyPrefix)+8]
+	key.KeyHash = hashToken(plaintext)
+
+	if err := tx.APIKeys().Create(key); err != nil {
+		return "", err
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,347 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"class-go-ai/models"
+	"class-go-ai/repository"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrEmailTaken         = errors.New("email is already registered")
+	ErrWeakPassword       = errors.New("password is too short")
+	ErrPasswordTooLong    = errors.New("password is too long")
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrInvalidToken       = errors.New("invalid or expired token")
+	ErrInvalidRole        = errors.New("role must be member, support or admin")
+	ErrLastAdmin          = errors.New("cannot remove the last admin")
+)
+
+const (
+	// MinPasswordLength is the shortest password accepted at registration
+	MinPasswordLength = 8
+	// MaxPasswordLength is the longest password bcrypt can hash, in bytes
+	MaxPasswordLength = 72
+	// DefaultAccessTTL is how long an access token is valid
+	DefaultAccessTTL = 15 * time.Minute
+	// DefaultRefreshTTL is how long a refresh token is valid
+	DefaultRefreshTTL = 30 * 24 * time.Hour
+)
+
+// AuthService handles credentials and JWT sessions
+type AuthService struct {
+	store      repository.Store
+	secret     []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+// NewAuthService creates a new auth service signing tokens with secret.
+// Without a secret a random one is generated, so tokens do not survive a
+// restart.
+func NewAuthService(store repository.Store, secret []byte) *AuthService {
+	if len(secret) == 0 {
+		log.Println("JWT secret not configured, using a random secret for this process")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(err)
+		}
+	}
+
+	return &AuthService{
+		store:      store,
+		secret:     secret,
+		accessTTL:  DefaultAccessTTL,
+		refreshTTL: DefaultRefreshTTL,
+	}
+}
+
+// SetTokenTTLs overrides the access and refresh token lifetimes
+func (s *AuthService) SetTokenTTLs(access, refresh time.Duration) {
+	s.accessTTL = access
+	s.refreshTTL = refresh
+}
+
+// Register creates a user with a password and starts a session
+func (s *AuthService) Register(req *models.RegisterRequest) (*models.TokenResponse, error) {
+	if len(req.Password) < MinPasswordLength {
+		return nil, ErrWeakPassword
+	}
+	if len(req.Password) > MaxPasswordLength {
+		return nil, ErrPasswordTooLong
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		Name:         req.Name,
+		Email:        strings.ToLower(strings.TrimSpace(req.Email)),
+		Phone:        req.Phone,
+		Address:      req.Address,
+		Avatar:       req.Avatar,
+		PasswordHash: string(hash),
+	}
+
+	err = s.store.Transaction(s.store.Context(), func(tx repository.Store) error {
+		_, err := tx.Users().FindByEmail(user.Email)
+		if err == nil {
+			return ErrEmailTaken
+		}
+		if !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+		return tx.Users().Create(user)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issue(s.store, user)
+}
+
+// Login checks a user's credentials and starts a session
+func (s *AuthService) Login(email, password string) (*models.TokenResponse, error) {
+	user, err := s.store.Users().FindByEmail(strings.ToLower(strings.TrimSpace(email)))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Deleted users and users created without a password cannot log in
+	if user.DeletedAt.Valid || user.PasswordHash == "" ||
+		bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return s.issue(s.store, user)
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token.
+// The old refresh token is revoked; presenting a revoked token again is
+// treated as theft and revokes every session of that user.
+func (s *AuthService) Refresh(refreshToken string) (*models.TokenResponse, error) {
+	var response *models.TokenResponse
+	var reused bool
+
+	err := s.store.Transaction(s.store.Context(), func(tx repository.Store) error {
+		token, err := tx.RefreshTokens().FindByHash(hashToken(refreshToken))
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if token.RevokedAt != nil {
+			reused = true
+			return ErrInvalidToken
+		}
+		if now.After(token.ExpiresAt) {
+			return ErrInvalidToken
+		}
+
+		user, err := tx.Users().FindByID(token.UserID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+
+		// A concurrent refresh with the same token revokes it first
+		revoked, err := tx.RefreshTokens().Revoke(token.TokenHash, now)
+		if err != nil {
+			return err
+		}
+		if !revoked {
+			return ErrInvalidToken
+		}
+
+		response, err = s.issue(tx, user)
+		return err
+	})
+
+	if reused {
+		s.revokeByHash(hashToken(refreshToken))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// Logout revokes a refresh token. Access tokens stay valid until they
+// expire, so their lifetime is kept short.
+func (s *AuthService) Logout(refreshToken string) error {
+	revoked, err := s.store.RefreshTokens().Revoke(hashToken(refreshToken), time.Now())
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// ParseAccessToken validates an access token and returns its user ID
+func (s *AuthService) ParseAccessToken(tokenString string) (uint, error) {
+	claims, err := s.parseClaims(tokenString)
+	if err != nil {
+		return 0, err
+	}
+
+	userID, err := strconv.ParseUint(claims.Subject, 10, 32)
+	if err != nil || userID == 0 {
+		return 0, ErrInvalidToken
+	}
+
+	return uint(userID), nil
+}
+
+// AccessTokenExpiry validates an access token and returns when it expires
+func (s *AuthService) AccessTokenExpiry(tokenString string) (time.Time, error) {
+	claims, err := s.parseClaims(tokenString)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return claims.ExpiresAt.Time, nil
+}
+
+// parseClaims checks the signature and expiry of an access token
+func (s *AuthService) parseClaims(tokenString string) (*jwt.RegisteredClaims, error) {
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(*jwt.Token) (interface{}, error) {
+		return s.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// Authenticate validates an access token and loads its user, so deleted
+// users and role changes take effect immediately
+func (s *AuthService) Authenticate(tokenString string) (*models.User, error) {
+	userID, err := s.ParseAccessToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.store.Users().FindByID(userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// SetRole changes a user's role. The last remaining admin cannot be demoted.
+func (s *AuthService) SetRole(userID uint, role models.Role) (*models.User, error) {
+	if !role.Valid() {
+		return nil, ErrInvalidRole
+	}
+
+	var user models.User
+	err := s.store.Transaction(s.store.Context(), func(tx repository.Store) error {
+		users, err := tx.Users().LockByIDs(userID)
+		if err != nil {
+			return err
+		}
+		if len(users) == 0 {
+			return ErrUserNotFound
+		}
+		user = users[0]
+
+		if user.Role == models.RoleAdmin && role != models.RoleAdmin {
+			admins, err := tx.Users().CountByRole(models.RoleAdmin)
+			if err != nil {
+				return err
+			}
+			if admins <= 1 {
+				return ErrLastAdmin
+			}
+		}
+
+		user.Role = role
+		return tx.Users().Save(&user)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// issue signs an access token and stores a new refresh token for user
+func (s *AuthService) issue(tx repository.Store, user *models.User) (*models.TokenResponse, error) {
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Subject:   strconv.FormatUint(uint64(user.ID), 10),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(s.accessTTL)),
+	}
+
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secret)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	refreshToken := hex.EncodeToString(raw)
+
+	err = tx.RefreshTokens().Create(&models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: now.Add(s.refreshTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.accessTTL.Seconds()),
+		User:         user,
+	}, nil
+}
+
+// revokeByHash revokes every open session of the user owning tokenHash
+func (s *AuthService) revokeByHash(tokenHash string) {
+	token, err := s.store.RefreshTokens().FindByHash(tokenHash)
+	if err != nil {
+		return
+	}
+
+	s.store.RefreshTokens().RevokeForUser(token.UserID, time.Now())
+}
+
+// hashToken returns the hex SHA-256 of an opaque token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"class-go-ai/events"
+	"class-go-ai/models"
+	"class-go-ai/repository"
+)
+
+// appendLedger writes a ledger entry inside tx, sealing it into the hash
+// chain. The caller must already have applied the change to the user's
+// balance and set BalanceAfter accordingly. The chain head stays locked
+// until tx ends, so concurrent appends wait for it and chain in commit
+// order; one that bypassed the lock fails with repository.ErrChainForked
+// rather than fork the chain.
+func appendLedger(tx repository.Store, entry *models.PointLedger) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	// Keep only what every database stores, so the hash survives a round trip
+	entry.CreatedAt = entry.CreatedAt.Truncate(time.Microsecond)
+
+	prevHash, err := tx.Ledger().LockHead()
+	if err != nil {
+		return err
+	}
+	entry.PrevHash = prevHash
+
+	entry.Hash = ledgerHash(entry)
+	if err := tx.Ledger().Create(entry); err != nil {
+		return err
+	}
+	if err := tx.Ledger().MoveHead(prevHash, entry); err != nil {
+		return err
+	}
+
+	events.CollectLedger(tx.Context(), entry)
+	return nil
+}
+
+// ledgerHash computes the chain hash of an entry from its previous hash and
+// canonical content. The id is left out because it is assigned on insert;
+// the chain itself fixes the order.
+func ledgerHash(entry *models.PointLedger) string {
+	canonical, _ := json.Marshal(struct {
+		PrevHash     string           `json:"prevHash"`
+		UserID       uint             `json:"userId"`
+		Change       int              `json:"change"`
+		BalanceAfter int              `json:"balanceAfter"`
+		EventType    models.EventType `json:"eventType"`
+		TransferID   *uint            `json:"transferId"`
+		Reference    string           `json:"reference"`
+		Metadata     string           `json:"metadata"`
+		CreatedAt    string           `json:"createdAt"`
+	}{
+		PrevHash:     entry.PrevHash,
+		UserID:       entry.UserID,
+		Change:       entry.Change,
+		BalanceAfter: entry.BalanceAfter,
+		EventType:    entry.EventType,
+		TransferID:   entry.TransferID,
+		Reference:    entry.Reference,
+		Metadata:     entry.Metadata,
+		CreatedAt:    entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:])
+}
+
+// ledgerMetadata encodes metadata as the JSON text stored in PointLedger.Metadata
+func ledgerMetadata(fields map[string]interface{}) string {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
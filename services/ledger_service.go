@@ -0,0 +1,173 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"class-go-ai/models"
+	"class-go-ai/repository"
+)
+
+// LedgerQuery filters a user's ledger entries
+type LedgerQuery struct {
+	UserID     uint
+	EventType  models.EventType
+	From       *time.Time // created_at >= From
+	To         *time.Time // created_at < To
+	TransferID *uint
+	Cursor     uint // only entries with id > Cursor
+	Limit      int
+}
+
+// LedgerService handles read access to the point ledger
+type LedgerService struct {
+	store       repository.Store
+	maxPageSize int
+}
+
+// NewLedgerService creates a new ledger service
+func NewLedgerService(store repository.Store) *LedgerService {
+	return &LedgerService{store: store, maxPageSize: DefaultMaxPageSize}
+}
+
+// SetMaxPageSize changes the largest page ListEntries returns
+func (s *LedgerService) SetMaxPageSize(size int) {
+	s.maxPageSize = size
+}
+
+// ListEntries returns a page of a user's ledger entries ordered by id
+func (s *LedgerService) ListEntries(q LedgerQuery) (*models.LedgerListResponse, error) {
+	if q.Limit < 1 || q.Limit > s.maxPageSize {
+		q.Limit = min(50, s.maxPageSize)
+	}
+
+	if err := s.ensureUser(q.UserID); err != nil {
+		return nil, err
+	}
+
+	// Fetch one extra row to know whether there is a next page
+	entries, err := s.store.Ledger().List(repository.LedgerFilter{
+		UserID:     q.UserID,
+		EventType:  q.EventType,
+		From:       q.From,
+		To:         q.To,
+		TransferID: q.TransferID,
+		AfterID:    q.Cursor,
+		Limit:      q.Limit + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.LedgerListResponse{Data: entries}
+	if len(entries) > q.Limit {
+		response.Data = entries[:q.Limit]
+		next := response.Data[q.Limit-1].ID
+		response.NextCursor = &next
+	}
+
+	return response, nil
+}
+
+// BalanceAsOf returns a user's balance at a point in time, taken from the
+// BalanceAfter of the last ledger entry written at or before asOf. Without
+// such an entry the balance is unknown and nil is returned: users can hold
+// points the ledger never recorded, such as seeded opening balances.
+func (s *LedgerService) BalanceAsOf(userID uint, asOf time.Time) (*int, error) {
+	if err := s.ensureUser(userID); err != nil {
+		return nil, err
+	}
+
+	entry, err := s.store.Ledger().LastForUser(userID, &asOf)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &entry.BalanceAfter, nil
+}
+
+// LatestEntryID returns the id of a user's newest ledger entry, or 0 when
+// the user has none
+func (s *LedgerService) LatestEntryID(userID uint) (uint, error) {
+	if err := s.ensureUser(userID); err != nil {
+		return 0, err
+	}
+
+	entry, err := s.store.Ledger().LastForUser(userID, nil)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	return entry.ID, nil
+}
+
+func (s *LedgerService) ensureUser(userID uint) error {
+	if _, err := s.store.Users().FindByID(userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// VerifyChain walks the ledger in id order and checks every row's hash
+// against its content and the previous row's hash. It stops at the first
+// row that was altered, or that follows a deleted or inserted row. Rows
+// written before hashing was introduced are counted as unsealed and skipped.
+// Deleting rows from the end cannot be seen from inside the chain; compare
+// HeadHash with a previously recorded value for that.
+func (s *LedgerService) VerifyChain() (*models.LedgerVerifyResult, error) {
+	result := &models.LedgerVerifyResult{Valid: true}
+	started := false
+	prevHash := ""
+
+	err := s.store.Ledger().Walk(500, func(batch []models.PointLedger) error {
+		for _, entry := range batch {
+			if entry.Hash == "" && !started {
+				result.Unsealed++
+				continue
+			}
+			started = true
+
+			reason := ""
+			switch {
+			case entry.Hash == "":
+				reason = "row is not sealed into the hash chain"
+			case entry.PrevHash != prevHash:
+				reason = "previous hash does not match; a row before it was deleted, inserted or altered"
+			case ledgerHash(&entry) != entry.Hash:
+				reason = "row content does not match its hash"
+			}
+
+			if reason != "" {
+				id := entry.ID
+				result.Valid = false
+				result.FirstInvalidID = &id
+				result.Reason = reason
+				return errStopVerify
+			}
+
+			result.Checked++
+			result.HeadID = entry.ID
+			result.HeadHash = entry.Hash
+			prevHash = entry.Hash
+		}
+		return nil
+	})
+
+	if err != nil && !errors.Is(err, errStopVerify) {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// errStopVerify ends the batch walk once the first invalid row is found
+var errStopVerify = errors.New("stop verification")
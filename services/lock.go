@@ -0,0 +1,18 @@
+package services
+
+import (
+	"class-go-ai/models"
+	"class-go-ai/repository"
+)
+
+// lockUser loads and locks one user inside tx
+func lockUser(tx repository.Store, userID uint) (*models.User, error) {
+	users, err := tx.Users().LockByIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, ErrUserNotFound
+	}
+	return &users[0], nil
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"errors"
+
+	"class-go-ai/events"
+	"class-go-ai/models"
+	"class-go-ai/repository"
+)
+
+var (
+	ErrReasonRequired = errors.New("reason is required")
+	ErrSourceRequired = errors.New("source reference is required")
+	ErrInvalidChange  = errors.New("change must not be 0")
+)
+
+// PointsService handles earn, redeem and adjust operations on the ledger
+type PointsService struct {
+	store     repository.Store
+	publisher events.Publisher
+}
+
+// NewPointsService creates a new points service
+func NewPointsService(store repository.Store) *PointsService {
+	return &PointsService{store: store}
+}
+
+// SetPublisher makes the service publish committed ledger entries
+func (s *PointsService) SetPublisher(publisher events.Publisher) {
+	s.publisher = publisher
+}
+
+// Earn credits points to a user, recording where they came from
+func (s *PointsService) Earn(userID uint, amount int, source string) (*models.PointLedger, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	if source == "" {
+		return nil, ErrSourceRequired
+	}
+
+	return s.post(userID, &models.PointLedger{
+		Change:    amount,
+		EventType: models.EventTypeEarn,
+		Reference: source,
+		Metadata:  ledgerMetadata(map[string]interface{}{"source": source}),
+	})
+}
+
+// Redeem debits points from a user's available balance
+func (s *PointsService) Redeem(userID uint, amount int, reference string) (*models.PointLedger, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	return s.post(userID, &models.PointLedger{
+		Change:    -amount,
+		EventType: models.EventTypeRedeem,
+		Reference: reference,
+	})
+}
+
+// Adjust posts a signed operator adjustment; the reason is stored in Metadata
+func (s *PointsService) Adjust(userID uint, change int, reason string) (*models.PointLedger, error) {
+	if change == 0 {
+		return nil, ErrInvalidChange
+	}
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+
+	return s.post(userID, &models.PointLedger{
+		Change:    change,
+		EventType: models.EventTypeAdjust,
+		Reference: "Manual adjustment",
+		Metadata:  ledgerMetadata(map[string]interface{}{"reason": reason}),
+	})
+}
+
+// post applies entry.Change to the user's balance and appends the entry in
+// one transaction, the same way CreateTransfer does
+func (s *PointsService) post(userID uint, entry *models.PointLedger) (*models.PointLedger, error) {
+	err := runInTx(s.store, s.publisher, func(tx repository.Store) error {
+		user, err := lockUser(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		// Debits may not dip into points reserved by holds
+		if entry.Change < 0 && user.AvailablePoints() < -entry.Change {
+			return ErrInsufficientPoints
+		}
+
+		user.Points += entry.Change
+		if err := tx.Users().Save(user); err != nil {
+			return err
+		}
+
+		entry.UserID = user.ID
+		entry.BalanceAfter = user.Points
+		return appendLedger(tx, entry)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
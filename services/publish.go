@@ -0,0 +1,24 @@
+package services
+
+import (
+	"class-go-ai/events"
+	"class-go-ai/repository"
+)
+
+// runInTx runs fn in a transaction on store and, once it has committed,
+// hands the ledger entries and transfer changes it made to publisher
+func runInTx(store repository.Store, publisher events.Publisher, fn func(tx repository.Store) error) error {
+	if publisher == nil {
+		return store.Transaction(store.Context(), fn)
+	}
+
+	ctx, collected := events.WithCollector(store.Context())
+	if err := store.Transaction(ctx, fn); err != nil {
+		return err
+	}
+
+	if messages := collected(); len(messages) > 0 {
+		publisher.Publish(messages...)
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"class-go-ai/models"
+	"class-go-ai/repository"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// changeColumn is point_ledgers.change, quoted by GORM because CHANGE is a
+// reserved word in MySQL
+var changeColumn = clause.Column{Name: "change"}
+
+// ReconcileService compares user balances and transfers against the ledger
+type ReconcileService struct {
+	db *gorm.DB
+}
+
+// NewReconcileService creates a new reconcile service
+func NewReconcileService(db *gorm.DB) *ReconcileService {
+	return &ReconcileService{db: db}
+}
+
+// ledgerKey identifies one expected ledger row of a transfer
+type ledgerKey struct {
+	userID    uint
+	eventType models.EventType
+	change    int
+}
+
+// Run checks every user and transfer. In repair mode each user whose points
+// differ from the ledger sum gets an adjust entry that brings the ledger in
+// line with User.Points; balances themselves are never changed.
+func (s *ReconcileService) Run(repair bool) (*models.ReconcileReport, error) {
+	report := &models.ReconcileReport{
+		GeneratedAt: time.Now(),
+		Repair:      repair,
+		Users:       []models.UserDiscrepancy{},
+		Transfers:   []models.TransferDiscrepancy{},
+	}
+
+	if err := s.checkUsers(report, repair); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkTransfers(report); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func (s *ReconcileService) checkUsers(report *models.ReconcileReport, repair bool) error {
+	// Deleted users keep their ledger, so check them too
+	var users []models.User
+	if err := s.db.Unscoped().Order("id").Find(&users).Error; err != nil {
+		return err
+	}
+	report.UsersChecked = len(users)
+
+	var sums []struct {
+		UserID uint
+		Total  int
+	}
+	if err := s.db.Model(&models.PointLedger{}).
+		Select("user_id, SUM(?) AS total", changeColumn).
+		Group("user_id").
+		Scan(&sums).Error; err != nil {
+		return err
+	}
+
+	var lastEntries []models.PointLedger
+	if err := s.db.Where("id IN (?)", s.db.Model(&models.PointLedger{}).Select("MAX(id)").Group("user_id")).
+		Find(&lastEntries).Error; err != nil {
+		return err
+	}
+
+	sumByUser := make(map[uint]int, len(sums))
+	for _, sum := range sums {
+		sumByUser[sum.UserID] = sum.Total
+	}
+	lastByUser := make(map[uint]int, len(lastEntries))
+	for _, entry := range lastEntries {
+		lastByUser[entry.UserID] = entry.BalanceAfter
+	}
+
+	for _, user := range users {
+		discrepancy := models.UserDiscrepancy{
+			UserID:    user.ID,
+			Points:    user.Points,
+			LedgerSum: sumByUser[user.ID],
+		}
+
+		if user.Points != discrepancy.LedgerSum {
+			discrepancy.Issues = append(discrepancy.Issues,
+				fmt.Sprintf("points %d differ from ledger sum %d", user.Points, discrepancy.LedgerSum))
+		}
+
+		if last, ok := lastByUser[user.ID]; ok {
+			discrepancy.LastBalanceAfter = &last
+			if last != user.Points {
+				discrepancy.Issues = append(discrepancy.Issues,
+					fmt.Sprintf("points %d differ from last balanceAfter %d", user.Points, last))
+			}
+		}
+
+		if len(discrepancy.Issues) == 0 {
+			continue
+		}
+
+		if repair && user.Points != discrepancy.LedgerSum {
+			entryID, err := s.repairUser(user.ID)
+			if err != nil {
+				return err
+			}
+			discrepancy.RepairEntryID = entryID
+		}
+
+		report.Users = append(report.Users, discrepancy)
+	}
+
+	return nil
+}
+
+// repairUser posts an adjust entry for the difference between the user's
+// points and their ledger sum, re-reading both inside the transaction
+func (s *ReconcileService) repairUser(userID uint) (*uint, error) {
+	var entryID *uint
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := repository.ForUpdate(tx).Unscoped().First(&user, userID).Error; err != nil {
+			return err
+		}
+
+		var sum int
+		if err := tx.Model(&models.PointLedger{}).
+			Where("user_id = ?", userID).
+			Select("COALESCE(SUM(?), 0)", changeColumn).
+			Scan(&sum).Error; err != nil {
+			return err
+		}
+
+		if user.Points == sum {
+			return nil
+		}
+
+		entry := &models.PointLedger{
+			UserID:       user.ID,
+			Change:       user.Points - sum,
+			BalanceAfter: user.Points,
+			EventType:    models.EventTypeAdjust,
+			Reference:    "Reconciliation",
+			Metadata: ledgerMetadata(map[string]interface{}{
+				"reason":    "reconciliation repair",
+				"ledgerSum": sum,
+				"points":    user.Points,
+			}),
+		}
+		if err := appendLedger(repository.NewGormStore(tx), entry); err != nil {
+			return err
+		}
+
+		entryID = &entry.ID
+		return nil
+	})
+
+	return entryID, err
+}
+
+func (s *ReconcileService) checkTransfers(report *models.ReconcileReport) error {
+	var transfers []models.Transfer
+	if err := s.db.Unscoped().Order("id").Find(&transfers).Error; err != nil {
+		return err
+	}
+	report.TransfersChecked = len(transfers)
+
+	var entries []models.PointLedger
+	if err := s.db.Where("transfer_id IS NOT NULL").Order("id").Find(&entries).Error; err != nil {
+		return err
+	}
+
+	actual := make(map[uint]map[ledgerKey]int)
+	for _, entry := range entries {
+		if actual[*entry.TransferID] == nil {
+			actual[*entry.TransferID] = make(map[ledgerKey]int)
+		}
+		actual[*entry.TransferID][ledgerKey{entry.UserID, entry.EventType, entry.Change}]++
+	}
+
+	for _, transfer := range transfers {
+		issues := compareLedgerRows(expectedLedgerRows(&transfer), actual[transfer.ID])
+		delete(actual, transfer.ID)
+
+		if len(issues) > 0 {
+			report.Transfers = append(report.Transfers, models.TransferDiscrepancy{
+				TransferID:     transfer.ID,
+				IdempotencyKey: transfer.IdempotencyKey,
+				Status:         transfer.Status,
+				Amount:         transfer.Amount,
+				Issues:         issues,
+			})
+		}
+	}
+
+	// Whatever is left points at transfers that do not exist
+	orphans := make([]uint, 0, len(actual))
+	for transferID := range actual {
+		orphans = append(orphans, transferID)
+	}
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i] < orphans[j] })
+	for _, transferID := range orphans {
+		report.Transfers = append(report.Transfers, models.TransferDiscrepancy{
+			TransferID: transferID,
+			Issues:     []string{"ledger entries reference a transfer that does not exist"},
+		})
+	}
+
+	return nil
+}
+
+// expectedLedgerRows returns the ledger rows a transfer should have in its
+// current status: one transfer_out and one transfer_in once completed, plus
+// the compensating pair once reversed
+func expectedLedgerRows(transfer *models.Transfer) map[ledgerKey]int {
+	expected := make(map[ledgerKey]int)
+
+	switch transfer.Status {
+	case models.TransferStatusCompleted, models.TransferStatusReversed:
+		expected[ledgerKey{transfer.FromUserID, models.EventTypeTransferOut, -transfer.Amount}]++
+		expected[ledgerKey{transfer.ToUserID, models.EventTypeTransferIn, transfer.Amount}]++
+	}
+
+	if transfer.Status == models.TransferStatusReversed {
+		expected[ledgerKey{transfer.ToUserID, models.EventTypeTransferOut, -transfer.Amount}]++
+		expected[ledgerKey{transfer.FromUserID, models.EventTypeTransferIn, transfer.Amount}]++
+	}
+
+	return expected
+}
+
+func compareLedgerRows(expected, actual map[ledgerKey]int) []string {
+	var issues []string
+
+	for key, want := range expected {
+		if got := actual[key]; got != want {
+			issues = append(issues, fmt.Sprintf("expected %d %s row(s) of %d for user %d, found %d",
+				want, key.eventType, key.change, key.userID, got))
+		}
+	}
+
+	for key, got := range actual {
+		if _, ok := expected[key]; !ok {
+			issues = append(issues, fmt.Sprintf("unexpected %d %s row(s) of %d for user %d",
+				got, key.eventType, key.change, key.userID))
+		}
+	}
+
+	sort.Strings(issues)
+	return issues
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"class-go-ai/models"
+	"class-go-ai/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrReplayAsOf         = errors.New("replaying up to a past time is only allowed as a dry run")
+	ErrReplayInconsistent = errors.New("ledger does not account for every balance; reconcile it before replaying")
+)
+
+// ReplayService rebuilds user balances from the point ledger
+type ReplayService struct {
+	db *gorm.DB
+}
+
+// NewReplayService creates a new replay service
+func NewReplayService(db *gorm.DB) *ReplayService {
+	return &ReplayService{db: db}
+}
+
+// Replay re-applies the ledger in id order from a zero balance, optionally
+// only up to asOf, and reports every user whose points would change. Each
+// entry's BalanceAfter is checked against the running balance; mismatches
+// are reported, not rewritten, since the ledger is append-only and
+// hash-sealed. HeldPoints are left untouched.
+//
+// Unless dryRun, the replayed balances are written with every user row
+// locked. That is refused with ErrReplayInconsistent, alongside the report,
+// while there are mismatches or balances the ledger never recorded, since
+// replaying would wipe them; and with ErrReplayAsOf for asOf, which would
+// write past balances over live ones.
+func (s *ReplayService) Replay(asOf *time.Time, dryRun bool) (*models.ReplayReport, error) {
+	if asOf != nil && !dryRun {
+		return nil, ErrReplayAsOf
+	}
+
+	report := &models.ReplayReport{
+		DryRun:     dryRun,
+		AsOf:       asOf,
+		Changes:    []models.BalanceDiff{},
+		Mismatches: []models.BalanceAfterMismatch{},
+		Unrecorded: []models.UnrecordedBalance{},
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Locked in id order, as transfers lock them, so no transfer moves
+		// points between the read and the write
+		users := tx.Unscoped().Order("id")
+		if !dryRun {
+			users = repository.ForUpdate(users)
+		}
+		var list []models.User
+		if err := users.Find(&list).Error; err != nil {
+			return err
+		}
+		report.UsersChecked = len(list)
+
+		running := make(map[uint]int, len(list))
+		replayed := make(map[uint]bool, len(list))
+		for _, user := range list {
+			running[user.ID] = 0
+		}
+
+		query := tx.Order("id")
+		if asOf != nil {
+			query = query.Where("created_at <= ?", *asOf)
+		}
+
+		var batch []models.PointLedger
+		err := query.FindInBatches(&batch, 1000, func(_ *gorm.DB, _ int) error {
+			for _, entry := range batch {
+				if _, ok := running[entry.UserID]; !ok {
+					continue
+				}
+
+				running[entry.UserID] += entry.Change
+				replayed[entry.UserID] = true
+				report.EntriesReplayed++
+
+				if entry.BalanceAfter != running[entry.UserID] {
+					report.Mismatches = append(report.Mismatches, models.BalanceAfterMismatch{
+						EntryID:      entry.ID,
+						UserID:       entry.UserID,
+						BalanceAfter: entry.BalanceAfter,
+						Expected:     running[entry.UserID],
+					})
+				}
+			}
+			return nil
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		for _, user := range list {
+			if !replayed[user.ID] && user.Points != 0 {
+				report.Unrecorded = append(report.Unrecorded, models.UnrecordedBalance{UserID: user.ID, Points: user.Points})
+			}
+			if running[user.ID] != user.Points {
+				report.Changes = append(report.Changes, models.BalanceDiff{
+					UserID:   user.ID,
+					Current:  user.Points,
+					Replayed: running[user.ID],
+					Delta:    running[user.ID] - user.Points,
+				})
+			}
+		}
+
+		if dryRun {
+			return nil
+		}
+		if !report.Clean() {
+			return ErrReplayInconsistent
+		}
+		for _, change := range report.Changes {
+			if err := tx.Unscoped().Model(&models.User{}).Where("id = ?", change.UserID).Update("points", change.Replayed).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if errors.Is(err, ErrReplayInconsistent) {
+		return report, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
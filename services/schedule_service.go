@@ -0,0 +1,356 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"class-go-ai/models"
+	"class-go-ai/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrScheduleNotFound = errors.New("scheduled transfer not found")
+	ErrInvalidFrequency = errors.New("frequency must be once, daily, weekly or monthly")
+	ErrInvalidPolicy    = errors.New("onInsufficient must be retry or skip")
+	ErrInvalidRetries   = errors.New("maxRetries must be between 0 and 10")
+	ErrScheduleStatus   = errors.New("scheduled transfer status does not allow this operation")
+)
+
+// errScheduleChanged skips a run whose schedule changed after it was loaded
+var errScheduleChanged = errors.New("schedule changed since it was loaded")
+
+// DefaultRetryDelay is how long a run waits before retrying after the sender
+// had insufficient points
+const DefaultRetryDelay = time.Hour
+
+// ScheduleService handles scheduled and recurring transfers
+type ScheduleService struct {
+	db         *gorm.DB
+	transfers  *TransferService
+	retryDelay time.Duration
+}
+
+// NewScheduleService creates a new schedule service that executes runs
+// through the given transfer service
+func NewScheduleService(db *gorm.DB, transfers *TransferService) *ScheduleService {
+	return &ScheduleService{db: db, transfers: transfers, retryDelay: DefaultRetryDelay}
+}
+
+// SetRetryDelay changes how long a run waits before retrying
+func (s *ScheduleService) SetRetryDelay(delay time.Duration) {
+	s.retryDelay = delay
+}
+
+// CreateSchedule creates a new active scheduled transfer
+func (s *ScheduleService) CreateSchedule(req *models.ScheduledTransferCreateRequest) (*models.ScheduledTransfer, error) {
+	// Validation
+	if req.Amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	if req.FromUserID == req.ToUserID {
+		return nil, ErrSameUser
+	}
+
+	switch req.Frequency {
+	case models.ScheduleFrequencyOnce, models.ScheduleFrequencyDaily,
+		models.ScheduleFrequencyWeekly, models.ScheduleFrequencyMonthly:
+	default:
+		return nil, ErrInvalidFrequency
+	}
+
+	policy := req.OnInsufficient
+	if policy == "" {
+		policy = models.InsufficientPolicySkip
+	}
+	if policy != models.InsufficientPolicyRetry && policy != models.InsufficientPolicySkip {
+		return nil, ErrInvalidPolicy
+	}
+
+	if req.MaxRetries < 0 || req.MaxRetries > 10 {
+		return nil, ErrInvalidRetries
+	}
+
+	startAt := req.StartAt
+	if startAt.IsZero() {
+		startAt = time.Now()
+	}
+
+	// Both users must exist
+	var count int64
+	if err := s.db.Model(&models.User{}).Where("id IN ?", []uint{req.FromUserID, req.ToUserID}).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count != 2 {
+		return nil, ErrUserNotFound
+	}
+
+	schedule := &models.ScheduledTransfer{
+		FromUserID:     req.FromUserID,
+		ToUserID:       req.ToUserID,
+		Amount:         req.Amount,
+		Note:           req.Note,
+		Frequency:      req.Frequency,
+		StartAt:        startAt,
+		Status:         models.ScheduleStatusActive,
+		OnInsufficient: policy,
+		MaxRetries:     req.MaxRetries,
+		NextRunAt:      &startAt,
+	}
+
+	if err := s.db.Create(schedule).Error; err != nil {
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+// ListSchedules returns the scheduled transfers sent by a user
+func (s *ScheduleService) ListSchedules(userID uint) ([]models.ScheduledTransfer, error) {
+	var schedules []models.ScheduledTransfer
+	err := s.db.Where("from_user_id = ?", userID).Order("id DESC").Find(&schedules).Error
+	return schedules, err
+}
+
+// GetSchedule retrieves a scheduled transfer by ID
+func (s *ScheduleService) GetSchedule(id uint) (*models.ScheduledTransfer, error) {
+	var schedule models.ScheduledTransfer
+	if err := s.db.First(&schedule, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrScheduleNotFound
+		}
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// ListRuns returns the run history of a scheduled transfer, newest first
+func (s *ScheduleService) ListRuns(id uint) ([]models.ScheduledTransferRun, error) {
+	if _, err := s.GetSchedule(id); err != nil {
+		return nil, err
+	}
+
+	var runs []models.ScheduledTransferRun
+	err := s.db.Where("schedule_id = ?", id).Order("id DESC").Find(&runs).Error
+	return runs, err
+}
+
+// PauseSchedule stops an active schedule from running
+func (s *ScheduleService) PauseSchedule(id uint) (*models.ScheduledTransfer, error) {
+	return s.setStatus(id, models.ScheduleStatusActive, models.ScheduleStatusPaused)
+}
+
+// ResumeSchedule reactivates a paused schedule. If occurrences were missed
+// while paused, the pending one runs on the next tick and older ones are skipped.
+func (s *ScheduleService) ResumeSchedule(id uint) (*models.ScheduledTransfer, error) {
+	return s.setStatus(id, models.ScheduleStatusPaused, models.ScheduleStatusActive)
+}
+
+// DeleteSchedule deletes a scheduled transfer; its run history is kept
+func (s *ScheduleService) DeleteSchedule(id uint) error {
+	schedule, err := s.GetSchedule(id)
+	if err != nil {
+		return err
+	}
+	return s.db.Delete(schedule).Error
+}
+
+func (s *ScheduleService) setStatus(id uint, from, to models.ScheduleStatus) (*models.ScheduledTransfer, error) {
+	schedule, err := s.GetSchedule(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if schedule.Status != from {
+		return nil, ErrScheduleStatus
+	}
+
+	// Only from the status just read, so a run finishing meanwhile is not
+	// written over
+	result := s.db.Model(schedule).Where("status = ?", from).Update("status", to)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrScheduleStatus
+	}
+	return schedule, nil
+}
+
+// RunDue executes every active schedule whose next run is due at now and
+// returns how many runs were attempted
+func (s *ScheduleService) RunDue(now time.Time) (int, error) {
+	var due []models.ScheduledTransfer
+	err := s.db.Where("status = ? AND next_run_at <= ?", models.ScheduleStatusActive, now).
+		Order("next_run_at").
+		Find(&due).Error
+	if err != nil {
+		return 0, err
+	}
+
+	ran := 0
+	for i := range due {
+		err := s.execute(&due[i], now)
+		if errors.Is(err, errScheduleChanged) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Scheduled transfer %d failed: %v", due[i].ID, err)
+			continue
+		}
+		ran++
+	}
+
+	return ran, nil
+}
+
+// execute runs the next occurrence of a schedule and records the outcome.
+// The idempotency key is derived from the occurrence and attempt, so a crash
+// between the transfer and the bookkeeping replays instead of paying twice.
+// A schedule paused, deleted or run by another worker since RunDue loaded
+// it is skipped with errScheduleChanged, before the transfer if possible.
+func (s *ScheduleService) execute(schedule *models.ScheduledTransfer, now time.Time) error {
+	scheduledFor := *schedule.NextRunAt
+
+	var current models.ScheduledTransfer
+	if err := s.db.First(&current, schedule.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errScheduleChanged
+		}
+		return err
+	}
+	if !unchanged(&current, schedule.Occurrence, schedule.Attempt, scheduledFor) {
+		return errScheduleChanged
+	}
+
+	transfer, err := s.transfers.CreateTransfer(&models.TransferCreateRequest{
+		FromUserID:     schedule.FromUserID,
+		ToUserID:       schedule.ToUserID,
+		Amount:         schedule.Amount,
+		Note:           schedule.Note,
+		IdempotencyKey: fmt.Sprintf("schedule-%d-%d-%d", schedule.ID, schedule.Occurrence, schedule.Attempt),
+	})
+
+	run := &models.ScheduledTransferRun{
+		ScheduleID:   schedule.ID,
+		Occurrence:   schedule.Occurrence,
+		Attempt:      schedule.Attempt,
+		ScheduledFor: scheduledFor,
+	}
+	if transfer != nil {
+		run.TransferID = &transfer.ID
+		run.TransferKey = transfer.IdempotencyKey
+	}
+
+	switch {
+	case err == nil:
+		run.Status = models.ScheduleRunSucceeded
+		s.advance(schedule, now)
+	case errors.Is(err, ErrInsufficientPoints):
+		run.Error = err.Error()
+		if schedule.OnInsufficient == models.InsufficientPolicyRetry && schedule.Attempt < schedule.MaxRetries {
+			run.Status = models.ScheduleRunRetrying
+			schedule.Attempt++
+			retryAt := now.Add(s.retryDelay)
+			schedule.NextRunAt = &retryAt
+		} else {
+			run.Status = models.ScheduleRunSkipped
+			s.advance(schedule, now)
+		}
+	case errors.Is(err, ErrUserNotFound), errors.Is(err, ErrSameUser), errors.Is(err, ErrIdempotencyKeyUsed):
+		// Will never succeed as configured; stop until someone looks at it
+		run.Status = models.ScheduleRunFailed
+		run.Error = err.Error()
+		schedule.Status = models.ScheduleStatusPaused
+	default:
+		// Infrastructure error: leave the schedule due so the next tick retries
+		return err
+	}
+
+	schedule.LastRunAt = &now
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// The run is recorded whatever happened to the schedule, since the
+		// transfer was made
+		if err := tx.Create(run).Error; err != nil {
+			return err
+		}
+
+		var current models.ScheduledTransfer
+		err := repository.ForUpdate(tx).First(&current, schedule.ID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil || !unchanged(&current, run.Occurrence, run.Attempt, scheduledFor) {
+			return err
+		}
+
+		return tx.Model(&models.ScheduledTransfer{}).
+			Where("id = ? AND status = ? AND occurrence = ? AND attempt = ?",
+				schedule.ID, models.ScheduleStatusActive, run.Occurrence, run.Attempt).
+			Updates(map[string]any{
+				"status":      schedule.Status,
+				"occurrence":  schedule.Occurrence,
+				"attempt":     schedule.Attempt,
+				"next_run_at": schedule.NextRunAt,
+				"last_run_at": schedule.LastRunAt,
+			}).Error
+	})
+}
+
+// unchanged reports whether schedule is still active and due at nextRunAt
+// for the given occurrence and attempt
+func unchanged(schedule *models.ScheduledTransfer, occurrence, attempt int, nextRunAt time.Time) bool {
+	return schedule.Status == models.ScheduleStatusActive &&
+		schedule.NextRunAt != nil && schedule.NextRunAt.Equal(nextRunAt) &&
+		schedule.Occurrence == occurrence && schedule.Attempt == attempt
+}
+
+// advance moves a schedule past the occurrence that just ran. Occurrences
+// that were missed (e.g. while the server was down) are skipped rather than
+// paid out in a burst; one-off schedules are completed.
+func (s *ScheduleService) advance(schedule *models.ScheduledTransfer, now time.Time) {
+	schedule.Attempt = 0
+
+	if schedule.Frequency == models.ScheduleFrequencyOnce {
+		schedule.Status = models.ScheduleStatusCompleted
+		schedule.NextRunAt = nil
+		return
+	}
+
+	next := schedule.Occurrence + 1
+	for !occurrenceAt(schedule, next).After(now) {
+		next++
+	}
+
+	schedule.Occurrence = next
+	nextRunAt := occurrenceAt(schedule, next)
+	schedule.NextRunAt = &nextRunAt
+}
+
+// occurrenceAt returns the time of the n-th occurrence of a schedule.
+// Monthly schedules keep the start day, clamped to the end of shorter months.
+func occurrenceAt(schedule *models.ScheduledTransfer, n int) time.Time {
+	start := schedule.StartAt
+	switch schedule.Frequency {
+	case models.ScheduleFrequencyDaily:
+		return start.AddDate(0, 0, n)
+	case models.ScheduleFrequencyWeekly:
+		return start.AddDate(0, 0, 7*n)
+	case models.ScheduleFrequencyMonthly:
+		firstOfMonth := time.Date(start.Year(), start.Month()+time.Month(n), 1,
+			start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+		lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+		day := start.Day()
+		if day > lastDay {
+			day = lastDay
+		}
+		return firstOfMonth.AddDate(0, 0, day-1)
+	default:
+		return start
+	}
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"errors"
+
+	"class-go-ai/models"
+	"class-go-ai/repository"
+)
+
+// ErrBatchRolledBack marks batch items undone because another item failed
+var ErrBatchRolledBack = errors.New("rolled back because another item in the batch failed")
+
+// BatchItemResult is the outcome of one item of a transfer batch
+type BatchItemResult struct {
+	Transfer *models.Transfer
+	Err      error
+}
+
+// CreateTransferBatch runs a list of transfers. In atomic mode they share one
+// transaction and either all complete or none do; the returned error is then
+// the failing item's error. Otherwise each item runs on its own through
+// CreateTransfer and failures are only reported per item.
+func (s *TransferService) CreateTransferBatch(reqs []*models.TransferCreateRequest, atomic bool) ([]BatchItemResult, error) {
+	results := make([]BatchItemResult, len(reqs))
+
+	if !atomic {
+		for i, req := range reqs {
+			results[i].Transfer, results[i].Err = s.CreateTransfer(req)
+		}
+		return results, nil
+	}
+
+	failed := -1
+	err := s.transaction(func(tx repository.Store) error {
+		// Lock every item's users before the first append locks the chain
+		// head, the order a single transfer takes them in, so the two
+		// cannot deadlock
+		var userIDs []uint
+		for _, req := range reqs {
+			userIDs = append(userIDs, req.FromUserID, req.ToUserID)
+		}
+		if _, err := tx.Users().LockByIDs(userIDs...); err != nil {
+			return err
+		}
+		for i, req := range reqs {
+			transfer, err := s.createInTx(tx, req)
+			if err != nil {
+				failed = i
+				results[i].Err = err
+				return err
+			}
+			results[i].Transfer = transfer
+		}
+		return nil
+	})
+
+	if err != nil {
+		if failed < 0 {
+			// The commit itself failed
+			for i := range results {
+				results[i] = BatchItemResult{Err: err}
+			}
+			return results, err
+		}
+		for i := range results {
+			if i != failed {
+				results[i] = BatchItemResult{Err: ErrBatchRolledBack}
+			}
+		}
+		return results, err
+	}
+
+	return results, nil
+}
+
+// createInTx runs one transfer inside an existing transaction, replaying it
+// if its idempotency key was already used
+func (s *TransferService) createInTx(tx repository.Store, req *models.TransferCreateRequest) (*models.Transfer, error) {
+	if err := validateTransfer(req); err != nil {
+		return nil, err
+	}
+
+	if req.IdempotencyKey != "" {
+		existing, err := s.findReplay(tx, req)
+		if existing != nil || err != nil {
+			return existing, err
+		}
+	}
+
+	transfer := s.newTransfer(req)
+	if err := s.executeTransfer(tx, req, transfer); err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"class-go-ai/models"
+	"class-go-ai/repository"
+)
+
+// CaptureTransfer completes a pending hold, moving the reserved points to the
+// receiver and writing the ledger entries. An expired hold is voided instead
+// and ErrHoldExpired is returned along with the cancelled transfer.
+func (s *TransferService) CaptureTransfer(idemKey string) (*models.Transfer, error) {
+	var transfer *models.Transfer
+	expired := false
+
+	err := s.transaction(func(tx repository.Store) error {
+		var err error
+		if transfer, err = findTransfer(tx, idemKey); err != nil {
+			return err
+		}
+
+		if transfer.HoldExpiresAt == nil {
+			return ErrNotHold
+		}
+
+		if transfer.Status != models.TransferStatusPending {
+			return ErrInvalidStatus
+		}
+
+		if !transfer.HoldExpiresAt.After(time.Now()) {
+			expired = true
+			return cancelPending(tx, transfer, "Hold expired")
+		}
+
+		fromUser, toUser, err := loadParties(tx, transfer.FromUserID, transfer.ToUserID)
+		if err != nil {
+			return err
+		}
+
+		// Turn the reservation into a real debit
+		fromUser.HeldPoints -= transfer.Amount
+		transfer.Status = models.TransferStatusProcessing
+		return settleTransfer(tx, transfer, fromUser, toUser)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if expired {
+		return transfer, ErrHoldExpired
+	}
+
+	return transfer, nil
+}
+
+// VoidTransfer releases a pending hold without moving any points
+func (s *TransferService) VoidTransfer(idemKey, reason string) (*models.Transfer, error) {
+	var transfer *models.Transfer
+
+	err := s.transaction(func(tx repository.Store) error {
+		var err error
+		if transfer, err = findTransfer(tx, idemKey); err != nil {
+			return err
+		}
+
+		if transfer.HoldExpiresAt == nil {
+			return ErrNotHold
+		}
+
+		if transfer.Status != models.TransferStatusPending {
+			return ErrInvalidStatus
+		}
+
+		if reason == "" {
+			reason = "Hold voided"
+		}
+		return cancelPending(tx, transfer, reason)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+// VoidExpiredHolds voids every pending hold that expired before now and
+// returns how many were voided
+func (s *TransferService) VoidExpiredHolds(now time.Time) (int, error) {
+	keys, err := s.store.Transfers().ExpiredHoldKeys(now)
+	if err != nil {
+		return 0, err
+	}
+
+	voided := 0
+	for _, key := range keys {
+		if _, err := s.VoidTransfer(key, "Hold expired"); err != nil {
+			// Captured or voided concurrently since the query above
+			if errors.Is(err, ErrInvalidStatus) {
+				continue
+			}
+			return voided, err
+		}
+		voided++
+	}
+
+	return voided, nil
+}
+
+// cancelPending marks a pending transfer cancelled, releasing the sender's
+// reserved points if it was a hold
+func cancelPending(tx repository.Store, transfer *models.Transfer, reason string) error {
+	if transfer.HoldExpiresAt != nil {
+		fromUser, err := lockUser(tx, transfer.FromUserID)
+		if err != nil {
+			return err
+		}
+
+		fromUser.HeldPoints -= transfer.Amount
+		if err := tx.Users().Save(fromUser); err != nil {
+			return err
+		}
+	}
+
+	transfer.Status = models.TransferStatusCancelled
+	transfer.Reason = reason
+	if err := tx.Transfers().Save(transfer); err != nil {
+		return err
+	}
+
+	return enqueueEvent(tx, models.WebhookTransferCancelled, transfer)
+}
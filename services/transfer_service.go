@@ -5,10 +5,11 @@ import (
 	"fmt"
 	"time"
 
+	"class-go-ai/events"
 	"class-go-ai/models"
+	"class-go-ai/repository"
 
 	"github.com/google/uuid"
-	"gorm.io/gorm"
 )
 
 var (
@@ -16,31 +17,142 @@ var (
 	ErrSameUser           = errors.New("cannot transfer to the same user")
 	ErrUserNotFound       = errors.New("user not found")
 	ErrInvalidAmount      = errors.New("amount must be greater than 0")
+	ErrIdempotencyKeyUsed = errors.New("idempotency key already used with a different request")
+	ErrTransferNotFound   = errors.New("transfer not found")
+	ErrInvalidStatus      = errors.New("transfer status does not allow this operation")
+	ErrReceiverNoFunds    = errors.New("receiver does not have enough points to reverse")
+	ErrNotHold            = errors.New("transfer is not a hold")
+	ErrHoldExpired        = errors.New("hold has expired")
 )
 
+// FailReasonInsufficientPoints is the FailReason of a transfer the sender
+// could not cover
+const FailReasonInsufficientPoints = "Insufficient points"
+
+// DefaultHoldTTL is how long a hold stays pending before it is voided
+const DefaultHoldTTL = 15 * time.Minute
+
+// DefaultMaxPageSize is the largest page a list request may ask for
+const DefaultMaxPageSize = 200
+
 // TransferService handles business logic for transfers
 type TransferService struct {
-	db *gorm.DB
+	store       repository.Store
+	holdTTL     time.Duration
+	maxPageSize int
+	publisher   events.Publisher
+	metrics     TransferMetrics
+}
+
+// TransferMetrics is told what CreateTransfer does that the published
+// changes do not show. Final statuses reach the publisher instead.
+type TransferMetrics interface {
+	// TransferRejected counts a transfer whose transaction failed without
+	// recording it, such as one between unknown users
+	TransferRejected(err error)
+	// TransferTransaction records how long a transfer transaction took and
+	// whether it committed
+	TransferTransaction(elapsed time.Duration, err error)
 }
 
 // NewTransferService creates a new transfer service
-func NewTransferService(db *gorm.DB) *TransferService {
-	return &TransferService{db: db}
+func NewTransferService(store repository.Store) *TransferService {
+	return &TransferService{store: store, holdTTL: DefaultHoldTTL, maxPageSize: DefaultMaxPageSize}
 }
 
-// CreateTransfer creates a new transfer with atomic transaction
+// SetHoldTTL changes how long new holds stay pending before they expire
+func (s *TransferService) SetHoldTTL(ttl time.Duration) {
+	s.holdTTL = ttl
+}
+
+// SetMaxPageSize changes the largest page GetTransfersByUserID returns
+func (s *TransferService) SetMaxPageSize(size int) {
+	s.maxPageSize = size
+}
+
+// SetPublisher makes the service publish committed ledger entries and
+// transfer changes
+func (s *TransferService) SetPublisher(publisher events.Publisher) {
+	s.publisher = publisher
+}
+
+// SetMetrics makes CreateTransfer report its rejections and transaction
+// durations to metrics
+func (s *TransferService) SetMetrics(metrics TransferMetrics) {
+	s.metrics = metrics
+}
+
+// transaction runs fn in a transaction and publishes its changes after commit
+func (s *TransferService) transaction(fn func(tx repository.Store) error) error {
+	return runInTx(s.store, s.publisher, fn)
+}
+
+// CreateTransfer creates a new transfer with atomic transaction.
+// If req.IdempotencyKey was already used with the same payload, the original
+// transfer (and its original error, if it failed) is returned instead.
 func (s *TransferService) CreateTransfer(req *models.TransferCreateRequest) (*models.Transfer, error) {
-	// Validation
+	if err := validateTransfer(req); err != nil {
+		return nil, err
+	}
+
+	if req.IdempotencyKey != "" {
+		existing, err := s.findReplay(s.store, req)
+		if existing != nil || err != nil {
+			return existing, err
+		}
+	}
+
+	transfer := s.newTransfer(req)
+
+	// Start transaction
+	started := time.Now()
+	err := s.transaction(func(tx repository.Store) error {
+		return s.executeTransfer(tx, req, transfer)
+	})
+	if s.metrics != nil {
+		s.metrics.TransferTransaction(time.Since(started), err)
+	}
+
+	if err != nil {
+		if errors.Is(err, ErrInsufficientPoints) {
+			return s.recordFailure(req, transfer, FailReasonInsufficientPoints, err)
+		}
+		if req.IdempotencyKey != "" {
+			// A concurrent request with the same key may have won the unique index
+			existing, replayErr := s.findReplay(s.store, req)
+			if existing != nil || replayErr != nil {
+				return existing, replayErr
+			}
+		}
+		if s.metrics != nil {
+			s.metrics.TransferRejected(err)
+		}
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+// validateTransfer checks a transfer request before touching the database
+func validateTransfer(req *models.TransferCreateRequest) error {
 	if req.Amount <= 0 {
-		return nil, ErrInvalidAmount
+		return ErrInvalidAmount
 	}
 
 	if req.FromUserID == req.ToUserID {
-		return nil, ErrSameUser
+		return ErrSameUser
 	}
 
-	// Generate idempotency key
-	idemKey := uuid.New().String()
+	return nil
+}
+
+// newTransfer builds the pending transfer for a request, using the client's
+// idempotency key or generating one
+func (s *TransferService) newTransfer(req *models.TransferCreateRequest) *models.Transfer {
+	idemKey := req.IdempotencyKey
+	if idemKey == "" {
+		idemKey = uuid.New().String()
+	}
 
 	transfer := &models.Transfer{
 		FromUserID:     req.FromUserID,
@@ -51,113 +163,314 @@ func (s *TransferService) CreateTransfer(req *models.TransferCreateRequest) (*mo
 		Status:         models.TransferStatusPending,
 	}
 
-	// Start transaction
-	err := s.db.Transaction(func(tx *gorm.DB) error {
-		// Get sender
-		var fromUser models.User
-		if err := tx.First(&fromUser, req.FromUserID).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return ErrUserNotFound
-			}
+	if req.Hold {
+		expiresAt := time.Now().Add(s.holdTTL)
+		transfer.HoldExpiresAt = &expiresAt
+	}
+
+	return transfer
+}
+
+// executeTransfer creates the transfer inside tx and either settles it or,
+// in hold mode, reserves the amount and leaves it pending
+func (s *TransferService) executeTransfer(tx repository.Store, req *models.TransferCreateRequest, transfer *models.Transfer) error {
+	fromUser, toUser, err := loadParties(tx, req.FromUserID, req.ToUserID)
+	if err != nil {
+		return err
+	}
+
+	// Check sufficient points (held points are not available)
+	if fromUser.AvailablePoints() < req.Amount {
+		return ErrInsufficientPoints
+	}
+
+	// Hold mode: reserve the amount and leave the transfer pending
+	if req.Hold {
+		fromUser.HeldPoints += req.Amount
+		if err := tx.Users().Save(fromUser); err != nil {
 			return err
 		}
+		if err := tx.Transfers().Create(transfer); err != nil {
+			return err
+		}
+		events.CollectTransfer(tx.Context(), transfer)
+		return nil
+	}
 
-		// Get receiver
-		var toUser models.User
-		if err := tx.First(&toUser, req.ToUserID).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return ErrUserNotFound
+	// Update status to processing
+	transfer.Status = models.TransferStatusProcessing
+	if err := tx.Transfers().Create(transfer); err != nil {
+		return err
+	}
+
+	return settleTransfer(tx, transfer, fromUser, toUser)
+}
+
+// recordFailure persists a failed transfer outside the rolled-back transaction
+// so the attempt stays visible and replays of its key return the same outcome.
+func (s *TransferService) recordFailure(req *models.TransferCreateRequest, transfer *models.Transfer, reason string, cause error) (*models.Transfer, error) {
+	transfer.ID = 0
+	transfer.Status = models.TransferStatusFailed
+	transfer.FailReason = reason
+	err := s.transaction(func(tx repository.Store) error {
+		if err := tx.Transfers().Create(transfer); err != nil {
+			return err
+		}
+		return enqueueEvent(tx, models.WebhookTransferFailed, transfer)
+	})
+	if err != nil {
+		if req.IdempotencyKey != "" {
+			existing, replayErr := s.findReplay(s.store, req)
+			if existing != nil || replayErr != nil {
+				return existing, replayErr
 			}
+		}
+		return nil, err
+	}
+	return transfer, cause
+}
+
+// findReplay looks up an earlier transfer made with req.IdempotencyKey.
+// It returns nil, nil when the key has not been used yet.
+func (s *TransferService) findReplay(store repository.Store, req *models.TransferCreateRequest) (*models.Transfer, error) {
+	existing, err := store.Transfers().FindByIdemKey(req.IdempotencyKey)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if existing.FromUserID != req.FromUserID ||
+		existing.ToUserID != req.ToUserID ||
+		existing.Amount != req.Amount ||
+		existing.Note != req.Note ||
+		(existing.HoldExpiresAt != nil) != req.Hold {
+		return nil, ErrIdempotencyKeyUsed
+	}
+
+	if existing.Status == models.TransferStatusFailed {
+		return existing, ErrInsufficientPoints
+	}
+	return existing, nil
+}
+
+// ReverseTransfer moves the points of a completed transfer back from the
+// receiver to the sender and marks the transfer reversed
+func (s *TransferService) ReverseTransfer(idemKey, reason string) (*models.Transfer, error) {
+	var transfer *models.Transfer
+
+	err := s.transaction(func(tx repository.Store) error {
+		var err error
+		if transfer, err = findTransfer(tx, idemKey); err != nil {
 			return err
 		}
 
-		// Check sufficient points
-		if fromUser.Points < req.Amount {
-			transfer.Status = models.TransferStatusFailed
-			transfer.FailReason = "Insufficient points"
-			if err := tx.Create(transfer).Error; err != nil {
-				return err
-			}
-			return ErrInsufficientPoints
+		if transfer.Status != models.TransferStatusCompleted {
+			return ErrInvalidStatus
 		}
 
-		// Update status to processing
-		transfer.Status = models.TransferStatusProcessing
-		if err := tx.Create(transfer).Error; err != nil {
+		fromUser, toUser, err := loadParties(tx, transfer.FromUserID, transfer.ToUserID)
+		if err != nil {
 			return err
 		}
 
-		// Deduct points from sender
-		fromUser.Points -= req.Amount
-		if err := tx.Save(&fromUser).Error; err != nil {
+		// Receiver must still have the transferred points available
+		if toUser.AvailablePoints() < transfer.Amount {
+			return ErrReceiverNoFunds
+		}
+
+		// Move points back to sender
+		toUser.Points -= transfer.Amount
+		if err := tx.Users().Save(toUser); err != nil {
 			return err
 		}
 
-		// Add points to receiver
-		toUser.Points += req.Amount
-		if err := tx.Save(&toUser).Error; err != nil {
+		fromUser.Points += transfer.Amount
+		if err := tx.Users().Save(fromUser); err != nil {
 			return err
 		}
 
-		// Create ledger entries
+		// Compensating ledger entries, linked to the original transfer
 		now := time.Now()
+		metadata := ledgerMetadata(map[string]interface{}{
+			"reversal": true,
+			"reason":   reason,
+		})
 
-		// Sender ledger
-		senderLedger := &models.PointLedger{
-			UserID:       fromUser.ID,
-			Change:       -req.Amount,
-			BalanceAfter: fromUser.Points,
+		receiverLedger := &models.PointLedger{
+			UserID:       toUser.ID,
+			Change:       -transfer.Amount,
+			BalanceAfter: toUser.Points,
 			EventType:    models.EventTypeTransferOut,
 			TransferID:   &transfer.ID,
-			Reference:    fmt.Sprintf("Transfer to user %d", toUser.ID),
+			Reference:    fmt.Sprintf("Reversal of transfer from user %d", fromUser.ID),
+			Metadata:     metadata,
 			CreatedAt:    now,
 		}
-		if err := tx.Create(senderLedger).Error; err != nil {
+		if err := appendLedger(tx, receiverLedger); err != nil {
 			return err
 		}
 
-		// Receiver ledger
-		receiverLedger := &models.PointLedger{
-			UserID:       toUser.ID,
-			Change:       req.Amount,
-			BalanceAfter: toUser.Points,
+		senderLedger := &models.PointLedger{
+			UserID:       fromUser.ID,
+			Change:       transfer.Amount,
+			BalanceAfter: fromUser.Points,
 			EventType:    models.EventTypeTransferIn,
 			TransferID:   &transfer.ID,
-			Reference:    fmt.Sprintf("Transfer from user %d", fromUser.ID),
+			Reference:    fmt.Sprintf("Reversal of transfer to user %d", toUser.ID),
+			Metadata:     metadata,
 			CreatedAt:    now,
 		}
-		if err := tx.Create(receiverLedger).Error; err != nil {
+		if err := appendLedger(tx, senderLedger); err != nil {
 			return err
 		}
 
-		// Mark transfer as completed
-		completedAt := time.Now()
-		transfer.Status = models.TransferStatusCompleted
-		transfer.CompletedAt = &completedAt
-		if err := tx.Save(transfer).Error; err != nil {
+		// Mark transfer as reversed
+		transfer.Status = models.TransferStatusReversed
+		transfer.Reason = reason
+		if err := tx.Transfers().Save(transfer); err != nil {
 			return err
 		}
 
-		return nil
+		return enqueueEvent(tx, models.WebhookTransferReversed, transfer)
 	})
 
 	if err != nil {
-		if errors.Is(err, ErrInsufficientPoints) {
-			return transfer, err
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+// CancelTransfer cancels a transfer that is still pending
+func (s *TransferService) CancelTransfer(idemKey, reason string) (*models.Transfer, error) {
+	var transfer *models.Transfer
+
+	err := s.transaction(func(tx repository.Store) error {
+		var err error
+		if transfer, err = findTransfer(tx, idemKey); err != nil {
+			return err
+		}
+
+		if transfer.Status != models.TransferStatusPending {
+			return ErrInvalidStatus
 		}
+
+		return cancelPending(tx, transfer, reason)
+	})
+
+	if err != nil {
 		return nil, err
 	}
 
 	return transfer, nil
 }
 
+// loadParties loads and locks the sender and receiver of a transfer inside
+// tx. Rows are locked in id order, so transfers in opposite directions
+// between the same users cannot deadlock.
+func loadParties(tx repository.Store, fromUserID, toUserID uint) (*models.User, *models.User, error) {
+	users, err := tx.Users().LockByIDs(fromUserID, toUserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var fromUser, toUser *models.User
+	for i := range users {
+		switch users[i].ID {
+		case fromUserID:
+			fromUser = &users[i]
+		case toUserID:
+			toUser = &users[i]
+		}
+	}
+	if fromUser == nil || toUser == nil {
+		return nil, nil, ErrUserNotFound
+	}
+
+	return fromUser, toUser, nil
+}
+
+// settleTransfer moves the points of a processing transfer, writes both
+// ledger entries and marks the transfer completed
+func settleTransfer(tx repository.Store, transfer *models.Transfer, fromUser, toUser *models.User) error {
+	// Deduct points from sender
+	fromUser.Points -= transfer.Amount
+	if err := tx.Users().Save(fromUser); err != nil {
+		return err
+	}
+
+	// Add points to receiver
+	toUser.Points += transfer.Amount
+	if err := tx.Users().Save(toUser); err != nil {
+		return err
+	}
+
+	// Create ledger entries
+	now := time.Now()
+
+	// Sender ledger
+	senderLedger := &models.PointLedger{
+		UserID:       fromUser.ID,
+		Change:       -transfer.Amount,
+		BalanceAfter: fromUser.Points,
+		EventType:    models.EventTypeTransferOut,
+		TransferID:   &transfer.ID,
+		Reference:    fmt.Sprintf("Transfer to user %d", toUser.ID),
+		CreatedAt:    now,
+	}
+	if err := appendLedger(tx, senderLedger); err != nil {
+		return err
+	}
+
+	// Receiver ledger
+	receiverLedger := &models.PointLedger{
+		UserID:       toUser.ID,
+		Change:       transfer.Amount,
+		BalanceAfter: toUser.Points,
+		EventType:    models.EventTypeTransferIn,
+		TransferID:   &transfer.ID,
+		Reference:    fmt.Sprintf("Transfer from user %d", fromUser.ID),
+		CreatedAt:    now,
+	}
+	if err := appendLedger(tx, receiverLedger); err != nil {
+		return err
+	}
+
+	// Mark transfer as completed
+	completedAt := time.Now()
+	transfer.Status = models.TransferStatusCompleted
+	transfer.CompletedAt = &completedAt
+	if err := tx.Transfers().Save(transfer); err != nil {
+		return err
+	}
+
+	return enqueueEvent(tx, models.WebhookTransferCompleted, transfer)
+}
+
+// findTransfer loads and locks a transfer by idempotency key inside tx
+func findTransfer(tx repository.Store, idemKey string) (*models.Transfer, error) {
+	transfer, err := tx.Transfers().LockByIdemKey(idemKey)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrTransferNotFound
+		}
+		return nil, err
+	}
+	return transfer, nil
+}
+
 // GetTransferByIdemKey retrieves a transfer by idempotency key
 func (s *TransferService) GetTransferByIdemKey(idemKey string) (*models.Transfer, error) {
-	var transfer models.Transfer
-	if err := s.db.Where("idempotency_key = ?", idemKey).First(&transfer).Error; err != nil {
+	transfer, err := s.store.Transfers().FindByIdemKey(idemKey)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrTransferNotFound
+		}
 		return nil, err
 	}
-	return &transfer, nil
+	return transfer, nil
 }
 
 // GetTransfersByUserID retrieves transfers for a user with pagination
@@ -165,26 +478,13 @@ func (s *TransferService) GetTransfersByUserID(userID uint, page, pageSize int)
 	if page < 1 {
 		page = 1
 	}
-	if pageSize < 1 || pageSize > 200 {
-		pageSize = 20
+	if pageSize < 1 || pageSize > s.maxPageSize {
+		pageSize = min(20, s.maxPageSize)
 	}
 
-	var transfers []models.Transfer
-	var total int64
-
-	// Count total
-	s.db.Model(&models.Transfer{}).
-		Where("from_user_id = ? OR to_user_id = ?", userID, userID).
-		Count(&total)
-
 	// Get paginated results
 	offset := (page - 1) * pageSize
-	err := s.db.Where("from_user_id = ? OR to_user_id = ?", userID, userID).
-		Order("created_at DESC").
-		Limit(pageSize).
-		Offset(offset).
-		Find(&transfers).Error
-
+	transfers, total, err := s.store.Transfers().ListByUser(userID, offset, pageSize)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"encoding/json"
+	"time"
+
+	"class-go-ai/events"
+	"class-go-ai/models"
+	"class-go-ai/repository"
+)
+
+// enqueueEvent writes a transfer event to the outbox inside tx, so it is
+// committed or rolled back together with the transfer change. The payload
+// is a snapshot of the transfer at this point.
+func enqueueEvent(tx repository.Store, eventType models.WebhookEvent, transfer *models.Transfer) error {
+	event := &models.OutboxEvent{
+		EventType:  eventType,
+		TransferID: transfer.ID,
+		FromUserID: transfer.FromUserID,
+		ToUserID:   transfer.ToUserID,
+		CreatedAt:  time.Now(),
+	}
+	if err := tx.Outbox().Create(event); err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(models.WebhookPayload{
+		ID:        event.ID,
+		Type:      eventType,
+		CreatedAt: event.CreatedAt,
+		Transfer:  transfer,
+	})
+	if err != nil {
+		return err
+	}
+
+	event.Payload = string(payload)
+	if err := tx.Outbox().Save(event); err != nil {
+		return err
+	}
+
+	// Also stream the change to live subscribers once committed
+	events.CollectTransfer(tx.Context(), transfer)
+	return nil
+}
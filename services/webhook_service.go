@@ -0,0 +1,438 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"class-go-ai/models"
+	"class-go-ai/signing"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrWebhookNotFound  = errors.New("webhook subscription not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+	ErrInvalidURL       = errors.New("url must be an absolute http or https URL")
+	ErrPrivateURL       = errors.New("url must not resolve to a private, loopback or link-local address")
+	ErrInvalidEvent     = errors.New("events must be transfer.completed, transfer.failed, transfer.reversed or transfer.cancelled")
+)
+
+const (
+	// DefaultWebhookMaxAttempts is how many times a delivery is tried before
+	// it is dead-lettered
+	DefaultWebhookMaxAttempts = 8
+	// DefaultWebhookBackoff is the delay after the first failed attempt; it
+	// doubles with every further failure
+	DefaultWebhookBackoff = 30 * time.Second
+	// maxWebhookBackoff caps the delay between attempts
+	maxWebhookBackoff = 6 * time.Hour
+	// webhookBatchSize bounds the work done per worker tick
+	webhookBatchSize = 100
+)
+
+// Delivery request headers, next to the signing headers
+const (
+	HeaderWebhookEvent    = "X-Webhook-Event"
+	HeaderWebhookDelivery = "X-Webhook-Delivery"
+)
+
+// WebhookService manages webhook subscriptions and delivers outbox events
+type WebhookService struct {
+	db           *gorm.DB
+	client       *http.Client
+	maxAttempts  int
+	backoff      time.Duration
+	allowPrivate bool
+	seesAllUsers func(role models.Role) bool
+}
+
+// NewWebhookService creates a new webhook service
+func NewWebhookService(db *gorm.DB) *WebhookService {
+	s := &WebhookService{
+		db:          db,
+		maxAttempts: DefaultWebhookMaxAttempts,
+		backoff:     DefaultWebhookBackoff,
+	}
+
+	// Every dialled address is checked, so a host that resolved to a public
+	// address when subscribed cannot be rebound to an internal one. Proxies
+	// are skipped so the check sees the target itself.
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: s.checkDial}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	s.client = &http.Client{Timeout: 10 * time.Second, Transport: transport}
+	return s
+}
+
+// SetRetryPolicy changes how often and how far apart deliveries are retried
+func (s *WebhookService) SetRetryPolicy(maxAttempts int, backoff time.Duration) {
+	s.maxAttempts = maxAttempts
+	s.backoff = backoff
+}
+
+// SetAllUsersPermission sets the check a subscription owner's current role
+// must pass for an AllUsers subscription to receive other users' transfers.
+// It runs on every dispatch, so demoting the owner narrows the subscription
+// to their own transfers. Without it no subscription sees other users'.
+func (s *WebhookService) SetAllUsersPermission(allowed func(role models.Role) bool) {
+	s.seesAllUsers = allowed
+}
+
+// SetAllowPrivateTargets lets subscriptions and deliveries reach private,
+// loopback and link-local addresses, for local development and tests
+func (s *WebhookService) SetAllowPrivateTargets(allow bool) {
+	s.allowPrivate = allow
+}
+
+// checkDial refuses connections to internal addresses; it runs after DNS
+// resolution, on the address actually dialled
+func (s *WebhookService) checkDial(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || (!s.allowPrivate && internalIP(ip)) {
+		return fmt.Errorf("%w: %s", ErrPrivateURL, host)
+	}
+	return nil
+}
+
+// checkHost resolves host and refuses it if any of its addresses is internal
+func (s *WebhookService) checkHost(host string) error {
+	if s.allowPrivate {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("%w: %s does not resolve", ErrInvalidURL, host)
+	}
+	for _, addr := range addrs {
+		if internalIP(addr.IP) {
+			return ErrPrivateURL
+		}
+	}
+	return nil
+}
+
+// internalIP reports whether ip is private, loopback, link-local or
+// unspecified, such as 10.0.0.1, 127.0.0.1 or the 169.254.169.254 metadata
+// endpoint
+func internalIP(ip net.IP) bool {
+	return ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsUnspecified()
+}
+
+// CreateSubscription registers a URL for transfer events. allUsers lets the
+// subscription see every transfer instead of only the owner's. The signing
+// secret is returned alongside, generated if req has none.
+func (s *WebhookService) CreateSubscription(ownerID uint, req *models.WebhookCreateRequest, allUsers bool) (*models.WebhookSubscription, string, error) {
+	target, err := url.Parse(req.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, "", ErrInvalidURL
+	}
+	if err := s.checkHost(target.Hostname()); err != nil {
+		return nil, "", err
+	}
+
+	for _, event := range req.Events {
+		if !event.Valid() {
+			return nil, "", ErrInvalidEvent
+		}
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		raw := make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, "", err
+		}
+		secret = "whsec_" + hex.EncodeToString(raw)
+	}
+
+	events := req.Events
+	if events == nil {
+		events = []models.WebhookEvent{}
+	}
+
+	subscription := &models.WebhookSubscription{
+		OwnerID:  ownerID,
+		URL:      req.URL,
+		Secret:   secret,
+		Events:   events,
+		AllUsers: allUsers,
+	}
+	if err := s.db.Create(subscription).Error; err != nil {
+		return nil, "", err
+	}
+
+	return subscription, secret, nil
+}
+
+// ListSubscriptions returns the subscriptions owned by a user
+func (s *WebhookService) ListSubscriptions(ownerID uint) ([]models.WebhookSubscription, error) {
+	var subscriptions []models.WebhookSubscription
+	err := s.db.Where("owner_id = ?", ownerID).Order("id DESC").Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+// GetSubscription retrieves a subscription by ID
+func (s *WebhookService) GetSubscription(id uint) (*models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+	if err := s.db.First(&subscription, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// DeleteSubscription removes a subscription; its pending deliveries are
+// dead-lettered
+func (s *WebhookService) DeleteSubscription(id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.WebhookSubscription{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrWebhookNotFound
+		}
+
+		return tx.Model(&models.WebhookDelivery{}).
+			Where("subscription_id = ? AND status = ?", id, models.DeliveryPending).
+			Updates(map[string]interface{}{
+				"status":     models.DeliveryDead,
+				"last_error": "Subscription deleted",
+			}).Error
+	})
+}
+
+// ListDeliveries returns the most recent deliveries of a subscription
+func (s *WebhookService) ListDeliveries(subscriptionID uint, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := s.db.Where("subscription_id = ?", subscriptionID).
+		Order("id DESC").
+		Limit(limit).
+		Find(&deliveries).Error
+	return deliveries, err
+}
+
+// Redeliver queues a delivery of subscriptionID again, whatever its state,
+// with a fresh set of attempts
+func (s *WebhookService) Redeliver(subscriptionID, deliveryID uint) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	err := s.db.Where("id = ? AND subscription_id = ?", deliveryID, subscriptionID).First(&delivery).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	delivery.Status = models.DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now()
+	delivery.LastError = ""
+	if err := s.db.Save(&delivery).Error; err != nil {
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+// Dispatch fans undispatched outbox events out into one delivery per
+// matching subscription and returns how many events it handled
+func (s *WebhookService) Dispatch(now time.Time) (int, error) {
+	var events []models.OutboxEvent
+	err := s.db.Where("dispatched_at IS NULL").Order("id").Limit(webhookBatchSize).Find(&events).Error
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+
+	var subscriptions []models.WebhookSubscription
+	if err := s.db.Find(&subscriptions).Error; err != nil {
+		return 0, err
+	}
+	seesAll, err := s.ownersSeeingAllUsers(subscriptions)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, event := range events {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			for _, subscription := range subscriptions {
+				if !subscription.Wants(event.EventType) {
+					continue
+				}
+				if !seesAll[subscription.OwnerID] &&
+					subscription.OwnerID != event.FromUserID && subscription.OwnerID != event.ToUserID {
+					continue
+				}
+
+				delivery := &models.WebhookDelivery{
+					SubscriptionID: subscription.ID,
+					EventID:        event.ID,
+					EventType:      event.EventType,
+					Status:         models.DeliveryPending,
+					NextAttemptAt:  now,
+				}
+				if err := tx.Create(delivery).Error; err != nil {
+					return err
+				}
+			}
+
+			return tx.Model(&event).Update("dispatched_at", now).Error
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(events), nil
+}
+
+// ownersSeeingAllUsers returns the owners of AllUsers subscriptions whose
+// current role still lets them receive every user's transfers. Deleted
+// owners are left out.
+func (s *WebhookService) ownersSeeingAllUsers(subscriptions []models.WebhookSubscription) (map[uint]bool, error) {
+	var ownerIDs []uint
+	for _, subscription := range subscriptions {
+		if subscription.AllUsers {
+			ownerIDs = append(ownerIDs, subscription.OwnerID)
+		}
+	}
+	seesAll := make(map[uint]bool)
+	if len(ownerIDs) == 0 || s.seesAllUsers == nil {
+		return seesAll, nil
+	}
+
+	var owners []models.User
+	if err := s.db.Select("id", "role").Where("id IN ?", ownerIDs).Find(&owners).Error; err != nil {
+		return nil, err
+	}
+	for _, owner := range owners {
+		seesAll[owner.ID] = s.seesAllUsers(owner.Role)
+	}
+	return seesAll, nil
+}
+
+// DeliverDue attempts every pending delivery that is due and returns how
+// many succeeded. Failures are rescheduled with exponential backoff and
+// dead-lettered after the last attempt. Once ctx is done no further
+// delivery is started; the one being sent still finishes, so shutting
+// down does not count as a failed attempt.
+func (s *WebhookService) DeliverDue(ctx context.Context, now time.Time) (int, error) {
+	var deliveries []models.WebhookDelivery
+	err := s.db.Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, now).
+		Order("next_attempt_at").
+		Limit(webhookBatchSize).
+		Find(&deliveries).Error
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for i := range deliveries {
+		if ctx.Err() != nil {
+			break
+		}
+		ok, err := s.attempt(context.WithoutCancel(ctx), &deliveries[i], now)
+		if err != nil {
+			return delivered, err
+		}
+		if ok {
+			delivered++
+		}
+	}
+
+	return delivered, nil
+}
+
+// attempt sends one delivery and records the outcome
+func (s *WebhookService) attempt(ctx context.Context, delivery *models.WebhookDelivery, now time.Time) (bool, error) {
+	var subscription models.WebhookSubscription
+	if err := s.db.Unscoped().First(&subscription, delivery.SubscriptionID).Error; err != nil {
+		return false, err
+	}
+	var event models.OutboxEvent
+	if err := s.db.First(&event, delivery.EventID).Error; err != nil {
+		return false, err
+	}
+
+	statusCode, sendErr := s.send(ctx, &subscription, delivery, []byte(event.Payload))
+
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+	if sendErr == nil {
+		delivery.Status = models.DeliveryDelivered
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+	} else {
+		delivery.LastError = sendErr.Error()
+		if delivery.Attempts >= s.maxAttempts {
+			delivery.Status = models.DeliveryDead
+		} else {
+			delivery.NextAttemptAt = now.Add(s.retryDelay(delivery.Attempts))
+		}
+	}
+
+	if err := s.db.Save(delivery).Error; err != nil {
+		return false, err
+	}
+	return sendErr == nil, nil
+}
+
+// send POSTs the signed payload and treats any 2xx response as success
+func (s *WebhookService) send(ctx context.Context, subscription *models.WebhookSubscription, delivery *models.WebhookDelivery, payload []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderWebhookEvent, string(delivery.EventType))
+	req.Header.Set(HeaderWebhookDelivery, strconv.FormatUint(uint64(delivery.ID), 10))
+	if err := signing.SignRequest(req, []byte(subscription.Secret)); err != nil {
+		return 0, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// retryDelay returns the backoff after the given number of failed attempts
+func (s *WebhookService) retryDelay(attempts int) time.Duration {
+	delay := s.backoff
+	for i := 1; i < attempts && delay < maxWebhookBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxWebhookBackoff {
+		delay = maxWebhookBackoff
+	}
+	return delay
+}
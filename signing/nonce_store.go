@@ -0,0 +1,68 @@
+package signing
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// NonceStore remembers nonces until their request could no longer pass the
+// timestamp check, so each signed request is accepted at most once. It is
+// in memory, so replay protection is per process: replicas behind a load
+// balancer each accept a nonce once.
+type NonceStore struct {
+	mu      sync.Mutex
+	expiry  map[string]time.Time
+	pending nonceHeap // every recorded nonce, soonest expiry first
+}
+
+// NewNonceStore creates an empty nonce store
+func NewNonceStore() *NonceStore {
+	return &NonceStore{expiry: make(map[string]time.Time)}
+}
+
+// Use records nonce until expiresAt and reports false if it is already
+// recorded. Expired nonces are dropped first, soonest expiry first, so each
+// call only touches the nonces that expired since the last one.
+func (s *NonceStore) Use(nonce string, now, expiresAt time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.pending) > 0 && now.After(s.pending[0].expiresAt) {
+		expired := heap.Pop(&s.pending).(nonceEntry)
+		delete(s.expiry, expired.nonce)
+	}
+
+	if _, seen := s.expiry[nonce]; seen {
+		return false
+	}
+	s.expiry[nonce] = expiresAt
+	heap.Push(&s.pending, nonceEntry{nonce: nonce, expiresAt: expiresAt})
+	return true
+}
+
+// Len returns how many nonces are remembered
+func (s *NonceStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.expiry)
+}
+
+type nonceEntry struct {
+	nonce     string
+	expiresAt time.Time
+}
+
+// nonceHeap is a container/heap of nonces ordered by expiry
+type nonceHeap []nonceEntry
+
+func (h nonceHeap) Len() int           { return len(h) }
+func (h nonceHeap) Less(i, j int) bool { return h[i].expiresAt.Before(h[j].expiresAt) }
+func (h nonceHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *nonceHeap) Push(x any)        { *h = append(*h, x.(nonceEntry)) }
+func (h *nonceHeap) Pop() any {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
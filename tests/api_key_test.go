@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"class-go-ai/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestAPIKey_TreasuryBatchPayout(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	app, auth := setupAuthApp(t, db)
+
+	admin, _ := auth.Register(&models.RegisterRequest{Name: "KeyAdmin", Email: "keyadmin@test.com", Password: "password-a"})
+	member, _ := auth.Register(&models.RegisterRequest{Name: "KeyMember", Email: "keymember@test.com", Password: "password-m"})
+	auth.SetRole(admin.User.ID, models.RoleAdmin)
+
+	treasury := &models.User{Name: "Treasury", Email: "treasury@test.com", Points: 1000}
+	payee1 := &models.User{Name: "Payee1", Email: "payee1@test.com"}
+	payee2 := &models.User{Name: "Payee2", Email: "payee2@test.com"}
+	db.Create(treasury)
+	db.Create(payee1)
+	db.Create(payee2)
+
+	// Only roles that can move points may bind a key to another account
+	create := fiber.Map{"name": "payouts", "scopes": []string{"transfers:write"}, "sourceUserId": treasury.ID}
+	if status := doJSON(t, app, "POST", "/api-keys", member.AccessToken, create, nil); status != 403 {
+		t.Errorf("Expected 403 for a member, got %d", status)
+	}
+
+	var created models.APIKeyResponse
+	if status := doJSON(t, app, "POST", "/api-keys", admin.AccessToken, create, &created); status != 201 || created.Key == "" {
+		t.Fatalf("Expected 201 with a key, got %d %+v", status, created)
+	}
+
+	var stored models.APIKey
+	db.First(&stored, created.APIKey.ID)
+	if stored.KeyHash == "" || stored.KeyHash == created.Key {
+		t.Errorf("Expected only a hash to be stored, got %q", stored.KeyHash)
+	}
+
+	headers := map[string]string{"X-API-Key": created.Key}
+	batch := fiber.Map{
+		"mode": "atomic",
+		"items": []fiber.Map{
+			{"toUserId": payee1.ID, "amount": 100},
+			{"toUserId": payee2.ID, "amount": 200},
+		},
+	}
+	var response models.TransferBatchResponse
+	if status := doJSONHeaders(t, app, "POST", "/transfers/batch", "", headers, batch, &response); status != 201 || response.Succeeded != 2 {
+		t.Fatalf("Expected 2 payouts, got %d %+v", status, response)
+	}
+	if response.Results[0].Transfer.FromUserID != treasury.ID {
+		t.Errorf("Expected payouts from the treasury, got %d", response.Results[0].Transfer.FromUserID)
+	}
+
+	var reloaded models.User
+	db.First(&reloaded, treasury.ID)
+	if reloaded.Points != 700 {
+		t.Errorf("Expected treasury to have 700 points, got %d", reloaded.Points)
+	}
+
+	db.First(&stored, created.APIKey.ID)
+	if stored.LastUsedAt == nil {
+		t.Error("Expected LastUsedAt to be recorded")
+	}
+
+	// Scopes limit the routes a key can call
+	if status := doJSONHeaders(t, app, "GET", "/transfers", "", headers, nil, nil); status != 403 {
+		t.Errorf("Expected 403 without transfers:read, got %d", status)
+	}
+	if status := doJSONHeaders(t, app, "GET", "/users", "", headers, nil, nil); status != 401 {
+		t.Errorf("Expected session-only routes to reject keys, got %d", status)
+	}
+
+	// Rotation replaces the key
+	var rotated models.APIKeyResponse
+	path := fmt.Sprintf("/api-keys/%d/rotate", created.APIKey.ID)
+	if status := doJSON(t, app, "POST", path, admin.AccessToken, nil, &rotated); status != 201 || rotated.Key == created.Key {
+		t.Fatalf("Expected a new key, got %d %+v", status, rotated)
+	}
+	if status := doJSONHeaders(t, app, "POST", "/transfers", "", headers, fiber.Map{"toUserId": payee1.ID, "amount": 1}, nil); status != 401 {
+		t.Errorf("Expected the rotated-out key to be rejected, got %d", status)
+	}
+
+	// Revocation disables the replacement too
+	headers["X-API-Key"] = rotated.Key
+	if status := doJSON(t, app, "DELETE", fmt.Sprintf("/api-keys/%d", rotated.APIKey.ID), member.AccessToken, nil, nil); status != 404 {
+		t.Errorf("Expected another user's key to be hidden, got %d", status)
+	}
+	if status := doJSON(t, app, "DELETE", fmt.Sprintf("/api-keys/%d", rotated.APIKey.ID), admin.AccessToken, nil, nil); status != 200 {
+		t.Errorf("Expected revoke to succeed, got %d", status)
+	}
+	if status := doJSONHeaders(t, app, "POST", "/transfers", "", headers, fiber.Map{"toUserId": payee1.ID, "amount": 1}, nil); status != 401 {
+		t.Errorf("Expected the revoked key to be rejected, got %d", status)
+	}
+
+	list := fiber.Map{}
+	doJSON(t, app, "GET", "/api-keys", admin.AccessToken, nil, &list)
+	if len(list["data"].([]interface{})) != 2 {
+		t.Errorf("Expected 2 keys for the admin, got %v", list["data"])
+	}
+}
+
+func TestAPIKey_DemotedOwnerLosesOtherAccounts(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	app, auth := setupAuthApp(t, db)
+
+	boss, _ := auth.Register(&models.RegisterRequest{Name: "KeyBoss", Email: "keyboss@test.com", Password: "password-b"})
+	owner, _ := auth.Register(&models.RegisterRequest{Name: "KeyOwner", Email: "keyowner@test.com", Password: "password-o"})
+	auth.SetRole(boss.User.ID, models.RoleAdmin)
+	auth.SetRole(owner.User.ID, models.RoleAdmin)
+
+	treasury := &models.User{Name: "DemoteTreasury", Email: "demotetreasury@test.com", Points: 1000}
+	db.Create(treasury)
+
+	var treasuryKey, ownKey models.APIKeyResponse
+	doJSON(t, app, "POST", "/api-keys", owner.AccessToken, fiber.Map{"name": "payouts", "scopes": []string{"transfers:read"}, "sourceUserId": treasury.ID}, &treasuryKey)
+	doJSON(t, app, "POST", "/api-keys", owner.AccessToken, fiber.Map{"name": "own", "scopes": []string{"transfers:read"}}, &ownKey)
+	if status := doJSONHeaders(t, app, "GET", "/transfers", "", map[string]string{"X-API-Key": treasuryKey.Key}, nil, nil); status != 200 {
+		t.Fatalf("Expected 200 before the demotion, got %d", status)
+	}
+
+	if _, err := auth.SetRole(owner.User.ID, models.RoleMember); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if status := doJSONHeaders(t, app, "GET", "/transfers", "", map[string]string{"X-API-Key": treasuryKey.Key}, nil, nil); status != 401 {
+		t.Errorf("Expected the treasury key rejected after the demotion, got %d", status)
+	}
+	if status := doJSONHeaders(t, app, "GET", "/transfers", "", map[string]string{"X-API-Key": ownKey.Key}, nil, nil); status != 200 {
+		t.Errorf("Expected the owner's own key to keep working, got %d", status)
+	}
+}
@@ -0,0 +1,269 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"class-go-ai/config"
+	"class-go-ai/container"
+	"class-go-ai/models"
+	"class-go-ai/repository"
+	"class-go-ai/routes"
+	"class-go-ai/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// setupAuthApp wires the routes to db with a fixed JWT secret
+func setupAuthApp(t *testing.T, db *gorm.DB) (*fiber.App, *services.AuthService) {
+	return setupAuthAppWithConfig(t, db, config.Default())
+}
+
+// setupAuthAppWithConfig is setupAuthApp with other settings
+func setupAuthAppWithConfig(t *testing.T, db *gorm.DB, cfg *config.Config) (*fiber.App, *services.AuthService) {
+	app, c := setupContainerApp(t, db, cfg)
+	return app, c.Auth
+}
+
+// setupContainerApp builds an app with its own services on db, so apps of
+// different tests share no state
+func setupContainerApp(t *testing.T, db *gorm.DB, cfg *config.Config) (*fiber.App, *container.Container) {
+	if cfg.Auth.JWTSecret == "" {
+		cfg.Auth.JWTSecret = "test-secret"
+	}
+	c := container.New(cfg, db)
+
+	app := fiber.New()
+	routes.SetupRoutes(app, c)
+	return app, c
+}
+
+// doJSON sends a JSON request and decodes the response body into out
+func doJSON(t *testing.T, app *fiber.App, method, path, token string, body interface{}, out interface{}) int {
+	return doJSONHeaders(t, app, method, path, token, nil, body, out)
+}
+
+// doJSONHeaders is doJSON with extra request headers
+func doJSONHeaders(t *testing.T, app *fiber.App, method, path, token string, headers map[string]string, body interface{}, out interface{}) int {
+	var reader io.Reader
+	if body != nil {
+		payload, _ := json.Marshal(body)
+		reader = strings.NewReader(string(payload))
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Request %s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		json.NewDecoder(resp.Body).Decode(out)
+	}
+	return resp.StatusCode
+}
+
+func TestAuth_RegisterLoginRefreshLogout(t *testing.T) {
+	db := setupTestDB(t)
+	auth := services.NewAuthService(repository.NewGormStore(db), []byte("test-secret"))
+
+	registered, err := auth.Register(&models.RegisterRequest{Name: "AuthAlice", Email: "AuthAlice@test.com", Password: "correct-horse"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	userID, err := auth.ParseAccessToken(registered.AccessToken)
+	if err != nil || userID != registered.User.ID {
+		t.Fatalf("Expected token for user %d, got %d (%v)", registered.User.ID, userID, err)
+	}
+
+	if _, err := auth.Register(&models.RegisterRequest{Name: "AuthAlice2", Email: "authalice@test.com", Password: "correct-horse"}); !errors.Is(err, services.ErrEmailTaken) {
+		t.Errorf("Expected ErrEmailTaken, got: %v", err)
+	}
+	if _, err := auth.Login("authalice@test.com", "wrong-password"); !errors.Is(err, services.ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials, got: %v", err)
+	}
+
+	session, err := auth.Login("authalice@test.com", "correct-horse")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if err := auth.Logout(registered.RefreshToken); err != nil {
+		t.Errorf("Expected no error, got: %v", err)
+	}
+	if err := auth.Logout(registered.RefreshToken); !errors.Is(err, services.ErrInvalidToken) {
+		t.Errorf("Expected logged out token to be rejected, got: %v", err)
+	}
+
+	// Refresh rotates the refresh token
+	rotated, err := auth.Refresh(session.RefreshToken)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if rotated.RefreshToken == session.RefreshToken {
+		t.Error("Expected a new refresh token")
+	}
+
+	// Reusing the old token revokes the whole family
+	if _, err := auth.Refresh(session.RefreshToken); !errors.Is(err, services.ErrInvalidToken) {
+		t.Errorf("Expected ErrInvalidToken on reuse, got: %v", err)
+	}
+	if _, err := auth.Refresh(rotated.RefreshToken); !errors.Is(err, services.ErrInvalidToken) {
+		t.Errorf("Expected rotated token to be revoked after reuse, got: %v", err)
+	}
+
+	if _, err := auth.ParseAccessToken(registered.AccessToken + "x"); !errors.Is(err, services.ErrInvalidToken) {
+		t.Errorf("Expected tampered token to be rejected, got: %v", err)
+	}
+}
+
+func TestAuth_TransfersUseAuthenticatedUser(t *testing.T) {
+	db := setupTestDB(t)
+	app, auth := setupAuthApp(t, db)
+
+	alice, _ := auth.Register(&models.RegisterRequest{Name: "AuthAlice3", Email: "authalice3@test.com", Password: "password-a"})
+	bob, _ := auth.Register(&models.RegisterRequest{Name: "AuthBob3", Email: "authbob3@test.com", Password: "password-b"})
+	carol, _ := auth.Register(&models.RegisterRequest{Name: "AuthCarol3", Email: "authcarol3@test.com", Password: "password-c"})
+	db.Model(&models.User{}).Where("id = ?", bob.User.ID).Update("points", 100)
+
+	if status := doJSON(t, app, "GET", "/transfers", "", nil, nil); status != 401 {
+		t.Errorf("Expected 401 without token, got %d", status)
+	}
+
+	// Alice cannot spend Bob's points
+	body := fiber.Map{"fromUserId": bob.User.ID, "toUserId": alice.User.ID, "amount": 50}
+	if status := doJSON(t, app, "POST", "/transfers", alice.AccessToken, body, nil); status != 403 {
+		t.Errorf("Expected 403 for foreign fromUserId, got %d", status)
+	}
+
+	// Bob's transfer debits Bob even without fromUserId
+	var created models.TransferResponse
+	body = fiber.Map{"toUserId": alice.User.ID, "amount": 30}
+	if status := doJSON(t, app, "POST", "/transfers", bob.AccessToken, body, &created); status != 201 {
+		t.Fatalf("Expected 201, got %d", status)
+	}
+	if created.Transfer.FromUserID != bob.User.ID {
+		t.Errorf("Expected transfer from %d, got %d", bob.User.ID, created.Transfer.FromUserID)
+	}
+
+	path := "/transfers/" + created.Transfer.IdempotencyKey
+	if status := doJSON(t, app, "GET", path, alice.AccessToken, nil, nil); status != 200 {
+		t.Errorf("Expected receiver to see the transfer, got %d", status)
+	}
+	if status := doJSON(t, app, "GET", path, carol.AccessToken, nil, nil); status != 404 {
+		t.Errorf("Expected 404 for an unrelated user, got %d", status)
+	}
+
+	list := fiber.Map{}
+	if status := doJSON(t, app, "GET", "/transfers", carol.AccessToken, nil, &list); status != 200 || len(list["data"].([]interface{})) != 0 {
+		t.Errorf("Expected an empty list for an unrelated user, got %d %v", status, list)
+	}
+	query := fmt.Sprintf("/transfers?userId=%d", bob.User.ID)
+	if status := doJSON(t, app, "GET", query, carol.AccessToken, nil, nil); status != 403 {
+		t.Errorf("Expected 403 listing another user's transfers, got %d", status)
+	}
+
+	// Only the receiver can reverse
+	reason := fiber.Map{"reason": "refund"}
+	if status := doJSON(t, app, "POST", path+"/reverse", bob.AccessToken, reason, nil); status != 403 {
+		t.Errorf("Expected 403 when the sender reverses, got %d", status)
+	}
+	if status := doJSON(t, app, "POST", path+"/reverse", alice.AccessToken, reason, nil); status != 200 {
+		t.Errorf("Expected receiver to reverse, got %d", status)
+	}
+}
+
+func TestAuth_UpdateUserEmail(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	app, auth := setupAuthApp(t, db)
+
+	alice, _ := auth.Register(&models.RegisterRequest{Name: "EmailAlice", Email: "emailalice@test.com", Password: "correct-horse"})
+	auth.Register(&models.RegisterRequest{Name: "EmailBob", Email: "emailbob@test.com", Password: "correct-horse"})
+	path := fmt.Sprintf("/users/%d", alice.User.ID)
+
+	// Another user's email is taken whatever its case
+	if status := doJSON(t, app, "PUT", path, alice.AccessToken, fiber.Map{"name": "EmailAlice", "email": " EmailBob@Test.com "}, nil); status != 409 {
+		t.Errorf("Expected 409, got: %d", status)
+	}
+
+	var updated models.User
+	if status := doJSON(t, app, "PUT", path, alice.AccessToken, fiber.Map{"name": "EmailAlice", "email": " Alice.New@Test.com "}, &updated); status != 200 {
+		t.Fatalf("Expected 200, got: %d", status)
+	}
+	if updated.Email != "alice.new@test.com" {
+		t.Errorf("Expected the email normalized, got: %q", updated.Email)
+	}
+	if _, err := auth.Login("Alice.New@test.com", "correct-horse"); err != nil {
+		t.Errorf("Expected login with the new email, got: %v", err)
+	}
+
+	// Keeping one's own email is not a conflict
+	if status := doJSON(t, app, "PUT", path, alice.AccessToken, fiber.Map{"name": "EmailAlice2", "email": "alice.new@test.com"}, nil); status != 200 {
+		t.Errorf("Expected 200, got: %d", status)
+	}
+}
+
+func TestAuth_CreateUserEmail(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	app, auth := setupAuthApp(t, db)
+
+	admin, _ := auth.Register(&models.RegisterRequest{Name: "CreateAdmin", Email: "createadmin@test.com", Password: "correct-horse"})
+	auth.SetRole(admin.User.ID, models.RoleAdmin)
+
+	// An existing email is taken whatever its case
+	if status := doJSON(t, app, "POST", "/users", admin.AccessToken, fiber.Map{"name": "Copy", "email": " CreateAdmin@Test.com "}, nil); status != 409 {
+		t.Errorf("Expected 409, got: %d", status)
+	}
+
+	var created models.User
+	if status := doJSON(t, app, "POST", "/users", admin.AccessToken, fiber.Map{"name": "Created", "email": " New.User@Test.com "}, &created); status != 201 {
+		t.Fatalf("Expected 201, got: %d", status)
+	}
+	if created.Email != "new.user@test.com" {
+		t.Errorf("Expected the email normalized, got: %q", created.Email)
+	}
+	if status := doJSON(t, app, "POST", "/users", admin.AccessToken, fiber.Map{"name": "Blank", "email": "   "}, nil); status != 400 {
+		t.Errorf("Expected 400 for a blank email, got: %d", status)
+	}
+}
+
+func TestAuth_RegisterPasswordLength(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	app, _ := setupAuthApp(t, db)
+
+	// bcrypt only hashes 72 bytes; longer passwords are refused up front
+	cases := []struct {
+		password string
+		status   int
+	}{
+		{"short", 400},
+		{strings.Repeat("a", 73), 400},
+		{strings.Repeat("a", 72), 201},
+	}
+	for i, tc := range cases {
+		var body map[string]string
+		request := fiber.Map{"name": "LengthUser", "email": fmt.Sprintf("length%d@test.com", i), "password": tc.password}
+		if status := doJSON(t, app, "POST", "/auth/register", "", request, &body); status != tc.status {
+			t.Errorf("Password of %d bytes: expected %d, got: %d %v", len(tc.password), tc.status, status, body)
+		}
+		if tc.status == 400 && body["error"] != "VALIDATION_ERROR" {
+			t.Errorf("Password of %d bytes: expected VALIDATION_ERROR, got: %v", len(tc.password), body)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"class-go-ai/config"
+	"class-go-ai/models"
+	"class-go-ai/repository"
+	"class-go-ai/services"
+)
+
+func TestConfig_FileThenEnvThenFlags(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(file, []byte(`{
+		"server": {"port": 8080},
+		"database": {"url": "file.db"},
+		"cors": {"allowOrigins": ["https://app.example.com"], "allowCredentials": true},
+		"limits": {"maxPageSize": 50, "holdTtl": "30m"}
+	}`), 0o600)
+
+	t.Setenv("CONFIG_FILE", file)
+	t.Setenv("DATABASE_URL", "env.db")
+	t.Setenv("MAX_PAGE_SIZE", "75")
+	t.Setenv("JWT_SECRET", "env-secret")
+
+	cfg, err := config.Load([]string{"-max-page-size", "100"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if cfg.Server.Port != 8080 || time.Duration(cfg.Limits.HoldTTL) != 30*time.Minute || !cfg.CORS.AllowCredentials {
+		t.Errorf("Expected file settings, got: %+v", cfg)
+	}
+	if cfg.Database.URL != "env.db" || cfg.Auth.JWTSecret != "env-secret" {
+		t.Errorf("Expected env to override the file, got: %+v", cfg)
+	}
+	if cfg.Limits.MaxPageSize != 100 {
+		t.Errorf("Expected the flag to override env, got: %d", cfg.Limits.MaxPageSize)
+	}
+	if cfg.Server.BodyLimit != config.Default().Server.BodyLimit {
+		t.Errorf("Expected unset settings to keep their default, got: %d", cfg.Server.BodyLimit)
+	}
+}
+
+func TestConfig_RejectsInvalidSettings(t *testing.T) {
+	t.Setenv("HOLD_TTL", "soon")
+	if _, err := config.Load(nil); err == nil || !strings.Contains(err.Error(), "HOLD_TTL") {
+		t.Errorf("Expected a HOLD_TTL error, got: %v", err)
+	}
+
+	cfg := config.Default()
+	cfg.Server.Port = 70000
+	cfg.CORS.AllowCredentials = true
+	cfg.Limits.MaxPageSize = 0
+	cfg.Server.ShutdownTimeout = 0
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Expected validation to fail")
+	}
+	for _, setting := range []string{"server.port", "server.shutdownTimeout", "cors.allowCredentials", "limits.maxPageSize"} {
+		if !strings.Contains(err.Error(), setting) {
+			t.Errorf("Expected %s to be reported, got: %v", setting, err)
+		}
+	}
+
+	file := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(file, []byte(`{"server": {"prot": 8080}}`), 0o600)
+	if _, err := config.Load([]string{"-config", file}); err == nil {
+		t.Error("Expected an unknown key to be rejected")
+	}
+}
+
+func TestConfig_AppliesToRoutesAndServices(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	cfg := config.Default()
+	cfg.CORS.AllowOrigins = []string{"https://app.example.com"}
+	app, auth := setupAuthAppWithConfig(t, db, cfg)
+
+	preflight := func(origin string) string {
+		req := httptest.NewRequest("OPTIONS", "/transfers", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		return resp.Header.Get("Access-Control-Allow-Origin")
+	}
+	if got := preflight("https://app.example.com"); got != "https://app.example.com" {
+		t.Errorf("Expected the configured origin to be allowed, got: %q", got)
+	}
+	if got := preflight("https://evil.example.com"); got != "" {
+		t.Errorf("Expected other origins to be refused, got: %q", got)
+	}
+
+	alice, _ := auth.Register(&models.RegisterRequest{Name: "PageAlice", Email: "pagealice@test.com", Password: "correct-horse"})
+	bob := &models.User{Name: "PageBob", Email: "pagebob@test.com"}
+	db.Create(bob)
+	db.Model(&models.User{}).Where("id = ?", alice.User.ID).Update("points", 100)
+
+	transfers := services.NewTransferService(repository.NewGormStore(db))
+	transfers.SetMaxPageSize(2)
+	for i := 0; i < 3; i++ {
+		transfers.CreateTransfer(&models.TransferCreateRequest{FromUserID: alice.User.ID, ToUserID: bob.ID, Amount: 1})
+	}
+
+	// A page size above the limit falls back to the default, capped by the limit
+	page, err := transfers.GetTransfersByUserID(alice.User.ID, 1, 50)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if page.PageSize != 2 || len(page.Data) != 2 || page.Total != 3 {
+		t.Errorf("Expected a page of 2 out of 3, got size %d with %d of %d", page.PageSize, len(page.Data), page.Total)
+	}
+}
@@ -0,0 +1,43 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"class-go-ai/config"
+	"class-go-ai/models"
+)
+
+func TestContainer_AppsAreIsolated(t *testing.T) {
+	firstCfg := config.Default()
+	firstCfg.Auth.JWTSecret = "first-secret"
+	first, firstC := setupContainerApp(t, openTestDB(t, "file:"+t.Name()+"_first?mode=memory&cache=shared"), firstCfg)
+	second, secondC := setupContainerApp(t, openTestDB(t, "file:"+t.Name()+"_second?mode=memory&cache=shared"), config.Default())
+
+	// The same email registers once per app, each on its own database
+	a, err := firstC.Auth.Register(&models.RegisterRequest{Name: "Iso", Email: "iso@test.com", Password: "correct-horse"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, err := secondC.Auth.Register(&models.RegisterRequest{Name: "Iso", Email: "iso@test.com", Password: "correct-horse"}); err != nil {
+		t.Fatalf("Expected the second app not to see the first app's user, got: %v", err)
+	}
+
+	path := fmt.Sprintf("/users/%d", a.User.ID)
+	if status := doJSON(t, first, "GET", path, a.AccessToken, nil, nil); status != 200 {
+		t.Errorf("Expected the token to work on its own app, got: %d", status)
+	}
+	if status := doJSON(t, second, "GET", path, a.AccessToken, nil, nil); status != 401 {
+		t.Errorf("Expected the other app to reject a token signed with another secret, got: %d", status)
+	}
+
+	// Events published by one app do not reach the other's subscribers
+	sub := secondC.Broker.Subscribe([]uint{a.User.ID}, 10)
+	defer sub.Close()
+	firstC.Points.Earn(a.User.ID, 10, "signup")
+	select {
+	case message := <-sub.Messages():
+		t.Errorf("Expected no event on the other app, got: %+v", message)
+	default:
+	}
+}
@@ -0,0 +1,249 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"class-go-ai/config"
+	"class-go-ai/events"
+	"class-go-ai/handlers"
+	"class-go-ai/models"
+	"class-go-ai/repository"
+	"class-go-ai/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// serve runs app on a local port until the test ends and returns its address
+func serve(t *testing.T, app *fiber.App) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go app.Listener(listener)
+	t.Cleanup(func() { app.ShutdownWithTimeout(time.Second) })
+	return listener.Addr().String()
+}
+
+// drain returns the messages waiting on sub without blocking
+func drain(sub *events.Subscription) []events.Message {
+	var messages []events.Message
+	for {
+		select {
+		case message := <-sub.Messages():
+			messages = append(messages, message)
+		default:
+			return messages
+		}
+	}
+}
+
+// sseEvent is one event read from a stream
+type sseEvent struct {
+	ID    string
+	Event string
+	Data  handlers.LedgerEvent
+}
+
+// readEvent reads the next event from an SSE stream, skipping comments
+func readEvent(t *testing.T, r *bufio.Reader) sseEvent {
+	var event sseEvent
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+
+		switch {
+		case line == "":
+			if event.ID != "" {
+				return event
+			}
+		case strings.HasPrefix(line, "id: "):
+			event.ID = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event.Event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event.Data)
+		}
+	}
+}
+
+func TestBroker_DropsForSlowSubscribers(t *testing.T) {
+	broker := events.NewBroker()
+	slow := broker.Subscribe([]uint{1}, 1)
+	both := broker.Subscribe([]uint{1, 2}, 10)
+
+	for i := 1; i <= 3; i++ {
+		broker.Publish(events.Message{Type: events.MessageLedger, Ledger: &models.PointLedger{ID: uint(i), UserID: 1}})
+	}
+	broker.Publish(events.Message{Type: events.MessageTransfer, Transfer: &models.Transfer{FromUserID: 1, ToUserID: 2}})
+
+	if got := len(drain(slow)); got != 1 {
+		t.Errorf("Expected 1 buffered message, got: %d", got)
+	}
+	if dropped := slow.TakeDropped(); dropped != 3 {
+		t.Errorf("Expected 3 dropped messages, got: %d", dropped)
+	}
+	if dropped := slow.TakeDropped(); dropped != 0 {
+		t.Errorf("Expected dropped count to reset, got: %d", dropped)
+	}
+	if broker.Dropped() != 3 {
+		t.Errorf("Expected 3 dropped overall, got: %d", broker.Dropped())
+	}
+
+	// A transfer between two watched users arrives once
+	if got := len(drain(both)); got != 4 {
+		t.Errorf("Expected 4 messages, got: %d", got)
+	}
+
+	slow.Close()
+	slow.Close()
+	if broker.Subscribers() != 1 {
+		t.Errorf("Expected 1 subscriber after close, got: %d", broker.Subscribers())
+	}
+}
+
+func TestTransferService_PublishesAfterCommit(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	broker := events.NewBroker()
+	service := services.NewTransferService(repository.NewGormStore(db))
+	service.SetPublisher(broker)
+
+	sender := &models.User{Name: "PubSender", Email: "pubsender@test.com", Points: 100}
+	receiver := &models.User{Name: "PubReceiver", Email: "pubreceiver@test.com"}
+	db.Create(sender)
+	db.Create(receiver)
+
+	sub := broker.Subscribe([]uint{receiver.ID}, 10)
+	defer sub.Close()
+
+	// A rolled back batch publishes nothing
+	service.CreateTransferBatch([]*models.TransferCreateRequest{
+		{FromUserID: sender.ID, ToUserID: receiver.ID, Amount: 60},
+		{FromUserID: sender.ID, ToUserID: receiver.ID, Amount: 60},
+	}, true)
+	if messages := drain(sub); len(messages) != 0 {
+		t.Fatalf("Expected no messages after rollback, got: %d", len(messages))
+	}
+
+	transfer, err := service.CreateTransfer(&models.TransferCreateRequest{FromUserID: sender.ID, ToUserID: receiver.ID, Amount: 60})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	var ledger *models.PointLedger
+	var completed *models.Transfer
+	for _, message := range drain(sub) {
+		switch message.Type {
+		case events.MessageLedger:
+			ledger = message.Ledger
+		case events.MessageTransfer:
+			completed = message.Transfer
+		}
+	}
+
+	if ledger == nil || ledger.EventType != models.EventTypeTransferIn || ledger.BalanceAfter != 60 {
+		t.Errorf("Expected transfer_in entry with balance 60, got: %+v", ledger)
+	}
+	if completed == nil || completed.ID != transfer.ID || completed.Status != models.TransferStatusCompleted {
+		t.Errorf("Expected completed transfer %d, got: %+v", transfer.ID, completed)
+	}
+}
+
+func TestEvents_StreamResumesFromLastEventID(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	app, c := setupContainerApp(t, db, config.Default())
+	auth, transfers, points := c.Auth, c.Transfers, c.Points
+
+	addr := serve(t, app)
+
+	alice, _ := auth.Register(&models.RegisterRequest{Name: "StreamAlice", Email: "streamalice@test.com", Password: "correct-horse"})
+	bob, _ := auth.Register(&models.RegisterRequest{Name: "StreamBob", Email: "streambob@test.com", Password: "correct-horse"})
+
+	// Only the user or a ledger reader may watch the stream
+	path := fmt.Sprintf("/users/%d/events", alice.User.ID)
+	if status := doJSON(t, app, "GET", path, bob.AccessToken, nil, nil); status != 403 {
+		t.Fatalf("Expected 403 for another member, got: %d", status)
+	}
+
+	first, _ := points.Earn(alice.User.ID, 100, "signup")
+	missed, _ := points.Earn(alice.User.ID, 20, "survey")
+
+	// Resuming after the first entry replays only what was missed
+	req, _ := http.NewRequest("GET", "http://"+addr+path, nil)
+	req.Header.Set("Authorization", "Bearer "+alice.AccessToken)
+	req.Header.Set("Last-Event-ID", fmt.Sprint(first.ID))
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	reader := bufio.NewReader(resp.Body)
+
+	event := readEvent(t, reader)
+	if event.ID != fmt.Sprint(missed.ID) || event.Event != string(models.EventTypeEarn) || event.Data.Balance != 120 {
+		t.Errorf("Expected missed earn entry %d with balance 120, got: %+v", missed.ID, event)
+	}
+
+	// Live entries follow once committed
+	if _, err := transfers.CreateTransfer(&models.TransferCreateRequest{FromUserID: alice.User.ID, ToUserID: bob.User.ID, Amount: 30}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	event = readEvent(t, reader)
+	if event.Event != string(models.EventTypeTransferOut) || event.Data.Balance != 90 || event.Data.Entry.Change != -30 {
+		t.Errorf("Expected transfer_out with balance 90, got: %+v", event)
+	}
+}
+
+func TestEvents_StreamReordersLateEntries(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	app, c := setupContainerApp(t, db, config.Default())
+	addr := serve(t, app)
+
+	alice, _ := c.Auth.Register(&models.RegisterRequest{Name: "ReorderAlice", Email: "reorderalice@test.com", Password: "correct-horse"})
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("http://%s/users/%d/events", addr, alice.User.ID), nil)
+	req.Header.Set("Authorization", "Bearer "+alice.AccessToken)
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+
+	// Commit two entries without publishing, then publish them newest first
+	// as two racing writers might
+	unpublished := services.NewPointsService(c.Store)
+	first, _ := unpublished.Earn(alice.User.ID, 100, "signup")
+	second, _ := unpublished.Earn(alice.User.ID, 20, "survey")
+	c.Broker.Publish(events.Message{Type: events.MessageLedger, Ledger: second})
+	c.Broker.Publish(events.Message{Type: events.MessageLedger, Ledger: first})
+
+	for _, want := range []*models.PointLedger{first, second} {
+		event := readEvent(t, reader)
+		if event.ID != fmt.Sprint(want.ID) || event.Data.Balance != want.BalanceAfter {
+			t.Errorf("Expected entry %d with balance %d, got: %+v", want.ID, want.BalanceAfter, event)
+		}
+	}
+
+	// A live entry continuing from the last one is sent as it arrives
+	third, _ := c.Points.Earn(alice.User.ID, 5, "bonus")
+	if event := readEvent(t, reader); event.ID != fmt.Sprint(third.ID) || event.Data.Balance != 125 {
+		t.Errorf("Expected entry %d with balance 125, got: %+v", third.ID, event)
+	}
+}
@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"class-go-ai/buildinfo"
+	"class-go-ai/config"
+	"class-go-ai/database"
+	"class-go-ai/models"
+)
+
+func TestHealth_ReadinessChecks(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	app, c := setupContainerApp(t, db, config.Default())
+	t.Cleanup(c.Workers.Stop)
+
+	if status := doJSON(t, app, "GET", "/healthz", "", nil, nil); status != 200 {
+		t.Errorf("Expected 200, got: %d", status)
+	}
+
+	var ready models.ReadinessResponse
+	if status := doJSON(t, app, "GET", "/readyz", "", nil, &ready); status != 200 || ready.Status != models.ReadinessReady {
+		t.Fatalf("Expected ready, got: %d %+v", status, ready)
+	}
+	for _, name := range []string{"database", "schema", "workers", "shutdown"} {
+		if ready.Checks[name] != "ok" {
+			t.Errorf("Expected %s to be ok, got: %q", name, ready.Checks[name])
+		}
+	}
+
+	// A worker that stops beating fails readiness
+	c.Workers.Go("stuck", time.Millisecond, func(ctx context.Context) { <-ctx.Done() })
+	time.Sleep(10 * time.Millisecond)
+	ready = models.ReadinessResponse{}
+	if status := doJSON(t, app, "GET", "/readyz", "", nil, &ready); status != 503 || ready.Checks["workers"] != "stale: stuck" {
+		t.Errorf("Expected the stale worker to fail readiness, got: %d %+v", status, ready)
+	}
+	c.Workers.Stop()
+	c.Workers.Wait(context.Background())
+
+	// So does a graceful shutdown, while the process stays healthy
+	c.Draining.Store(true)
+	ready = models.ReadinessResponse{}
+	if status := doJSON(t, app, "GET", "/readyz", "", nil, &ready); status != 503 || ready.Checks["shutdown"] != "draining" {
+		t.Errorf("Expected draining to fail readiness, got: %d %+v", status, ready)
+	}
+	if status := doJSON(t, app, "GET", "/healthz", "", nil, nil); status != 200 {
+		t.Errorf("Expected 200 while draining, got: %d", status)
+	}
+}
+
+func TestHealth_ReadinessFollowsDatabase(t *testing.T) {
+	// A private database, since this one is rolled back and closed
+	db := openTestDB(t, "file:"+t.Name()+"?mode=memory&cache=shared")
+	app, _ := setupContainerApp(t, db, config.Default())
+
+	migrator, _ := database.NewMigrator(db)
+	if _, err := migrator.Down(1); err != nil {
+		t.Fatalf("Failed to roll back: %v", err)
+	}
+	var ready models.ReadinessResponse
+	if status := doJSON(t, app, "GET", "/readyz", "", nil, &ready); status != 503 || ready.Checks["schema"] != "migrations pending" {
+		t.Errorf("Expected pending migrations to fail readiness, got: %d %+v", status, ready)
+	}
+
+	sqlDB, _ := db.DB()
+	sqlDB.Close()
+	ready = models.ReadinessResponse{}
+	if status := doJSON(t, app, "GET", "/readyz", "", nil, &ready); status != 503 || ready.Checks["database"] != "unreachable" {
+		t.Errorf("Expected a closed database to fail readiness, got: %d %+v", status, ready)
+	}
+}
+
+func TestHealth_Version(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	app, _ := setupContainerApp(t, db, config.Default())
+
+	buildinfo.Commit, buildinfo.BuildTime = "0123abc", "2026-01-02T03:04:05Z"
+	t.Cleanup(func() { buildinfo.Commit, buildinfo.BuildTime = "", "" })
+
+	var version models.VersionResponse
+	if status := doJSON(t, app, "GET", "/version", "", nil, &version); status != 200 {
+		t.Fatalf("Expected 200, got: %d", status)
+	}
+	if version.Commit != "0123abc" || version.BuildTime != "2026-01-02T03:04:05Z" || version.GoVersion == "" {
+		t.Errorf("Expected the stamped build details, got: %+v", version)
+	}
+	if version.SchemaVersion == nil || *version.SchemaVersion != version.LatestSchema || version.LatestSchema == 0 {
+		t.Errorf("Expected the latest schema version, got: %+v", version)
+	}
+}
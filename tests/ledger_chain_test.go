@@ -0,0 +1,143 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"class-go-ai/models"
+	"class-go-ai/repository"
+	"class-go-ai/services"
+
+	"gorm.io/gorm"
+)
+
+// seedChain writes a few ledger entries through the services
+func seedChain(t *testing.T, db *gorm.DB) []models.PointLedger {
+	points := services.NewPointsService(repository.NewGormStore(db))
+	transfers := services.NewTransferService(repository.NewGormStore(db))
+
+	user1 := &models.User{Name: "ChainAlice", Email: "chainalice@test.com"}
+	user2 := &models.User{Name: "ChainBob", Email: "chainbob@test.com"}
+	db.Create(user1)
+	db.Create(user2)
+
+	points.Earn(user1.ID, 500, "signup")
+	transfers.CreateTransfer(&models.TransferCreateRequest{FromUserID: user1.ID, ToUserID: user2.ID, Amount: 100})
+	points.Redeem(user2.ID, 30, "voucher")
+
+	var entries []models.PointLedger
+	db.Order("id").Find(&entries)
+	if len(entries) != 4 {
+		t.Fatalf("Expected 4 ledger entries, got: %d", len(entries))
+	}
+	return entries
+}
+
+func TestLedgerChain_Valid(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	entries := seedChain(t, db)
+
+	for i, entry := range entries {
+		if entry.Hash == "" {
+			t.Fatalf("Entry %d is not sealed", entry.ID)
+		}
+		if i > 0 && entry.PrevHash != entries[i-1].Hash {
+			t.Errorf("Entry %d does not link to entry %d", entry.ID, entries[i-1].ID)
+		}
+	}
+
+	result, err := services.NewLedgerService(repository.NewGormStore(db)).VerifyChain()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if !result.Valid || result.Checked != 4 || result.HeadHash != entries[3].Hash {
+		t.Errorf("Expected valid chain of 4 ending at the last hash, got: %+v", result)
+	}
+}
+
+func TestLedgerChain_DetectsAlteredRow(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	entries := seedChain(t, db)
+
+	db.Model(&models.PointLedger{}).Where("id = ?", entries[1].ID).Update("change", -1)
+
+	result, _ := services.NewLedgerService(repository.NewGormStore(db)).VerifyChain()
+	if result.Valid || result.FirstInvalidID == nil || *result.FirstInvalidID != entries[1].ID {
+		t.Errorf("Expected entry %d to be reported, got: %+v", entries[1].ID, result)
+	}
+}
+
+func TestLedgerChain_DetectsDeletedRow(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	entries := seedChain(t, db)
+
+	db.Delete(&models.PointLedger{}, entries[2].ID)
+
+	// The row after the gap no longer links up
+	result, _ := services.NewLedgerService(repository.NewGormStore(db)).VerifyChain()
+	if result.Valid || result.FirstInvalidID == nil || *result.FirstInvalidID != entries[3].ID {
+		t.Errorf("Expected entry %d to be reported, got: %+v", entries[3].ID, result)
+	}
+}
+
+func TestLedgerChain_RefusesFork(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	entries := seedChain(t, db)
+	ledger := repository.NewGormStore(db).Ledger()
+
+	if head, err := ledger.LockHead(); err != nil || head != entries[3].Hash {
+		t.Fatalf("Expected the head at the last entry, got %q: %v", head, err)
+	}
+
+	// A writer that chained onto an older entry cannot move the head
+	fork := models.PointLedger{ID: entries[3].ID + 1, PrevHash: entries[2].Hash, Hash: "fork"}
+	if err := ledger.MoveHead(entries[2].Hash, &fork); !errors.Is(err, repository.ErrChainForked) {
+		t.Errorf("Expected ErrChainForked, got: %v", err)
+	}
+	if head, _ := ledger.LockHead(); head != entries[3].Hash {
+		t.Errorf("Expected the head to stay at the last entry, got: %q", head)
+	}
+}
+
+func TestLedgerChain_ConcurrentAppends(t *testing.T) {
+	if testDatabaseURL == "" {
+		t.Skip("needs TEST_DATABASE_URL; SQLite runs one writer at a time")
+	}
+	db := setupIsolatedTestDB(t)
+	store := repository.NewGormStore(db)
+	transfers := services.NewTransferService(store)
+
+	// Pairs that share no user rows, so only the chain head orders them
+	const pairs, rounds = 8, 5
+	users := make([]models.User, 2*pairs)
+	for i := range users {
+		users[i] = models.User{Name: fmt.Sprintf("ChainRace%d", i), Email: fmt.Sprintf("chainrace%d@test.com", i), Points: 100}
+		db.Create(&users[i])
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < pairs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range rounds {
+				req := &models.TransferCreateRequest{FromUserID: users[2*i].ID, ToUserID: users[2*i+1].ID, Amount: 1}
+				if _, err := transfers.CreateTransfer(req); err != nil {
+					t.Errorf("Expected no error, got: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	result, err := services.NewLedgerService(store).VerifyChain()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !result.Valid || result.Checked != pairs*rounds*2 {
+		t.Errorf("Expected a valid chain of %d entries, got: %+v", pairs*rounds*2, result)
+	}
+}
@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"class-go-ai/models"
+	"class-go-ai/repository"
+	"class-go-ai/services"
+)
+
+func TestLedger_FilterAndPaginate(t *testing.T) {
+	db := setupTestDB(t)
+	points := services.NewPointsService(repository.NewGormStore(db))
+	transfers := services.NewTransferService(repository.NewGormStore(db))
+	ledger := services.NewLedgerService(repository.NewGormStore(db))
+
+	user1 := &models.User{Name: "LedgerAlice1", Email: "ledgeralice1@test.com"}
+	user2 := &models.User{Name: "LedgerBob1", Email: "ledgerbob1@test.com"}
+	db.Create(user1)
+	db.Create(user2)
+
+	points.Earn(user1.ID, 100, "signup")
+	points.Earn(user1.ID, 200, "order-1")
+	transfer, _ := transfers.CreateTransfer(&models.TransferCreateRequest{
+		FromUserID: user1.ID,
+		ToUserID:   user2.ID,
+		Amount:     50,
+	})
+	points.Redeem(user1.ID, 25, "voucher")
+
+	// Page through everything two at a time
+	page1, err := ledger.ListEntries(services.LedgerQuery{UserID: user1.ID, Limit: 2})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(page1.Data) != 2 || page1.NextCursor == nil {
+		t.Fatalf("Expected 2 entries and a cursor, got %d entries", len(page1.Data))
+	}
+
+	page2, _ := ledger.ListEntries(services.LedgerQuery{UserID: user1.ID, Limit: 2, Cursor: *page1.NextCursor})
+	if len(page2.Data) != 2 || page2.NextCursor != nil {
+		t.Errorf("Expected last page of 2 entries, got %d entries", len(page2.Data))
+	}
+
+	if page2.Data[0].ID <= page1.Data[1].ID {
+		t.Error("Expected entries ordered by id across pages")
+	}
+
+	// Filter by event type
+	earned, _ := ledger.ListEntries(services.LedgerQuery{UserID: user1.ID, EventType: models.EventTypeEarn})
+	if len(earned.Data) != 2 {
+		t.Errorf("Expected 2 earn entries, got: %d", len(earned.Data))
+	}
+
+	// Filter by transfer
+	byTransfer, _ := ledger.ListEntries(services.LedgerQuery{UserID: user1.ID, TransferID: &transfer.ID})
+	if len(byTransfer.Data) != 1 || byTransfer.Data[0].EventType != models.EventTypeTransferOut {
+		t.Errorf("Expected the transfer_out entry, got: %+v", byTransfer.Data)
+	}
+
+	// Unknown user
+	if _, err := ledger.ListEntries(services.LedgerQuery{UserID: 999999}); err != services.ErrUserNotFound {
+		t.Errorf("Expected ErrUserNotFound, got: %v", err)
+	}
+}
+
+func TestLedger_BalanceAsOf(t *testing.T) {
+	db := setupTestDB(t)
+	ledger := services.NewLedgerService(repository.NewGormStore(db))
+
+	user := &models.User{Name: "LedgerAlice2", Email: "ledgeralice2@test.com", Points: 70}
+	db.Create(user)
+
+	base := time.Date(2026, 5, 1, 12, 0, 0, 0, time.Local)
+	db.Create(&models.PointLedger{UserID: user.ID, Change: 100, BalanceAfter: 100, EventType: models.EventTypeEarn, CreatedAt: base})
+	db.Create(&models.PointLedger{UserID: user.ID, Change: -30, BalanceAfter: 70, EventType: models.EventTypeRedeem, CreatedAt: base.Add(time.Hour)})
+
+	cases := []struct {
+		asOf     time.Time
+		expected int
+	}{
+		{base, 100},
+		{base.Add(30 * time.Minute), 100},
+		{base.Add(2 * time.Hour), 70},
+	}
+
+	for _, tc := range cases {
+		balance, err := ledger.BalanceAsOf(user.ID, tc.asOf)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if balance == nil || *balance != tc.expected {
+			t.Errorf("As of %v: expected %d, got: %v", tc.asOf, tc.expected, balance)
+		}
+	}
+
+	// Before the first entry the ledger does not know the balance
+	if balance, err := ledger.BalanceAsOf(user.ID, base.Add(-time.Minute)); err != nil || balance != nil {
+		t.Errorf("Expected an unknown balance before the first entry, got %v: %v", balance, err)
+	}
+
+	// Nor for points seeded without any entry
+	seeded := &models.User{Name: "LedgerSeeded", Email: "ledgerseeded@test.com", Points: 1000}
+	db.Create(seeded)
+	if balance, err := ledger.BalanceAsOf(seeded.ID, time.Now()); err != nil || balance != nil {
+		t.Errorf("Expected an unknown balance for a seeded user, got %v: %v", balance, err)
+	}
+}
@@ -0,0 +1,266 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"class-go-ai/handlers"
+	"class-go-ai/middleware"
+	"class-go-ai/models"
+	"class-go-ai/repository"
+	"class-go-ai/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var errInjected = errors.New("injected failure")
+
+// setupMemoryStore creates a store holding a sender with points and a receiver
+func setupMemoryStore(t *testing.T, points int) (*repository.MemoryStore, *models.User, *models.User) {
+	store := repository.NewMemoryStore()
+	sender := &models.User{Name: "MemSender", Email: "memsender@test.com", Points: points}
+	receiver := &models.User{Name: "MemReceiver", Email: "memreceiver@test.com"}
+	if err := store.Users().Create(sender); err != nil {
+		t.Fatalf("Failed to create sender: %v", err)
+	}
+	if err := store.Users().Create(receiver); err != nil {
+		t.Fatalf("Failed to create receiver: %v", err)
+	}
+	return store, sender, receiver
+}
+
+// balances returns the points of the given users in store
+func balances(t *testing.T, store repository.Store, ids ...uint) []int {
+	points := make([]int, len(ids))
+	for i, id := range ids {
+		user, err := store.Users().FindByID(id)
+		if err != nil {
+			t.Fatalf("Failed to load user %d: %v", id, err)
+		}
+		points[i] = user.Points
+	}
+	return points
+}
+
+func TestMemoryStore_Transfer(t *testing.T) {
+	store, sender, receiver := setupMemoryStore(t, 100)
+	service := services.NewTransferService(store)
+
+	transfer, err := service.CreateTransfer(&models.TransferCreateRequest{FromUserID: sender.ID, ToUserID: receiver.ID, Amount: 40})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if transfer.Status != models.TransferStatusCompleted {
+		t.Errorf("Expected completed, got: %s", transfer.Status)
+	}
+	if got := balances(t, store, sender.ID, receiver.ID); got[0] != 60 || got[1] != 40 {
+		t.Errorf("Expected 60 and 40, got: %v", got)
+	}
+
+	// A failed attempt is still recorded
+	if _, err := service.CreateTransfer(&models.TransferCreateRequest{FromUserID: sender.ID, ToUserID: receiver.ID, Amount: 500}); !errors.Is(err, services.ErrInsufficientPoints) {
+		t.Errorf("Expected ErrInsufficientPoints, got: %v", err)
+	}
+	page, err := service.GetTransfersByUserID(sender.ID, 1, 10)
+	if err != nil || page.Total != 2 || page.Data[0].Status != models.TransferStatusFailed {
+		t.Errorf("Expected the failed transfer first of 2, got: %+v (%v)", page, err)
+	}
+
+	result, err := services.NewLedgerService(store).VerifyChain()
+	if err != nil || !result.Valid || result.Checked != 2 {
+		t.Errorf("Expected a valid chain of 2 entries, got: %+v (%v)", result, err)
+	}
+}
+
+func TestMemoryStore_RollsBackInjectedFailures(t *testing.T) {
+	store, sender, receiver := setupMemoryStore(t, 100)
+	service := services.NewTransferService(store)
+	req := func() *models.TransferCreateRequest {
+		return &models.TransferCreateRequest{FromUserID: sender.ID, ToUserID: receiver.ID, Amount: 30, IdempotencyKey: "mem-fault"}
+	}
+
+	// Fail the receiver's ledger entry, after the sender's was written
+	ledgerWrites := 0
+	faults := map[string]repository.Fault{
+		"second ledger entry": func(op string) error {
+			if op == "Ledger.Create" {
+				if ledgerWrites++; ledgerWrites == 2 {
+					return errInjected
+				}
+			}
+			return nil
+		},
+		"commit": repository.FailOn("Commit", errInjected),
+	}
+
+	for name, fault := range faults {
+		store.SetFault(fault)
+		if _, err := service.CreateTransfer(req()); !errors.Is(err, errInjected) {
+			t.Errorf("%s: expected the injected error, got: %v", name, err)
+		}
+		store.SetFault(nil)
+
+		if got := balances(t, store, sender.ID, receiver.ID); got[0] != 100 || got[1] != 0 {
+			t.Errorf("%s: expected balances to be rolled back, got: %v", name, got)
+		}
+		if _, err := store.Transfers().FindByIdemKey("mem-fault"); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("%s: expected no transfer to be kept, got: %v", name, err)
+		}
+		if _, err := store.Ledger().Last(); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("%s: expected no ledger entry to be kept, got: %v", name, err)
+		}
+	}
+
+	// The key is still free once the store works again
+	if _, err := service.CreateTransfer(req()); err != nil {
+		t.Fatalf("Expected the retry to succeed, got: %v", err)
+	}
+	if got := balances(t, store, sender.ID, receiver.ID); got[0] != 70 || got[1] != 30 {
+		t.Errorf("Expected 70 and 30, got: %v", got)
+	}
+}
+
+func TestMemoryStore_UniqueIdempotencyKey(t *testing.T) {
+	store, sender, receiver := setupMemoryStore(t, 100)
+
+	first := &models.Transfer{FromUserID: sender.ID, ToUserID: receiver.ID, Amount: 1, Status: models.TransferStatusFailed, IdempotencyKey: "mem-unique"}
+	if err := store.Transfers().Create(first); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	second := &models.Transfer{FromUserID: sender.ID, ToUserID: receiver.ID, Amount: 1, Status: models.TransferStatusFailed, IdempotencyKey: "mem-unique"}
+	if err := store.Transfers().Create(second); !errors.Is(err, repository.ErrDuplicateKey) {
+		t.Errorf("Expected ErrDuplicateKey, got: %v", err)
+	}
+
+	// Concurrent retries with one key move the points once
+	service := services.NewTransferService(store)
+	var wg sync.WaitGroup
+	ids := make([]uint, 20)
+	for i := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			transfer, err := service.CreateTransfer(&models.TransferCreateRequest{FromUserID: sender.ID, ToUserID: receiver.ID, Amount: 10, IdempotencyKey: "mem-retry"})
+			if err != nil {
+				t.Errorf("Expected no error, got: %v", err)
+				return
+			}
+			ids[i] = transfer.ID
+		}()
+	}
+	wg.Wait()
+
+	for _, id := range ids {
+		if id != ids[0] {
+			t.Fatalf("Expected every retry to return one transfer, got: %v", ids)
+		}
+	}
+	if got := balances(t, store, sender.ID, receiver.ID); got[0] != 90 || got[1] != 10 {
+		t.Errorf("Expected 90 and 10, got: %v", got)
+	}
+}
+
+func TestMemoryStore_Handlers(t *testing.T) {
+	store, sender, receiver := setupMemoryStore(t, 100)
+	transfers := handlers.NewTransferHandler(services.NewTransferService(store))
+	users := handlers.NewUserHandler(store.Users())
+
+	// Requests act as the sender, without going through token auth
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		middleware.SetUser(c, sender.ID, models.RoleMember)
+		return c.Next()
+	})
+	app.Post("/transfers", transfers.CreateTransfer)
+	app.Get("/users/:id", users.GetUser)
+
+	var created models.TransferResponse
+	body := fiber.Map{"toUserId": receiver.ID, "amount": 25}
+	if status := doJSON(t, app, "POST", "/transfers", "", body, &created); status != 201 || created.Transfer.Amount != 25 {
+		t.Fatalf("Expected 201, got: %d %+v", status, created)
+	}
+
+	var user models.User
+	if status := doJSON(t, app, "GET", fmt.Sprintf("/users/%d", receiver.ID), "", nil, &user); status != 200 || user.Points != 25 {
+		t.Errorf("Expected the receiver with 25 points, got: %d %+v", status, user)
+	}
+	if status := doJSON(t, app, "GET", "/users/999", "", nil, nil); status != 404 {
+		t.Errorf("Expected 404 for a missing user, got: %d", status)
+	}
+
+	// A store failure surfaces as a server error and changes nothing
+	store.SetFault(repository.FailOn("Transfers.Create", errInjected))
+	if status := doJSON(t, app, "POST", "/transfers", "", body, nil); status != 500 {
+		t.Errorf("Expected 500, got: %d", status)
+	}
+	if got := balances(t, store, sender.ID); got[0] != 75 {
+		t.Errorf("Expected the sender to keep 75, got: %v", got)
+	}
+}
+
+func TestMemoryStore_AuthAndAPIKeys(t *testing.T) {
+	store, sender, _ := setupMemoryStore(t, 100)
+	auth := services.NewAuthService(store, []byte("test-secret"))
+	keys := services.NewAPIKeyService(store)
+
+	session, err := auth.Register(&models.RegisterRequest{Name: "MemAuth", Email: "MemAuth@test.com", Password: "correct-horse"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, err := auth.Register(&models.RegisterRequest{Name: "MemAuth2", Email: "memauth@test.com", Password: "correct-horse"}); !errors.Is(err, services.ErrEmailTaken) {
+		t.Errorf("Expected ErrEmailTaken, got: %v", err)
+	}
+	if _, err := auth.Login("memauth@test.com", "correct-horse"); err != nil {
+		t.Errorf("Expected login to succeed, got: %v", err)
+	}
+	if user, err := auth.Authenticate(session.AccessToken); err != nil || user.ID != session.User.ID {
+		t.Errorf("Expected the registered user, got: %+v (%v)", user, err)
+	}
+
+	// Reusing a refreshed token revokes the whole session family
+	refreshed, err := auth.Refresh(session.RefreshToken)
+	if err != nil {
+		t.Fatalf("Expected refresh to succeed, got: %v", err)
+	}
+	if _, err := auth.Refresh(session.RefreshToken); !errors.Is(err, services.ErrInvalidToken) {
+		t.Errorf("Expected ErrInvalidToken for a reused token, got: %v", err)
+	}
+	if err := auth.Logout(refreshed.RefreshToken); !errors.Is(err, services.ErrInvalidToken) {
+		t.Errorf("Expected the newer token to be revoked too, got: %v", err)
+	}
+
+	if _, err := auth.SetRole(session.User.ID, models.RoleAdmin); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, err := auth.SetRole(session.User.ID, models.RoleMember); !errors.Is(err, services.ErrLastAdmin) {
+		t.Errorf("Expected ErrLastAdmin, got: %v", err)
+	}
+
+	// The admin's key acts for the sender until it is rotated away
+	debitOthers := func(role models.Role) bool { return role == models.RoleAdmin }
+	key, plaintext, err := keys.Create(session.User.ID, &models.APIKeyCreateRequest{
+		Name: "mem", Scopes: []models.APIKeyScope{models.ScopeTransfersWrite}, SourceUserID: &sender.ID,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if found, err := keys.Authenticate(plaintext, debitOthers); err != nil || found.ID != key.ID || found.LastUsedAt == nil {
+		t.Errorf("Expected the key with its use recorded, got: %+v (%v)", found, err)
+	}
+
+	replacement, replacementPlaintext, err := keys.Rotate(key.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, err := keys.Authenticate(plaintext, debitOthers); !errors.Is(err, services.ErrInvalidAPIKey) {
+		t.Errorf("Expected the rotated key to be rejected, got: %v", err)
+	}
+	if _, err := keys.Authenticate(replacementPlaintext, debitOthers); err != nil {
+		t.Errorf("Expected the replacement to work, got: %v", err)
+	}
+	if listed, err := keys.List(session.User.ID); err != nil || len(listed) != 2 || listed[0].ID != replacement.ID {
+		t.Errorf("Expected both keys newest first, got: %+v (%v)", listed, err)
+	}
+}
@@ -0,0 +1,124 @@
+package tests
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"class-go-ai/config"
+	"class-go-ai/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// scrape returns the /metrics lines of app that start with one of prefixes
+func scrape(t *testing.T, app *fiber.App, prefixes ...string) map[string]bool {
+	resp, err := app.Test(httptest.NewRequest("GET", "/metrics", nil))
+	if err != nil {
+		t.Fatalf("Failed to scrape: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("Expected 200, got: %d", resp.StatusCode)
+	}
+	body, _ := io.ReadAll(resp.Body)
+
+	lines := map[string]bool{}
+	for _, line := range strings.Split(string(body), "\n") {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(line, prefix) {
+				lines[line] = true
+			}
+		}
+	}
+	return lines
+}
+
+func TestMetrics_TransfersAndRoutes(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	app, c := setupContainerApp(t, db, config.Default())
+
+	alice, _ := c.Auth.Register(&models.RegisterRequest{Name: "MetricsAlice", Email: "metricsalice@test.com", Password: "correct-horse"})
+	bob, _ := c.Auth.Register(&models.RegisterRequest{Name: "MetricsBob", Email: "metricsbob@test.com", Password: "correct-horse"})
+	db.Model(&models.User{}).Where("id = ?", alice.User.ID).Update("points", 100)
+
+	var created models.TransferResponse
+	if status := doJSON(t, app, "POST", "/transfers", alice.AccessToken, fiber.Map{"toUserId": bob.User.ID, "amount": 30}, &created); status != 201 {
+		t.Fatalf("Expected 201, got: %d", status)
+	}
+	doJSON(t, app, "POST", "/transfers", alice.AccessToken, fiber.Map{"toUserId": bob.User.ID, "amount": 25}, nil)
+	if status := doJSON(t, app, "POST", "/transfers", alice.AccessToken, fiber.Map{"toUserId": bob.User.ID, "amount": 500}, nil); status != 409 {
+		t.Errorf("Expected 409, got: %d", status)
+	}
+	if status := doJSON(t, app, "POST", "/transfers", alice.AccessToken, fiber.Map{"toUserId": 999999, "amount": 5}, nil); status != 404 {
+		t.Errorf("Expected 404, got: %d", status)
+	}
+	path := "/transfers/" + created.Transfer.IdempotencyKey
+	if status := doJSON(t, app, "POST", path+"/reverse", bob.AccessToken, fiber.Map{"reason": "refund"}, nil); status != 200 {
+		t.Errorf("Expected 200, got: %d", status)
+	}
+	doJSON(t, app, "GET", path, alice.AccessToken, nil, nil)
+	doJSON(t, app, "GET", "/no-such-route", "", nil, nil)
+
+	lines := scrape(t, app, "points_")
+	for _, want := range []string{
+		`points_transfers_total{reason="",status="completed"} 2`,
+		`points_transfers_total{reason="",status="reversed"} 1`,
+		`points_transfers_total{reason="insufficient_points",status="failed"} 1`,
+		`points_transfers_total{reason="user_not_found",status="failed"} 1`,
+		`points_transfer_points_moved_total 55`,
+		`points_ledger_entries_total{event_type="transfer_out"} 3`,
+		`points_transfer_transaction_duration_seconds_count{outcome="committed"} 2`,
+		`points_transfer_transaction_duration_seconds_count{outcome="rolled_back"} 2`,
+		`points_http_request_duration_seconds_count{method="POST",route="/transfers/",status="201"} 2`,
+		`points_http_request_duration_seconds_count{method="POST",route="/transfers/",status="409"} 1`,
+		`points_http_request_duration_seconds_count{method="POST",route="/transfers/:id/reverse",status="200"} 1`,
+		`points_http_request_duration_seconds_count{method="GET",route="/transfers/:id",status="200"} 1`,
+		`points_http_request_duration_seconds_count{method="GET",route="unmatched",status="404"} 1`,
+		`points_events_dropped_total 0`,
+	} {
+		if !lines[want] {
+			t.Errorf("Expected the line %s", want)
+		}
+	}
+}
+
+func TestMetrics_RolledBackBatchAndPool(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	app, c := setupContainerApp(t, db, config.Default())
+
+	alice, _ := c.Auth.Register(&models.RegisterRequest{Name: "MetricsAlice2", Email: "metricsalice2@test.com", Password: "correct-horse"})
+	bob, _ := c.Auth.Register(&models.RegisterRequest{Name: "MetricsBob2", Email: "metricsbob2@test.com", Password: "correct-horse"})
+	db.Model(&models.User{}).Where("id = ?", alice.User.ID).Update("points", 50)
+
+	// The first item settles inside the batch transaction, then rolls back
+	batch := models.TransferBatchRequest{Mode: models.TransferBatchAtomic, Items: []models.TransferBatchItem{
+		{ToUserID: bob.User.ID, Amount: 40},
+		{ToUserID: bob.User.ID, Amount: 40},
+	}}
+	doJSON(t, app, "POST", "/transfers/batch", alice.AccessToken, batch, nil)
+
+	lines := scrape(t, app, "points_transfer", "points_ledger", "go_sql_")
+	for line := range lines {
+		if strings.HasPrefix(line, "points_transfers_total") || strings.HasPrefix(line, "points_ledger_entries_total") {
+			t.Errorf("Expected nothing counted for a rolled back batch, got: %s", line)
+		}
+	}
+	if !lines[`points_transfer_points_moved_total 0`] {
+		t.Error("Expected no points moved")
+	}
+	if !hasPrefix(lines, `go_sql_open_connections{db_name="points"}`) {
+		t.Error("Expected the connection pool stats")
+	}
+}
+
+// hasPrefix reports whether one of lines starts with prefix
+func hasPrefix(lines map[string]bool, prefix string) bool {
+	for line := range lines {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"class-go-ai/database"
+	"class-go-ai/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openEmptyDB opens an isolated in-memory database without migrating it
+func openEmptyDB(t *testing.T) (*gorm.DB, *database.Migrator) {
+	db, err := database.Open("file:"+t.Name()+"?mode=memory&cache=shared", &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	return db, migrator
+}
+
+func TestMigrator_UpDownAndStatus(t *testing.T) {
+	db, migrator := openEmptyDB(t)
+
+	if err := migrator.Check(); !errors.Is(err, database.ErrSchemaOutdated) {
+		t.Fatalf("Expected an outdated schema, got: %v", err)
+	}
+
+	applied, err := migrator.Up()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(applied) != migrator.Latest() {
+		t.Errorf("Expected %d migrations applied, got: %d", migrator.Latest(), len(applied))
+	}
+	if err := migrator.Check(); err != nil {
+		t.Errorf("Expected an up to date schema, got: %v", err)
+	}
+
+	// Every model column exists, so the migrations have not drifted from the models
+	for _, model := range database.Models() {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatalf("Failed to parse model: %v", err)
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" && !db.Migrator().HasColumn(model, field.DBName) {
+				t.Errorf("Expected column %s.%s", stmt.Schema.Table, field.DBName)
+			}
+		}
+	}
+
+	// The constraints migration enforces statuses and references
+	user := &models.User{Name: "MigrateUser", Email: "migrateuser@test.com"}
+	db.Create(user)
+	if err := db.Create(&models.Transfer{FromUserID: user.ID, ToUserID: user.ID + 100, Amount: 1, Status: models.TransferStatusCompleted, IdempotencyKey: "migrate-fk"}).Error; err == nil {
+		t.Error("Expected a transfer to an unknown user to be rejected")
+	}
+	if err := db.Create(&models.Transfer{FromUserID: user.ID, ToUserID: user.ID, Amount: 1, Status: "bogus", IdempotencyKey: "migrate-status"}).Error; err == nil {
+		t.Error("Expected an unknown status to be rejected")
+	}
+
+	rolledBack, err := migrator.Down(1)
+	if err != nil || len(rolledBack) != 1 {
+		t.Fatalf("Expected one migration rolled back, got %d: %v", len(rolledBack), err)
+	}
+	if version, _ := migrator.Version(); version != migrator.Latest()-1 {
+		t.Errorf("Expected version %d, got: %d", migrator.Latest()-1, version)
+	}
+	if db.Migrator().HasTable(&models.LedgerHead{}) {
+		t.Error("Expected the ledger head table dropped")
+	}
+
+	if rolledBack, err := migrator.Down(1); err != nil || len(rolledBack) != 1 || rolledBack[0].Name != "constraints" {
+		t.Fatalf("Expected the constraints migration rolled back, got %+v: %v", rolledBack, err)
+	}
+	if err := db.Create(&models.Transfer{FromUserID: user.ID, ToUserID: user.ID, Amount: 1, Status: "bogus", IdempotencyKey: "migrate-status"}).Error; err != nil {
+		t.Errorf("Expected the status check to be gone, got: %v", err)
+	}
+
+	status, _ := migrator.Status()
+	if !status[0].Applied || status[len(status)-2].Applied || status[len(status)-1].Applied {
+		t.Errorf("Expected only the two latest migrations pending, got: %+v", status)
+	}
+
+	if _, err := migrator.To(99); !errors.Is(err, database.ErrNoMigration) {
+		t.Errorf("Expected ErrNoMigration, got: %v", err)
+	}
+
+	if _, err := migrator.To(0); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if db.Migrator().HasTable(&models.User{}) {
+		t.Error("Expected every table dropped at version 0")
+	}
+}
+
+func TestMigrator_AdoptsAutoMigratedDatabase(t *testing.T) {
+	db, migrator := openEmptyDB(t)
+
+	// A database created before migrations existed
+	if err := db.AutoMigrate(database.Models()...); err != nil {
+		t.Fatalf("Failed to auto migrate: %v", err)
+	}
+	user := &models.User{Name: "LegacyUser", Email: "legacyuser@test.com", Points: 50}
+	db.Create(user)
+
+	// Nothing is recorded yet, so rolling back must not reach the baseline
+	if _, err := migrator.Down(1); !errors.Is(err, database.ErrNotAdopted) {
+		t.Fatalf("Expected ErrNotAdopted, got: %v", err)
+	}
+	if _, err := migrator.To(0); !errors.Is(err, database.ErrNotAdopted) {
+		t.Fatalf("Expected ErrNotAdopted, got: %v", err)
+	}
+
+	applied, err := migrator.Up()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(applied) != migrator.Latest() || applied[0].Name != "baseline" {
+		t.Errorf("Expected the baseline adopted and the rest applied, got: %+v", applied)
+	}
+
+	var kept models.User
+	if err := db.First(&kept, user.ID).Error; err != nil || kept.Points != 50 {
+		t.Errorf("Expected existing rows to survive, got %+v: %v", kept, err)
+	}
+
+	// A database migrated by a newer build is refused too
+	db.Create(&database.SchemaMigration{Version: 99, Name: "future"})
+	if err := migrator.Check(); !errors.Is(err, database.ErrSchemaTooNew) {
+		t.Errorf("Expected ErrSchemaTooNew, got: %v", err)
+	}
+}
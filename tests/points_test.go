@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"encoding/json"
+	"testing"
+
+	"class-go-ai/models"
+	"class-go-ai/repository"
+	"class-go-ai/services"
+)
+
+func TestPoints_EarnAndRedeem(t *testing.T) {
+	db := setupTestDB(t)
+	service := services.NewPointsService(repository.NewGormStore(db))
+
+	user := &models.User{Name: "PointsAlice1", Email: "pointsalice1@test.com", Points: 100}
+	db.Create(user)
+
+	earned, err := service.Earn(user.ID, 50, "order-1001")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if earned.EventType != models.EventTypeEarn || earned.BalanceAfter != 150 || earned.Reference != "order-1001" {
+		t.Errorf("Unexpected earn entry: %+v", earned)
+	}
+
+	redeemed, err := service.Redeem(user.ID, 120, "voucher-A")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if redeemed.Change != -120 || redeemed.BalanceAfter != 30 {
+		t.Errorf("Unexpected redeem entry: %+v", redeemed)
+	}
+
+	// Cannot redeem more than available
+	if _, err := service.Redeem(user.ID, 31, "voucher-B"); err != services.ErrInsufficientPoints {
+		t.Errorf("Expected ErrInsufficientPoints, got: %v", err)
+	}
+
+	var updated models.User
+	db.First(&updated, user.ID)
+
+	if updated.Points != 30 {
+		t.Errorf("Expected points 30, got: %d", updated.Points)
+	}
+
+	// Source is mandatory for earn
+	if _, err := service.Earn(user.ID, 10, ""); err != services.ErrSourceRequired {
+		t.Errorf("Expected ErrSourceRequired, got: %v", err)
+	}
+}
+
+func TestPoints_Adjust(t *testing.T) {
+	db := setupTestDB(t)
+	service := services.NewPointsService(repository.NewGormStore(db))
+
+	user := &models.User{Name: "PointsAlice2", Email: "pointsalice2@test.com", Points: 100}
+	db.Create(user)
+
+	entry, err := service.Adjust(user.ID, -40, "Goodwill correction")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if entry.EventType != models.EventTypeAdjust || entry.BalanceAfter != 60 {
+		t.Errorf("Unexpected adjust entry: %+v", entry)
+	}
+
+	var metadata map[string]string
+	if err := json.Unmarshal([]byte(entry.Metadata), &metadata); err != nil || metadata["reason"] != "Goodwill correction" {
+		t.Errorf("Expected reason in metadata, got: %q", entry.Metadata)
+	}
+
+	// Reason is mandatory
+	if _, err := service.Adjust(user.ID, 10, ""); err != services.ErrReasonRequired {
+		t.Errorf("Expected ErrReasonRequired, got: %v", err)
+	}
+
+	// Balance may not go negative
+	if _, err := service.Adjust(user.ID, -61, "Too much"); err != services.ErrInsufficientPoints {
+		t.Errorf("Expected ErrInsufficientPoints, got: %v", err)
+	}
+}
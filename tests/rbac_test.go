@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"class-go-ai/models"
+	"class-go-ai/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRBAC_PermissionMatrix(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	app, auth := setupAuthApp(t, db)
+
+	member, _ := auth.Register(&models.RegisterRequest{Name: "RbacMember", Email: "rbacmember@test.com", Password: "password-m"})
+	support, _ := auth.Register(&models.RegisterRequest{Name: "RbacSupport", Email: "rbacsupport@test.com", Password: "password-s"})
+	admin, _ := auth.Register(&models.RegisterRequest{Name: "RbacAdmin", Email: "rbacadmin@test.com", Password: "password-a"})
+	auth.SetRole(support.User.ID, models.RoleSupport)
+	auth.SetRole(admin.User.ID, models.RoleAdmin)
+
+	memberLedger := fmt.Sprintf("/users/%d/ledger", member.User.ID)
+	memberEarn := fmt.Sprintf("/users/%d/points/earn", member.User.ID)
+	earn := fiber.Map{"amount": 10, "source": "promo"}
+
+	cases := []struct {
+		name   string
+		token  string
+		method string
+		path   string
+		body   interface{}
+		status int
+	}{
+		{"member lists users", member.AccessToken, "GET", "/users", nil, 403},
+		{"member reads own user", member.AccessToken, "GET", fmt.Sprintf("/users/%d", member.User.ID), nil, 200},
+		{"member reads other user", member.AccessToken, "GET", fmt.Sprintf("/users/%d", admin.User.ID), nil, 403},
+		{"member reads own ledger", member.AccessToken, "GET", memberLedger, nil, 200},
+		{"member earns points", member.AccessToken, "POST", memberEarn, earn, 403},
+		{"member deletes user", member.AccessToken, "DELETE", fmt.Sprintf("/users/%d", support.User.ID), nil, 403},
+		{"support lists users", support.AccessToken, "GET", "/users", nil, 200},
+		{"support reads ledger", support.AccessToken, "GET", memberLedger, nil, 200},
+		{"support earns points", support.AccessToken, "POST", memberEarn, earn, 403},
+		{"support verifies chain", support.AccessToken, "GET", "/admin/ledger/verify", nil, 200},
+		{"support repairs", support.AccessToken, "POST", "/admin/reconcile", nil, 403},
+		{"admin earns points", admin.AccessToken, "POST", memberEarn, earn, 201},
+		{"admin adjusts points", admin.AccessToken, "POST", fmt.Sprintf("/users/%d/points/adjust", member.User.ID), fiber.Map{"change": -5, "reason": "correction"}, 201},
+	}
+
+	for _, tc := range cases {
+		body := fiber.Map{}
+		if status := doJSON(t, app, tc.method, tc.path, tc.token, tc.body, &body); status != tc.status {
+			t.Errorf("%s: expected %d, got %d (%v)", tc.name, tc.status, status, body)
+		} else if status == 403 && body["error"] != "FORBIDDEN" {
+			t.Errorf("%s: expected FORBIDDEN envelope, got %v", tc.name, body)
+		}
+	}
+}
+
+func TestRBAC_SetRole(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	app, auth := setupAuthApp(t, db)
+
+	admin, _ := auth.Register(&models.RegisterRequest{Name: "RoleAdmin", Email: "roleadmin@test.com", Password: "password-a"})
+	member, _ := auth.Register(&models.RegisterRequest{Name: "RoleMember", Email: "rolemember@test.com", Password: "password-m"})
+	auth.SetRole(admin.User.ID, models.RoleAdmin)
+
+	path := fmt.Sprintf("/admin/users/%d/role", member.User.ID)
+	if status := doJSON(t, app, "PUT", path, member.AccessToken, fiber.Map{"role": "admin"}, nil); status != 403 {
+		t.Errorf("Expected member to be forbidden, got %d", status)
+	}
+	if status := doJSON(t, app, "PUT", path, admin.AccessToken, fiber.Map{"role": "owner"}, nil); status != 400 {
+		t.Errorf("Expected 400 for an unknown role, got %d", status)
+	}
+
+	var updated models.User
+	if status := doJSON(t, app, "PUT", path, admin.AccessToken, fiber.Map{"role": "support"}, &updated); status != 200 || updated.Role != models.RoleSupport {
+		t.Fatalf("Expected role support, got %d %+v", status, updated)
+	}
+
+	// The new role applies to existing tokens immediately
+	if status := doJSON(t, app, "GET", "/users", member.AccessToken, nil, nil); status != 200 {
+		t.Errorf("Expected support to list users, got %d", status)
+	}
+
+	if _, err := auth.SetRole(admin.User.ID, models.RoleMember); !errors.Is(err, services.ErrLastAdmin) {
+		t.Errorf("Expected ErrLastAdmin, got: %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"testing"
+
+	"class-go-ai/models"
+	"class-go-ai/repository"
+	"class-go-ai/services"
+)
+
+func findUserDiscrepancy(report *models.ReconcileReport, userID uint) *models.UserDiscrepancy {
+	for i := range report.Users {
+		if report.Users[i].UserID == userID {
+			return &report.Users[i]
+		}
+	}
+	return nil
+}
+
+func findTransferDiscrepancy(report *models.ReconcileReport, transferID uint) *models.TransferDiscrepancy {
+	for i := range report.Transfers {
+		if report.Transfers[i].TransferID == transferID {
+			return &report.Transfers[i]
+		}
+	}
+	return nil
+}
+
+func TestReconcile_DetectsAndRepairsDrift(t *testing.T) {
+	db := setupTestDB(t)
+	points := services.NewPointsService(repository.NewGormStore(db))
+	reconciler := services.NewReconcileService(db)
+
+	// Balance written directly, bypassing the ledger
+	drifted := &models.User{Name: "ReconAlice1", Email: "reconalice1@test.com", Points: 100}
+	clean := &models.User{Name: "ReconBob1", Email: "reconbob1@test.com"}
+	db.Create(drifted)
+	db.Create(clean)
+	points.Earn(clean.ID, 40, "signup")
+
+	report, err := reconciler.Run(false)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	discrepancy := findUserDiscrepancy(report, drifted.ID)
+	if discrepancy == nil || discrepancy.LedgerSum != 0 || discrepancy.Points != 100 {
+		t.Fatalf("Expected drift for user %d, got: %+v", drifted.ID, discrepancy)
+	}
+
+	if findUserDiscrepancy(report, clean.ID) != nil {
+		t.Errorf("Expected no discrepancy for user %d", clean.ID)
+	}
+
+	// Repair posts an adjust entry without touching the balance
+	report, err = reconciler.Run(true)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	discrepancy = findUserDiscrepancy(report, drifted.ID)
+	if discrepancy == nil || discrepancy.RepairEntryID == nil {
+		t.Fatal("Expected a repair entry for the drifted user")
+	}
+
+	var entry models.PointLedger
+	db.First(&entry, *discrepancy.RepairEntryID)
+	if entry.EventType != models.EventTypeAdjust || entry.Change != 100 || entry.BalanceAfter != 100 {
+		t.Errorf("Unexpected repair entry: %+v", entry)
+	}
+
+	report, _ = reconciler.Run(false)
+	if findUserDiscrepancy(report, drifted.ID) != nil {
+		t.Error("Expected drift to be repaired")
+	}
+}
+
+func TestReconcile_TransferLedgerRows(t *testing.T) {
+	db := setupTestDB(t)
+	points := services.NewPointsService(repository.NewGormStore(db))
+	transfers := services.NewTransferService(repository.NewGormStore(db))
+	reconciler := services.NewReconcileService(db)
+
+	user1 := &models.User{Name: "ReconAlice2", Email: "reconalice2@test.com"}
+	user2 := &models.User{Name: "ReconBob2", Email: "reconbob2@test.com"}
+	db.Create(user1)
+	db.Create(user2)
+	points.Earn(user1.ID, 500, "signup")
+
+	intact, _ := transfers.CreateTransfer(&models.TransferCreateRequest{FromUserID: user1.ID, ToUserID: user2.ID, Amount: 100})
+	reversed, _ := transfers.CreateTransfer(&models.TransferCreateRequest{FromUserID: user1.ID, ToUserID: user2.ID, Amount: 50})
+	transfers.ReverseTransfer(reversed.IdempotencyKey, "test")
+	broken, _ := transfers.CreateTransfer(&models.TransferCreateRequest{FromUserID: user1.ID, ToUserID: user2.ID, Amount: 25})
+
+	// Lose the receiver's row of one transfer
+	db.Where("transfer_id = ? AND event_type = ?", broken.ID, models.EventTypeTransferIn).Delete(&models.PointLedger{})
+
+	report, err := reconciler.Run(false)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if findTransferDiscrepancy(report, intact.ID) != nil {
+		t.Error("Expected completed transfer to reconcile")
+	}
+
+	if findTransferDiscrepancy(report, reversed.ID) != nil {
+		t.Error("Expected reversed transfer with compensating rows to reconcile")
+	}
+
+	discrepancy := findTransferDiscrepancy(report, broken.ID)
+	if discrepancy == nil || len(discrepancy.Issues) != 1 {
+		t.Fatalf("Expected one issue for transfer %d, got: %+v", broken.ID, discrepancy)
+	}
+
+	// The receiver's balance no longer matches the ledger either
+	if findUserDiscrepancy(report, user2.ID) == nil {
+		t.Error("Expected a balance discrepancy for the receiver")
+	}
+}
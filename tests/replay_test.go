@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"class-go-ai/models"
+	"class-go-ai/repository"
+	"class-go-ai/services"
+)
+
+func TestReplay_RebuildsDriftedBalances(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	points := services.NewPointsService(repository.NewGormStore(db))
+	replay := services.NewReplayService(db)
+
+	alice := &models.User{Name: "ReplayAlice", Email: "replayalice@test.com"}
+	bob := &models.User{Name: "ReplayBob", Email: "replaybob@test.com"}
+	db.Create(alice)
+	db.Create(bob)
+	points.Earn(alice.ID, 100, "signup")
+	points.Redeem(alice.ID, 30, "voucher")
+	points.Earn(bob.ID, 50, "signup")
+
+	// Balance written directly, bypassing the ledger
+	db.Model(&models.User{}).Where("id = ?", alice.ID).Update("points", 999)
+
+	report, err := replay.Replay(nil, true)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if report.EntriesReplayed != 3 || len(report.Mismatches) != 0 {
+		t.Errorf("Expected 3 consistent entries, got: %+v", report)
+	}
+	if len(report.Changes) != 1 || report.Changes[0].UserID != alice.ID || report.Changes[0].Replayed != 70 {
+		t.Fatalf("Expected alice to be rebuilt to 70, got: %+v", report.Changes)
+	}
+
+	// Dry run leaves balances untouched
+	var reloaded models.User
+	db.First(&reloaded, alice.ID)
+	if reloaded.Points != 999 {
+		t.Errorf("Expected dry run to keep 999 points, got %d", reloaded.Points)
+	}
+
+	if _, err := replay.Replay(nil, false); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	db.First(&reloaded, alice.ID)
+	if reloaded.Points != 70 {
+		t.Errorf("Expected 70 points after replay, got %d", reloaded.Points)
+	}
+	var reloadedBob models.User
+	db.First(&reloadedBob, bob.ID)
+	if reloadedBob.Points != 50 {
+		t.Errorf("Expected bob to keep 50 points, got %d", reloadedBob.Points)
+	}
+}
+
+func TestReplay_AsOfAndBalanceAfterMismatch(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	points := services.NewPointsService(repository.NewGormStore(db))
+	replay := services.NewReplayService(db)
+
+	user := &models.User{Name: "ReplayCarol", Email: "replaycarol@test.com"}
+	db.Create(user)
+	points.Earn(user.ID, 100, "signup")
+
+	cutoff := time.Now()
+	time.Sleep(5 * time.Millisecond)
+	points.Earn(user.ID, 25, "bonus")
+
+	report, err := replay.Replay(&cutoff, true)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if report.EntriesReplayed != 1 || len(report.Changes) != 1 || report.Changes[0].Replayed != 100 {
+		t.Errorf("Expected balance of 100 as of cutoff, got: %+v", report)
+	}
+
+	// Corrupt a stored BalanceAfter; replay reports it without rewriting it
+	var entry models.PointLedger
+	db.Where("user_id = ?", user.ID).Order("id DESC").First(&entry)
+	db.Model(&entry).UpdateColumn("balance_after", 1)
+
+	report, err = replay.Replay(nil, false)
+	if !errors.Is(err, services.ErrReplayInconsistent) {
+		t.Fatalf("Expected ErrReplayInconsistent, got: %v", err)
+	}
+	if len(report.Mismatches) != 1 || report.Mismatches[0].EntryID != entry.ID || report.Mismatches[0].Expected != 125 {
+		t.Errorf("Expected mismatch on entry %d, got: %+v", entry.ID, report.Mismatches)
+	}
+	if len(report.Changes) != 0 {
+		t.Errorf("Expected no balance changes, got: %+v", report.Changes)
+	}
+
+	// Past balances are never written over live ones
+	if _, err := replay.Replay(&cutoff, false); !errors.Is(err, services.ErrReplayAsOf) {
+		t.Errorf("Expected ErrReplayAsOf, got: %v", err)
+	}
+}
+
+func TestReplay_RefusesUnrecordedBalances(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	points := services.NewPointsService(repository.NewGormStore(db))
+	replay := services.NewReplayService(db)
+
+	// An opening balance seeded straight into users, then spent through the ledger
+	seeded := &models.User{Name: "ReplaySeeded", Email: "replayseeded@test.com", Points: 1000}
+	untouched := &models.User{Name: "ReplayUntouched", Email: "replayuntouched@test.com", Points: 500}
+	db.Create(seeded)
+	db.Create(untouched)
+	points.Redeem(seeded.ID, 250, "voucher")
+
+	report, err := replay.Replay(nil, false)
+	if !errors.Is(err, services.ErrReplayInconsistent) {
+		t.Fatalf("Expected ErrReplayInconsistent, got: %v", err)
+	}
+	if len(report.Mismatches) != 1 || report.Mismatches[0].UserID != seeded.ID {
+		t.Errorf("Expected the seeded user's entry to mismatch, got: %+v", report.Mismatches)
+	}
+	if len(report.Unrecorded) != 1 || report.Unrecorded[0].UserID != untouched.ID || report.Unrecorded[0].Points != 500 {
+		t.Errorf("Expected the untouched user's 500 points unrecorded, got: %+v", report.Unrecorded)
+	}
+
+	var reloaded models.User
+	db.First(&reloaded, seeded.ID)
+	if reloaded.Points != 750 {
+		t.Errorf("Expected 750 points kept, got %d", reloaded.Points)
+	}
+	var reloadedUntouched models.User
+	db.First(&reloadedUntouched, untouched.ID)
+	if reloadedUntouched.Points != 500 {
+		t.Errorf("Expected 500 points kept, got %d", reloadedUntouched.Points)
+	}
+
+	// Once reconcile records them in the ledger, replay has nothing to change
+	if _, err := services.NewReconcileService(db).Run(true); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	report, err = replay.Replay(nil, false)
+	if !errors.Is(err, services.ErrReplayInconsistent) || len(report.Unrecorded) != 0 {
+		t.Errorf("Expected only the old mismatch left, got %v: %+v", err, report)
+	}
+}
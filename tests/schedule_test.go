@@ -0,0 +1,233 @@
+package tests
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"class-go-ai/events"
+	"class-go-ai/models"
+	"class-go-ai/repository"
+	"class-go-ai/services"
+)
+
+func TestSchedule_MonthlyRuns(t *testing.T) {
+	db := setupTestDB(t)
+	schedules := services.NewScheduleService(db, services.NewTransferService(repository.NewGormStore(db)))
+
+	// Create test users
+	user1 := &models.User{Name: "SchedAlice1", Email: "schedalice1@test.com", Points: 1000}
+	user2 := &models.User{Name: "SchedBob1", Email: "schedbob1@test.com", Points: 0}
+	db.Create(user1)
+	db.Create(user2)
+
+	start := time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC)
+	schedule, err := schedules.CreateSchedule(&models.ScheduledTransferCreateRequest{
+		FromUserID: user1.ID,
+		ToUserID:   user2.ID,
+		Amount:     100,
+		Frequency:  models.ScheduleFrequencyMonthly,
+		StartAt:    start,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create schedule: %v", err)
+	}
+
+	// Not due yet
+	if ran, _ := schedules.RunDue(start.Add(-time.Minute)); ran != 0 {
+		t.Errorf("Expected no runs before start, got: %d", ran)
+	}
+
+	if _, err := schedules.RunDue(start.Add(time.Minute)); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	updated, _ := schedules.GetSchedule(schedule.ID)
+	expected := time.Date(2026, 2, 28, 9, 0, 0, 0, time.UTC)
+	if updated.NextRunAt == nil || !updated.NextRunAt.Equal(expected) {
+		t.Errorf("Expected next run %v (clamped to end of February), got: %v", expected, updated.NextRunAt)
+	}
+
+	runs, _ := schedules.ListRuns(schedule.ID)
+	if len(runs) != 1 || runs[0].Status != models.ScheduleRunSucceeded || runs[0].TransferID == nil {
+		t.Fatalf("Expected one succeeded run with a transfer, got: %+v", runs)
+	}
+
+	var receiver models.User
+	db.First(&receiver, user2.ID)
+	if receiver.Points != 100 {
+		t.Errorf("Expected receiver points 100, got: %d", receiver.Points)
+	}
+}
+
+func TestSchedule_RetryThenSkip(t *testing.T) {
+	db := setupTestDB(t)
+	schedules := services.NewScheduleService(db, services.NewTransferService(repository.NewGormStore(db)))
+	schedules.SetRetryDelay(time.Hour)
+
+	// Create test users; sender cannot cover the amount
+	user1 := &models.User{Name: "SchedAlice2", Email: "schedalice2@test.com", Points: 50}
+	user2 := &models.User{Name: "SchedBob2", Email: "schedbob2@test.com", Points: 0}
+	db.Create(user1)
+	db.Create(user2)
+
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	schedule, err := schedules.CreateSchedule(&models.ScheduledTransferCreateRequest{
+		FromUserID:     user1.ID,
+		ToUserID:       user2.ID,
+		Amount:         100,
+		Frequency:      models.ScheduleFrequencyDaily,
+		StartAt:        start,
+		OnInsufficient: models.InsufficientPolicyRetry,
+		MaxRetries:     1,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create schedule: %v", err)
+	}
+
+	// First attempt fails and is retried an hour later
+	now := start.Add(time.Minute)
+	schedules.RunDue(now)
+
+	updated, _ := schedules.GetSchedule(schedule.ID)
+	if updated.Attempt != 1 || !updated.NextRunAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("Expected retry scheduled at %v, got attempt %d at %v", now.Add(time.Hour), updated.Attempt, updated.NextRunAt)
+	}
+
+	// Retry also fails and the occurrence is skipped
+	schedules.RunDue(now.Add(time.Hour))
+
+	updated, _ = schedules.GetSchedule(schedule.ID)
+	if updated.Attempt != 0 || updated.Occurrence != 1 || !updated.NextRunAt.Equal(start.AddDate(0, 0, 1)) {
+		t.Errorf("Expected skip to next day, got occurrence %d at %v", updated.Occurrence, updated.NextRunAt)
+	}
+
+	runs, _ := schedules.ListRuns(schedule.ID)
+	if len(runs) != 2 {
+		t.Fatalf("Expected 2 runs, got: %d", len(runs))
+	}
+
+	if runs[0].Status != models.ScheduleRunSkipped || runs[1].Status != models.ScheduleRunRetrying {
+		t.Errorf("Expected retrying then skipped, got: %s, %s", runs[1].Status, runs[0].Status)
+	}
+
+	if runs[0].TransferKey == runs[1].TransferKey {
+		t.Error("Expected each attempt to use its own idempotency key")
+	}
+}
+
+func TestSchedule_OnceAndPause(t *testing.T) {
+	db := setupTestDB(t)
+	schedules := services.NewScheduleService(db, services.NewTransferService(repository.NewGormStore(db)))
+
+	// Create test users
+	user1 := &models.User{Name: "SchedAlice3", Email: "schedalice3@test.com", Points: 1000}
+	user2 := &models.User{Name: "SchedBob3", Email: "schedbob3@test.com", Points: 0}
+	db.Create(user1)
+	db.Create(user2)
+
+	start := time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC)
+	once, _ := schedules.CreateSchedule(&models.ScheduledTransferCreateRequest{
+		FromUserID: user1.ID,
+		ToUserID:   user2.ID,
+		Amount:     100,
+		Frequency:  models.ScheduleFrequencyOnce,
+		StartAt:    start,
+	})
+	paused, _ := schedules.CreateSchedule(&models.ScheduledTransferCreateRequest{
+		FromUserID: user1.ID,
+		ToUserID:   user2.ID,
+		Amount:     100,
+		Frequency:  models.ScheduleFrequencyWeekly,
+		StartAt:    start,
+	})
+
+	if _, err := schedules.PauseSchedule(paused.ID); err != nil {
+		t.Fatalf("Failed to pause schedule: %v", err)
+	}
+
+	schedules.RunDue(start.Add(time.Minute))
+
+	updated, _ := schedules.GetSchedule(once.ID)
+	if updated.Status != models.ScheduleStatusCompleted || updated.NextRunAt != nil {
+		t.Errorf("Expected one-off schedule to complete, got: %s", updated.Status)
+	}
+
+	runs, _ := schedules.ListRuns(paused.ID)
+	if len(runs) != 0 {
+		t.Errorf("Expected paused schedule not to run, got %d runs", len(runs))
+	}
+
+	// Pausing twice is not allowed
+	if _, err := schedules.PauseSchedule(paused.ID); err != services.ErrScheduleStatus {
+		t.Errorf("Expected ErrScheduleStatus, got: %v", err)
+	}
+}
+
+// publishHook runs fn on every committed change, between a scheduled
+// transfer and the bookkeeping of its run
+type publishHook func()
+
+func (h publishHook) Publish(...events.Message) { h() }
+
+func TestSchedule_ChangedWhileRunning(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	transfers := services.NewTransferService(repository.NewGormStore(db))
+	schedules := services.NewScheduleService(db, transfers)
+
+	user1 := &models.User{Name: "SchedRaceAlice", Email: "schedracealice@test.com", Points: 1000}
+	user2 := &models.User{Name: "SchedRaceBob", Email: "schedracebob@test.com"}
+	db.Create(user1)
+	db.Create(user2)
+
+	start := time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC)
+	deleted, _ := schedules.CreateSchedule(&models.ScheduledTransferCreateRequest{
+		FromUserID: user1.ID, ToUserID: user2.ID, Amount: 100,
+		Frequency: models.ScheduleFrequencyWeekly, StartAt: start,
+	})
+	paused, _ := schedules.CreateSchedule(&models.ScheduledTransferCreateRequest{
+		FromUserID: user1.ID, ToUserID: user2.ID, Amount: 100,
+		Frequency: models.ScheduleFrequencyWeekly, StartAt: start.Add(time.Minute),
+	})
+
+	// Both are loaded as due; while the first pays out, it is deleted and
+	// the second is paused
+	var once sync.Once
+	transfers.SetPublisher(publishHook(func() {
+		once.Do(func() {
+			if err := schedules.DeleteSchedule(deleted.ID); err != nil {
+				t.Errorf("Failed to delete schedule: %v", err)
+			}
+			if _, err := schedules.PauseSchedule(paused.ID); err != nil {
+				t.Errorf("Failed to pause schedule: %v", err)
+			}
+		})
+	}))
+
+	if ran, err := schedules.RunDue(start.Add(time.Hour)); err != nil || ran != 1 {
+		t.Fatalf("Expected only the first schedule to run, got %d: %v", ran, err)
+	}
+
+	if _, err := schedules.GetSchedule(deleted.ID); err != services.ErrScheduleNotFound {
+		t.Errorf("Expected the deleted schedule to stay deleted, got: %v", err)
+	}
+	var runs int64
+	db.Model(&models.ScheduledTransferRun{}).Where("schedule_id = ?", deleted.ID).Count(&runs)
+	if runs != 1 {
+		t.Errorf("Expected the payout of the deleted schedule recorded, got %d runs", runs)
+	}
+
+	reloaded, _ := schedules.GetSchedule(paused.ID)
+	if reloaded.Status != models.ScheduleStatusPaused {
+		t.Errorf("Expected the second schedule to stay paused, got: %s", reloaded.Status)
+	}
+	if pausedRuns, _ := schedules.ListRuns(paused.ID); len(pausedRuns) != 0 {
+		t.Errorf("Expected the paused schedule not to run, got %d runs", len(pausedRuns))
+	}
+
+	var receiver models.User
+	db.First(&receiver, user2.ID)
+	if receiver.Points != 100 {
+		t.Errorf("Expected a single payout of 100, got %d", receiver.Points)
+	}
+}
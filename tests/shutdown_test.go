@@ -0,0 +1,170 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"class-go-ai/config"
+	"class-go-ai/middleware"
+	"class-go-ai/models"
+	"class-go-ai/repository"
+	"class-go-ai/services"
+	"class-go-ai/workers"
+
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestShutdown_StreamsEndWhenBrokerCloses(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	app, c := setupContainerApp(t, db, config.Default())
+	addr := serve(t, app)
+
+	alice, _ := c.Auth.Register(&models.RegisterRequest{Name: "DrainAlice", Email: "drainalice@test.com", Password: "correct-horse"})
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("http://%s/users/%d/events", addr, alice.User.ID), nil)
+	req.Header.Set("Authorization", "Bearer "+alice.AccessToken)
+	resp, err := (&http.Client{Timeout: 5 * time.Second}).Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	reader.ReadString('\n') // ": connected"
+
+	socket := dialSocket(t, addr, alice.AccessToken)
+	readSocket(t, socket)
+
+	c.Broker.Close()
+
+	// The SSE response ends, so the client can resume elsewhere
+	if _, err := io.ReadAll(reader); err != nil {
+		t.Errorf("Expected the stream to end cleanly, got: %v", err)
+	}
+
+	// The socket is closed with "going away"
+	socket.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err = socket.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("Expected a going away close, got: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if open := c.Broker.Wait(ctx); open != 0 {
+		t.Errorf("Expected every stream to be closed, got %d open", open)
+	}
+}
+
+func TestShutdown_WorkerGroupReportsUnfinishedWorkers(t *testing.T) {
+	group := workers.NewGroup(context.Background())
+	release := make(chan struct{})
+
+	group.Go("ticker", time.Minute, func(ctx context.Context) {
+		<-ctx.Done()
+	})
+	group.Go("stuck", time.Minute, func(ctx context.Context) {
+		<-ctx.Done()
+		<-release
+	})
+
+	group.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if running := group.Wait(ctx); !slices.Equal(running, []string{"stuck"}) {
+		t.Errorf("Expected only the stuck worker to be reported, got: %v", running)
+	}
+
+	close(release)
+	if running := group.Wait(context.Background()); running != nil {
+		t.Errorf("Expected every worker to finish, got: %v", running)
+	}
+}
+
+func TestShutdown_InFlightRequests(t *testing.T) {
+	inFlight := middleware.NewInFlight()
+	started, release := make(chan struct{}), make(chan struct{})
+
+	app := fiber.New()
+	app.Use(inFlight.Handler())
+	app.Get("/slow", func(c *fiber.Ctx) error {
+		close(started)
+		<-release
+		return c.SendString("done")
+	})
+	addr := serve(t, app)
+
+	finished := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/slow")
+		if err == nil {
+			resp.Body.Close()
+		}
+		finished <- err
+	}()
+	<-started
+
+	// A deadline that passes mid-request reports it as abandoned
+	if err := app.ShutdownWithTimeout(50 * time.Millisecond); err == nil {
+		t.Error("Expected the shutdown to time out")
+	}
+	if requests := inFlight.Requests(); !slices.Equal(requests, []string{"GET /slow"}) {
+		t.Errorf("Expected the slow request to be in flight, got: %v", requests)
+	}
+
+	close(release)
+	if err := <-finished; err != nil {
+		t.Errorf("Expected the request to finish, got: %v", err)
+	}
+	if requests := inFlight.Requests(); len(requests) != 0 {
+		t.Errorf("Expected no request in flight, got: %v", requests)
+	}
+}
+
+func TestShutdown_WebhookDeliveryStopsStarting(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	transfers := services.NewTransferService(repository.NewGormStore(db))
+	webhooks := services.NewWebhookService(db)
+	webhooks.SetAllowPrivateTargets(true)
+
+	sender := &models.User{Name: "DrainSender", Email: "drainsender@test.com", Points: 100}
+	receiver := &models.User{Name: "DrainReceiver", Email: "drainreceiver@test.com"}
+	db.Create(sender)
+	db.Create(receiver)
+
+	recv := &webhookReceiver{secret: []byte("whsec_drain"), status: 200}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+	webhooks.CreateSubscription(sender.ID, &models.WebhookCreateRequest{
+		URL:    server.URL + "/hooks",
+		Secret: "whsec_drain",
+		Events: []models.WebhookEvent{models.WebhookTransferCompleted},
+	}, false)
+	transfers.CreateTransfer(&models.TransferCreateRequest{FromUserID: sender.ID, ToUserID: receiver.ID, Amount: 10})
+
+	now := time.Now()
+	webhooks.Dispatch(now)
+
+	// Once shutting down, nothing new is sent and no attempt is used up
+	stopped, cancel := context.WithCancel(context.Background())
+	cancel()
+	if delivered, err := webhooks.DeliverDue(stopped, now); delivered != 0 || err != nil || len(recv.payloads) != 0 {
+		t.Errorf("Expected no delivery after shutdown began, got %d (%v)", delivered, err)
+	}
+	var delivery models.WebhookDelivery
+	db.First(&delivery)
+	if delivery.Status != models.DeliveryPending || delivery.Attempts != 0 {
+		t.Errorf("Expected the delivery to stay pending, got: %+v", delivery)
+	}
+
+	if delivered, err := webhooks.DeliverDue(context.Background(), now); delivered != 1 || err != nil {
+		t.Errorf("Expected the next process to deliver it, got %d (%v)", delivered, err)
+	}
+}
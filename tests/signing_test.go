@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"class-go-ai/config"
+	"class-go-ai/middleware"
+	"class-go-ai/models"
+	"class-go-ai/signing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestSignature_Middleware(t *testing.T) {
+	secret := []byte("partner-secret")
+	now := time.Unix(1700000000, 0)
+
+	app := fiber.New()
+	app.Post("/transfers", middleware.RequireSignature(middleware.SignatureConfig{
+		Secret: secret,
+		Window: time.Minute,
+		Now:    func() time.Time { return now },
+	}), func(c *fiber.Ctx) error {
+		return c.SendStatus(201)
+	})
+
+	body := `{"toUserId":2,"amount":10}`
+	send := func(timestamp, nonce, signature, payload string) int {
+		req := httptest.NewRequest("POST", "/transfers?mode=x", strings.NewReader(payload))
+		req.Header.Set(signing.HeaderTimestamp, timestamp)
+		req.Header.Set(signing.HeaderNonce, nonce)
+		req.Header.Set(signing.HeaderSignature, signature)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		return resp.StatusCode
+	}
+
+	ts := strconv.FormatInt(now.Unix(), 10)
+	valid := signing.Sign(secret, "POST", "/transfers?mode=x", ts, "nonce-1", []byte(body))
+
+	if status := send(ts, "nonce-1", valid, body); status != 201 {
+		t.Errorf("Expected signed request to pass, got %d", status)
+	}
+	if status := send(ts, "nonce-1", valid, body); status != 401 {
+		t.Errorf("Expected replayed nonce to be rejected, got %d", status)
+	}
+
+	tampered := signing.Sign(secret, "POST", "/transfers?mode=x", ts, "nonce-2", []byte(body))
+	if status := send(ts, "nonce-2", tampered, `{"toUserId":2,"amount":9999}`); status != 401 {
+		t.Errorf("Expected tampered body to be rejected, got %d", status)
+	}
+
+	// A forged request must not burn the nonce
+	if status := send(ts, "nonce-2", tampered, body); status != 201 {
+		t.Errorf("Expected nonce-2 to still be usable, got %d", status)
+	}
+
+	stale := strconv.FormatInt(now.Add(-2*time.Minute).Unix(), 10)
+	staleSig := signing.Sign(secret, "POST", "/transfers?mode=x", stale, "nonce-3", []byte(body))
+	if status := send(stale, "nonce-3", staleSig, body); status != 401 {
+		t.Errorf("Expected stale timestamp to be rejected, got %d", status)
+	}
+
+	if status := send("", "", "", body); status != 401 {
+		t.Errorf("Expected unsigned request to be rejected, got %d", status)
+	}
+}
+
+func TestSignature_PartnerTransfers(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.PartnerSigningSecret = "partner-secret"
+	db := setupIsolatedTestDB(t)
+	app, auth := setupAuthAppWithConfig(t, db, cfg)
+
+	partner, _ := auth.Register(&models.RegisterRequest{Name: "SignPartner", Email: "signpartner@test.com", Password: "password-p"})
+	payee := &models.User{Name: "SignPayee", Email: "signpayee@test.com"}
+	db.Create(payee)
+	db.Model(&models.User{}).Where("id = ?", partner.User.ID).Update("points", 100)
+
+	var key models.APIKeyResponse
+	doJSON(t, app, "POST", "/api-keys", partner.AccessToken, fiber.Map{"name": "partner", "scopes": []string{"transfers:write"}}, &key)
+
+	body := `{"toUserId":` + strconv.Itoa(int(payee.ID)) + `,"amount":10}`
+	request := func(sign bool) int {
+		req := httptest.NewRequest("POST", "/transfers", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(middleware.HeaderAPIKey, key.Key)
+		if sign {
+			if err := signing.SignRequest(req, []byte("partner-secret")); err != nil {
+				t.Fatalf("Failed to sign: %v", err)
+			}
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		return resp.StatusCode
+	}
+
+	if status := request(false); status != 401 {
+		t.Errorf("Expected unsigned API key request to be rejected, got %d", status)
+	}
+	if status := request(true); status != 201 {
+		t.Errorf("Expected signed API key request to pass, got %d", status)
+	}
+
+	// Sessions do not need a signature
+	if status := doJSON(t, app, "POST", "/transfers", partner.AccessToken, fiber.Map{"toUserId": payee.ID, "amount": 5}, nil); status != 201 {
+		t.Errorf("Expected session request to pass, got %d", status)
+	}
+}
+
+func TestSignature_NonceStoreDropsExpired(t *testing.T) {
+	nonces := signing.NewNonceStore()
+	now := time.Unix(1_700_000_000, 0)
+
+	// Expiries out of order, as client timestamps skew
+	for i, ttl := range []time.Duration{3, 1, 2} {
+		if !nonces.Use(fmt.Sprintf("nonce-%d", i), now, now.Add(ttl*time.Minute)) {
+			t.Fatalf("Expected nonce-%d to be new", i)
+		}
+	}
+	if nonces.Use("nonce-1", now, now.Add(time.Minute)) {
+		t.Error("Expected a reused nonce to be refused")
+	}
+
+	later := now.Add(90 * time.Second)
+	if !nonces.Use("nonce-3", later, later.Add(time.Minute)) || nonces.Len() != 3 {
+		t.Errorf("Expected only nonce-1 dropped, got %d left", nonces.Len())
+	}
+	if !nonces.Use("nonce-1", later, later.Add(time.Minute)) {
+		t.Error("Expected an expired nonce to be usable again")
+	}
+	if nonces.Use("nonce-0", later, later.Add(time.Minute)) {
+		t.Error("Expected an unexpired nonce to stay refused")
+	}
+
+	end := now.Add(time.Hour)
+	nonces.Use("nonce-4", end, end.Add(time.Minute))
+	if nonces.Len() != 1 {
+		t.Errorf("Expected every earlier nonce dropped, got %d", nonces.Len())
+	}
+}
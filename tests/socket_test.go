@@ -0,0 +1,194 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"class-go-ai/config"
+	"class-go-ai/events"
+	"class-go-ai/handlers"
+	"class-go-ai/models"
+
+	"github.com/fasthttp/websocket"
+)
+
+// dialSocket opens the transfer WebSocket, with a bearer token if given
+func dialSocket(t *testing.T, addr, token string) *websocket.Conn {
+	header := http.Header{}
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/ws/transfers", header)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readSocket reads the next message from conn
+func readSocket(t *testing.T, conn *websocket.Conn) handlers.SocketMessage {
+	var message handlers.SocketMessage
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err := conn.ReadJSON(&message); err != nil {
+		t.Fatalf("Failed to read message: %v", err)
+	}
+	return message
+}
+
+func TestSocket_TransferNotifications(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	app, c := setupContainerApp(t, db, config.Default())
+	auth, transfers := c.Auth, c.Transfers
+
+	addr := serve(t, app)
+
+	alice, _ := auth.Register(&models.RegisterRequest{Name: "SocketAlice", Email: "socketalice@test.com", Password: "correct-horse"})
+	bob, _ := auth.Register(&models.RegisterRequest{Name: "SocketBob", Email: "socketbob@test.com", Password: "correct-horse"})
+	agent, _ := auth.Register(&models.RegisterRequest{Name: "SocketAgent", Email: "socketagent@test.com", Password: "correct-horse"})
+	auth.SetRole(agent.User.ID, models.RoleSupport)
+	db.Model(&models.User{}).Where("id = ?", alice.User.ID).Update("points", 100)
+
+	// A member authenticates on the upgrade and may only watch itself
+	member := dialSocket(t, addr, alice.AccessToken)
+	if ready := readSocket(t, member); ready.Type != handlers.SocketReady || ready.UserID != alice.User.ID {
+		t.Fatalf("Expected ready for %d, got: %+v", alice.User.ID, ready)
+	}
+
+	member.WriteJSON(handlers.SocketMessage{Type: handlers.SocketSubscribe, UserIDs: []uint{alice.User.ID, bob.User.ID}})
+	if reply := readSocket(t, member); reply.Type != handlers.SocketError || reply.Error != "FORBIDDEN" {
+		t.Errorf("Expected FORBIDDEN, got: %+v", reply)
+	}
+	member.WriteJSON(handlers.SocketMessage{Type: handlers.SocketSubscribe, UserIDs: []uint{alice.User.ID}})
+	if reply := readSocket(t, member); reply.Type != handlers.SocketSubscribed || len(reply.UserIDs) != 1 {
+		t.Errorf("Expected subscribed to 1 user, got: %+v", reply)
+	}
+
+	// A browser authenticates with its first message; support may watch anyone
+	support := dialSocket(t, addr, "")
+	support.WriteJSON(handlers.SocketMessage{Type: handlers.SocketAuth, Token: agent.AccessToken})
+	if ready := readSocket(t, support); ready.Type != handlers.SocketReady {
+		t.Fatalf("Expected ready, got: %+v", ready)
+	}
+	support.WriteJSON(handlers.SocketMessage{Type: handlers.SocketSubscribe, UserIDs: []uint{bob.User.ID}})
+	if reply := readSocket(t, support); reply.Type != handlers.SocketSubscribed {
+		t.Errorf("Expected subscribed, got: %+v", reply)
+	}
+
+	transfer, err := transfers.CreateTransfer(&models.TransferCreateRequest{FromUserID: alice.User.ID, ToUserID: bob.User.ID, Amount: 40})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	for _, conn := range []*websocket.Conn{member, support} {
+		message := readSocket(t, conn)
+		if message.Type != handlers.SocketTransfer || message.Transfer.ID != transfer.ID || message.Transfer.Status != models.TransferStatusCompleted {
+			t.Errorf("Expected completed transfer %d, got: %+v", transfer.ID, message)
+		}
+	}
+
+	// Anything but auth before authenticating closes the connection
+	anonymous := dialSocket(t, addr, "")
+	anonymous.WriteJSON(handlers.SocketMessage{Type: handlers.SocketSubscribe, UserIDs: []uint{bob.User.ID}})
+	if reply := readSocket(t, anonymous); reply.Error != "UNAUTHORIZED" {
+		t.Errorf("Expected UNAUTHORIZED, got: %+v", reply)
+	}
+	anonymous.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := anonymous.ReadMessage(); err == nil {
+		t.Error("Expected the connection to be closed")
+	}
+
+	// Plain HTTP requests are refused
+	if status := doJSON(t, app, "GET", "/ws/transfers", alice.AccessToken, nil, nil); status != 426 {
+		t.Errorf("Expected 426, got: %d", status)
+	}
+}
+
+func TestSocket_SlowConsumerGetsGaps(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	app, c := setupContainerApp(t, db, config.Default())
+	auth, broker := c.Auth, c.Broker
+	addr := serve(t, app)
+
+	alice, _ := auth.Register(&models.RegisterRequest{Name: "GapAlice", Email: "gapalice@test.com", Password: "correct-horse"})
+
+	conn := dialSocket(t, addr, alice.AccessToken)
+	readSocket(t, conn)
+	conn.WriteJSON(handlers.SocketMessage{Type: handlers.SocketSubscribe, UserIDs: []uint{alice.User.ID}})
+	readSocket(t, conn)
+
+	// Publishing far faster than the socket writes never blocks; every
+	// message is either delivered or counted in a gap
+	const published = 5000
+	for i := 1; i <= published; i++ {
+		broker.Publish(events.Message{Type: events.MessageTransfer, Transfer: &models.Transfer{ID: uint(i), FromUserID: alice.User.ID}})
+	}
+
+	var delivered, missed uint64
+	for delivered+missed < published {
+		message := readSocket(t, conn)
+		switch message.Type {
+		case handlers.SocketTransfer:
+			delivered++
+		case handlers.SocketGap:
+			missed += message.Missed
+		}
+	}
+
+	if delivered+missed != published {
+		t.Errorf("Expected %d messages accounted for, got %d delivered + %d missed", published, delivered, missed)
+	}
+	if missed != broker.Dropped() {
+		t.Errorf("Expected gaps to match %d dropped, got: %d", broker.Dropped(), missed)
+	}
+}
+
+func TestSocket_RechecksSession(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	app, c := setupContainerApp(t, db, config.Default())
+	auth, broker := c.Auth, c.Broker
+	addr := serve(t, app)
+
+	alice, _ := auth.Register(&models.RegisterRequest{Name: "RecheckAlice", Email: "recheckalice@test.com", Password: "correct-horse"})
+	agent, _ := auth.Register(&models.RegisterRequest{Name: "RecheckAgent", Email: "recheckagent@test.com", Password: "correct-horse"})
+	auth.SetRole(agent.User.ID, models.RoleSupport)
+
+	conn := dialSocket(t, addr, agent.AccessToken)
+	readSocket(t, conn)
+	conn.WriteJSON(handlers.SocketMessage{Type: handlers.SocketSubscribe, UserIDs: []uint{agent.User.ID, alice.User.ID}})
+	if reply := readSocket(t, conn); reply.Type != handlers.SocketSubscribed || len(reply.UserIDs) != 2 {
+		t.Fatalf("Expected subscribed to 2 users, got: %+v", reply)
+	}
+
+	// Once demoted, the next transfer of another user drops those
+	// subscriptions instead of being sent
+	auth.SetRole(agent.User.ID, models.RoleMember)
+	broker.Publish(events.Message{Type: events.MessageTransfer, Transfer: &models.Transfer{ID: 1, FromUserID: alice.User.ID}})
+	if reply := readSocket(t, conn); reply.Type != handlers.SocketSubscribed || len(reply.UserIDs) != 1 || reply.UserIDs[0] != agent.User.ID {
+		t.Errorf("Expected only the agent's own subscription left, got: %+v", reply)
+	}
+	conn.WriteJSON(handlers.SocketMessage{Type: handlers.SocketSubscribe, UserIDs: []uint{alice.User.ID}})
+	if reply := readSocket(t, conn); reply.Type != handlers.SocketError || reply.Error != "FORBIDDEN" {
+		t.Errorf("Expected FORBIDDEN after the demotion, got: %+v", reply)
+	}
+
+	// A promotion applies to the open socket too
+	auth.SetRole(agent.User.ID, models.RoleSupport)
+	conn.WriteJSON(handlers.SocketMessage{Type: handlers.SocketSubscribe, UserIDs: []uint{alice.User.ID}})
+	if reply := readSocket(t, conn); reply.Type != handlers.SocketSubscribed || len(reply.UserIDs) != 2 {
+		t.Errorf("Expected subscribed to 2 users again, got: %+v", reply)
+	}
+
+	// The socket closes once its token expires
+	auth.SetTokenTTLs(time.Second, time.Hour)
+	short, _ := auth.Login("recheckalice@test.com", "correct-horse")
+	expiring := dialSocket(t, addr, short.AccessToken)
+	readSocket(t, expiring)
+	expiring.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err := expiring.ReadMessage()
+	if closeErr, ok := err.(*websocket.CloseError); !ok || closeErr.Code != 4001 || closeErr.Text != "token expired" {
+		t.Errorf("Expected close 4001 token expired, got: %v", err)
+	}
+}
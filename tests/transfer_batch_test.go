@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"testing"
+
+	"class-go-ai/models"
+	"class-go-ai/repository"
+	"class-go-ai/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestTransferBatch_AtomicRollsBack(t *testing.T) {
+	db := setupTestDB(t)
+	service := services.NewTransferService(repository.NewGormStore(db))
+
+	// Create test users
+	treasury := &models.User{Name: "BatchTreasury1", Email: "batchtreasury1@test.com", Points: 150}
+	user1 := &models.User{Name: "BatchAlice1", Email: "batchalice1@test.com", Points: 0}
+	user2 := &models.User{Name: "BatchBob1", Email: "batchbob1@test.com", Points: 0}
+	db.Create(treasury)
+	db.Create(user1)
+	db.Create(user2)
+
+	// Second item exceeds what is left after the first
+	results, err := service.CreateTransferBatch([]*models.TransferCreateRequest{
+		{FromUserID: treasury.ID, ToUserID: user1.ID, Amount: 100},
+		{FromUserID: treasury.ID, ToUserID: user2.ID, Amount: 100},
+	}, true)
+
+	if err != services.ErrInsufficientPoints {
+		t.Fatalf("Expected ErrInsufficientPoints, got: %v", err)
+	}
+
+	if results[0].Err != services.ErrBatchRolledBack || results[1].Err != services.ErrInsufficientPoints {
+		t.Errorf("Expected rolled back + insufficient, got: %v, %v", results[0].Err, results[1].Err)
+	}
+
+	// Nothing was applied
+	var updatedTreasury, updatedUser1 models.User
+	db.First(&updatedTreasury, treasury.ID)
+	db.First(&updatedUser1, user1.ID)
+
+	if updatedTreasury.Points != 150 || updatedUser1.Points != 0 {
+		t.Errorf("Expected balances unchanged, got treasury %d, user1 %d", updatedTreasury.Points, updatedUser1.Points)
+	}
+
+	var count int64
+	db.Model(&models.Transfer{}).Where("from_user_id = ?", treasury.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("Expected no transfers recorded, got: %d", count)
+	}
+}
+
+func TestTransferBatch_Partial(t *testing.T) {
+	db := setupTestDB(t)
+	service := services.NewTransferService(repository.NewGormStore(db))
+
+	// Create test users
+	treasury := &models.User{Name: "BatchTreasury2", Email: "batchtreasury2@test.com", Points: 150}
+	user1 := &models.User{Name: "BatchAlice2", Email: "batchalice2@test.com", Points: 0}
+	user2 := &models.User{Name: "BatchBob2", Email: "batchbob2@test.com", Points: 0}
+	db.Create(treasury)
+	db.Create(user1)
+	db.Create(user2)
+
+	results, err := service.CreateTransferBatch([]*models.TransferCreateRequest{
+		{FromUserID: treasury.ID, ToUserID: user1.ID, Amount: 100},
+		{FromUserID: treasury.ID, ToUserID: user2.ID, Amount: 100},
+		{FromUserID: treasury.ID, ToUserID: 999999, Amount: 10},
+		{FromUserID: treasury.ID, ToUserID: treasury.ID, Amount: 10},
+	}, false)
+
+	if err != nil {
+		t.Fatalf("Expected no batch error in partial mode, got: %v", err)
+	}
+
+	expected := []error{nil, services.ErrInsufficientPoints, services.ErrUserNotFound, services.ErrSameUser}
+	for i, want := range expected {
+		if results[i].Err != want {
+			t.Errorf("Item %d: expected %v, got: %v", i, want, results[i].Err)
+		}
+	}
+
+	var updatedTreasury models.User
+	db.First(&updatedTreasury, treasury.ID)
+
+	if updatedTreasury.Points != 50 {
+		t.Errorf("Expected treasury points 50, got: %d", updatedTreasury.Points)
+	}
+}
+
+func TestTransferBatch_ItemKeysDoNotCollideWithSingleKeys(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	app, auth := setupAuthApp(t, db)
+
+	alice, _ := auth.Register(&models.RegisterRequest{Name: "KeyAlice", Email: "keyalice@test.com", Password: "correct-horse"})
+	bob, _ := auth.Register(&models.RegisterRequest{Name: "KeyBob", Email: "keybob@test.com", Password: "correct-horse"})
+	db.Model(&models.User{}).Where("id = ?", alice.User.ID).Update("points", 100)
+
+	// A single transfer keyed like the first item of batch "order"
+	single := fiber.Map{"toUserId": bob.User.ID, "amount": 10}
+	if status := doJSONHeaders(t, app, "POST", "/transfers", alice.AccessToken, map[string]string{"Idempotency-Key": "order-0"}, single, nil); status != 201 {
+		t.Fatalf("Expected 201, got: %d", status)
+	}
+
+	var response models.TransferBatchResponse
+	batch := fiber.Map{"mode": "atomic", "items": []fiber.Map{{"toUserId": bob.User.ID, "amount": 25}}}
+	if status := doJSONHeaders(t, app, "POST", "/transfers/batch", alice.AccessToken, map[string]string{"Idempotency-Key": "order"}, batch, &response); status != 201 {
+		t.Fatalf("Expected 201, got: %d %+v", status, response)
+	}
+	if item := response.Results[0]; item.Status != "succeeded" || item.Transfer.Amount != 25 || item.Transfer.IdempotencyKey != "batch:order:0" {
+		t.Errorf("Expected a new transfer of 25 keyed batch:order:0, got: %+v", item)
+	}
+
+	// Clients cannot claim the batch namespace for single transfers
+	if status := doJSONHeaders(t, app, "POST", "/transfers", alice.AccessToken, map[string]string{"Idempotency-Key": "batch:order:0"}, single, nil); status != 400 {
+		t.Errorf("Expected 400, got: %d", status)
+	}
+
+	var sender models.User
+	db.First(&sender, alice.User.ID)
+	if sender.Points != 65 {
+		t.Errorf("Expected 65 points left, got: %d", sender.Points)
+	}
+}
@@ -0,0 +1,186 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"class-go-ai/models"
+	"class-go-ai/repository"
+	"class-go-ai/services"
+)
+
+func TestHoldTransfer_Capture(t *testing.T) {
+	db := setupTestDB(t)
+	service := services.NewTransferService(repository.NewGormStore(db))
+
+	// Create test users
+	user1 := &models.User{Name: "HoldAlice1", Email: "holdalice1@test.com", Points: 1000}
+	user2 := &models.User{Name: "HoldBob1", Email: "holdbob1@test.com", Points: 0}
+	db.Create(user1)
+	db.Create(user2)
+
+	hold, err := service.CreateTransfer(&models.TransferCreateRequest{
+		FromUserID: user1.ID,
+		ToUserID:   user2.ID,
+		Amount:     400,
+		Hold:       true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create hold: %v", err)
+	}
+
+	if hold.Status != models.TransferStatusPending || hold.HoldExpiresAt == nil {
+		t.Fatalf("Expected pending hold with expiry, got status %s", hold.Status)
+	}
+
+	// Points are reserved but not moved, and no ledger yet
+	var sender models.User
+	db.First(&sender, user1.ID)
+
+	if sender.Points != 1000 || sender.HeldPoints != 400 {
+		t.Errorf("Expected points 1000 / held 400, got: %d / %d", sender.Points, sender.HeldPoints)
+	}
+
+	var count int64
+	db.Model(&models.PointLedger{}).Where("transfer_id = ?", hold.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("Expected no ledger entries before capture, got: %d", count)
+	}
+
+	// Reserved points cannot be spent twice
+	_, err = service.CreateTransfer(&models.TransferCreateRequest{
+		FromUserID: user1.ID,
+		ToUserID:   user2.ID,
+		Amount:     700,
+	})
+	if err != services.ErrInsufficientPoints {
+		t.Errorf("Expected ErrInsufficientPoints against available balance, got: %v", err)
+	}
+
+	captured, err := service.CaptureTransfer(hold.IdempotencyKey)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if captured.Status != models.TransferStatusCompleted {
+		t.Errorf("Expected status completed, got: %s", captured.Status)
+	}
+
+	var receiver models.User
+	db.First(&sender, user1.ID)
+	db.First(&receiver, user2.ID)
+
+	if sender.Points != 600 || sender.HeldPoints != 0 {
+		t.Errorf("Expected points 600 / held 0, got: %d / %d", sender.Points, sender.HeldPoints)
+	}
+
+	if receiver.Points != 400 {
+		t.Errorf("Expected receiver points 400, got: %d", receiver.Points)
+	}
+
+	db.Model(&models.PointLedger{}).Where("transfer_id = ?", hold.ID).Count(&count)
+	if count != 2 {
+		t.Errorf("Expected 2 ledger entries after capture, got: %d", count)
+	}
+}
+
+func TestHoldTransfer_Void(t *testing.T) {
+	db := setupTestDB(t)
+	service := services.NewTransferService(repository.NewGormStore(db))
+
+	// Create test users
+	user1 := &models.User{Name: "HoldAlice2", Email: "holdalice2@test.com", Points: 1000}
+	user2 := &models.User{Name: "HoldBob2", Email: "holdbob2@test.com", Points: 0}
+	db.Create(user1)
+	db.Create(user2)
+
+	hold, err := service.CreateTransfer(&models.TransferCreateRequest{
+		FromUserID: user1.ID,
+		ToUserID:   user2.ID,
+		Amount:     400,
+		Hold:       true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create hold: %v", err)
+	}
+
+	voided, err := service.VoidTransfer(hold.IdempotencyKey, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if voided.Status != models.TransferStatusCancelled {
+		t.Errorf("Expected status cancelled, got: %s", voided.Status)
+	}
+
+	var sender models.User
+	db.First(&sender, user1.ID)
+
+	if sender.Points != 1000 || sender.HeldPoints != 0 {
+		t.Errorf("Expected points 1000 / held 0, got: %d / %d", sender.Points, sender.HeldPoints)
+	}
+
+	// A voided hold cannot be captured
+	if _, err := service.CaptureTransfer(hold.IdempotencyKey); err != services.ErrInvalidStatus {
+		t.Errorf("Expected ErrInvalidStatus, got: %v", err)
+	}
+}
+
+func TestHoldTransfer_Expiry(t *testing.T) {
+	db := setupTestDB(t)
+	service := services.NewTransferService(repository.NewGormStore(db))
+	service.SetHoldTTL(time.Millisecond)
+
+	// Create test users
+	user1 := &models.User{Name: "HoldAlice3", Email: "holdalice3@test.com", Points: 1000}
+	user2 := &models.User{Name: "HoldBob3", Email: "holdbob3@test.com", Points: 0}
+	db.Create(user1)
+	db.Create(user2)
+
+	expiring, _ := service.CreateTransfer(&models.TransferCreateRequest{
+		FromUserID: user1.ID,
+		ToUserID:   user2.ID,
+		Amount:     100,
+		Hold:       true,
+	})
+	swept, _ := service.CreateTransfer(&models.TransferCreateRequest{
+		FromUserID: user1.ID,
+		ToUserID:   user2.ID,
+		Amount:     200,
+		Hold:       true,
+	})
+
+	time.Sleep(5 * time.Millisecond)
+
+	// Capturing after expiry voids the hold
+	transfer, err := service.CaptureTransfer(expiring.IdempotencyKey)
+	if err != services.ErrHoldExpired {
+		t.Errorf("Expected ErrHoldExpired, got: %v", err)
+	}
+
+	if transfer == nil || transfer.Status != models.TransferStatusCancelled {
+		t.Error("Expected expired hold to be cancelled")
+	}
+
+	// The sweeper voids the rest
+	voided, err := service.VoidExpiredHolds(time.Now())
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if voided < 1 {
+		t.Errorf("Expected at least 1 voided hold, got: %d", voided)
+	}
+
+	found, _ := service.GetTransferByIdemKey(swept.IdempotencyKey)
+	if found.Status != models.TransferStatusCancelled {
+		t.Errorf("Expected swept hold to be cancelled, got: %s", found.Status)
+	}
+
+	var sender models.User
+	db.First(&sender, user1.ID)
+
+	if sender.HeldPoints != 0 {
+		t.Errorf("Expected no held points, got: %d", sender.HeldPoints)
+	}
+}
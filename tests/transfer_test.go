@@ -1,37 +1,108 @@
 package tests
 
 import (
+	"os"
+	"sync"
 	"testing"
 
+	"class-go-ai/database"
 	"class-go-ai/models"
+	"class-go-ai/repository"
 	"class-go-ai/services"
 
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
+// testDatabaseURL runs the suite against another database instead of
+// in-memory SQLite, e.g. TEST_DATABASE_URL=postgres://localhost/points_test
+var testDatabaseURL = os.Getenv("TEST_DATABASE_URL")
+
+// testModels are the tables every test database is migrated with
+var testModels = []interface{}{&models.User{}, &models.Transfer{}, &models.PointLedger{}, &models.LedgerHead{}, &models.ScheduledTransfer{}, &models.ScheduledTransferRun{}, &models.RefreshToken{}, &models.APIKey{}, &models.WebhookSubscription{}, &models.OutboxEvent{}, &models.WebhookDelivery{}}
+
 func setupTestDB(t *testing.T) *gorm.DB {
+	if testDatabaseURL != "" {
+		return openExternalTestDB(t, false)
+	}
 	// Create in-memory SQLite database
-	db, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{
+	return openTestDB(t, "file::memory:?cache=shared")
+}
+
+// setupIsolatedTestDB creates an in-memory database that no other test shares
+func setupIsolatedTestDB(t *testing.T) *gorm.DB {
+	if testDatabaseURL != "" {
+		return openExternalTestDB(t, true)
+	}
+	return openTestDB(t, "file:"+t.Name()+"?mode=memory&cache=shared")
+}
+
+func openTestDB(t *testing.T, dsn string) *gorm.DB {
+	db, err := database.Open(dsn, &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Silent),
 	})
 	if err != nil {
 		t.Fatalf("Failed to connect to test database: %v", err)
 	}
 
-	// Auto migrate
-	err = db.AutoMigrate(&models.User{}, &models.Transfer{}, &models.PointLedger{})
-	if err != nil {
+	// Apply the migrations
+	if err := migrateUp(db); err != nil {
 		t.Fatalf("Failed to migrate test database: %v", err)
 	}
 
 	return db
 }
 
+// migrateUp applies every migration to db
+func migrateUp(db *gorm.DB) error {
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	_, err = migrator.Up()
+	return err
+}
+
+var (
+	externalOnce sync.Once
+	externalDB   *gorm.DB
+	externalErr  error
+)
+
+// openExternalTestDB connects to TEST_DATABASE_URL, recreating its tables on
+// first use. All tests share it; isolated tests empty it first instead of
+// getting their own, which is safe because the suite does not run tests in
+// parallel.
+func openExternalTestDB(t *testing.T, isolated bool) *gorm.DB {
+	externalOnce.Do(func() {
+		externalDB, externalErr = database.Open(testDatabaseURL, &gorm.Config{
+			Logger: logger.Default.LogMode(logger.Silent),
+		})
+		if externalErr == nil {
+			externalErr = externalDB.Migrator().DropTable(append(testModels, &database.SchemaMigration{})...)
+		}
+		if externalErr == nil {
+			externalErr = migrateUp(externalDB)
+		}
+	})
+	if externalErr != nil {
+		t.Fatalf("Failed to set up test database: %v", externalErr)
+	}
+
+	if isolated {
+		for i := len(testModels) - 1; i >= 0; i-- {
+			if err := externalDB.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(testModels[i]).Error; err != nil {
+				t.Fatalf("Failed to empty test database: %v", err)
+			}
+		}
+	}
+
+	return externalDB
+}
+
 func TestCreateTransfer_Success(t *testing.T) {
 	db := setupTestDB(t)
-	service := services.NewTransferService(db)
+	service := services.NewTransferService(repository.NewGormStore(db))
 
 	// Create test users
 	user1 := &models.User{Name: "Alice", Email: "alice@test.com", Points: 1000}
@@ -75,9 +146,9 @@ func TestCreateTransfer_Success(t *testing.T) {
 		t.Errorf("Expected user2 points 750, got: %d", updatedUser2.Points)
 	}
 
-	// Verify ledger entries
+	// Verify ledger entries (the shared test DB holds other tests' rows too)
 	var ledgers []models.PointLedger
-	db.Find(&ledgers)
+	db.Where("transfer_id = ?", transfer.ID).Find(&ledgers)
 
 	if len(ledgers) != 2 {
 		t.Errorf("Expected 2 ledger entries, got: %d", len(ledgers))
@@ -86,7 +157,7 @@ func TestCreateTransfer_Success(t *testing.T) {
 
 func TestCreateTransfer_InsufficientPoints(t *testing.T) {
 	db := setupTestDB(t)
-	service := services.NewTransferService(db)
+	service := services.NewTransferService(repository.NewGormStore(db))
 
 	// Create test users
 	user1 := &models.User{Name: "Alice2", Email: "alice2@test.com", Points: 100}
@@ -135,7 +206,7 @@ func TestCreateTransfer_InsufficientPoints(t *testing.T) {
 
 func TestCreateTransfer_SameUser(t *testing.T) {
 	db := setupTestDB(t)
-	service := services.NewTransferService(db)
+	service := services.NewTransferService(repository.NewGormStore(db))
 
 	// Create test user
 	user1 := &models.User{Name: "Alice3", Email: "alice3@test.com", Points: 1000}
@@ -158,7 +229,7 @@ func TestCreateTransfer_SameUser(t *testing.T) {
 
 func TestGetTransferByIdemKey(t *testing.T) {
 	db := setupTestDB(t)
-	service := services.NewTransferService(db)
+	service := services.NewTransferService(repository.NewGormStore(db))
 
 	// Create test users
 	user1 := &models.User{Name: "Alice4", Email: "alice4@test.com", Points: 1000}
@@ -196,7 +267,7 @@ func TestGetTransferByIdemKey(t *testing.T) {
 
 func TestGetTransfersByUserID(t *testing.T) {
 	db := setupTestDB(t)
-	service := services.NewTransferService(db)
+	service := services.NewTransferService(repository.NewGormStore(db))
 
 	// Create test users
 	user1 := &models.User{Name: "Alice5", Email: "alice5@test.com", Points: 1000}
@@ -234,3 +305,242 @@ func TestGetTransfersByUserID(t *testing.T) {
 		t.Errorf("Expected 2 transfer records, got: %d", len(result.Data))
 	}
 }
+
+func TestCreateTransfer_IdempotentReplay(t *testing.T) {
+	db := setupTestDB(t)
+	service := services.NewTransferService(repository.NewGormStore(db))
+
+	// Create test users
+	user1 := &models.User{Name: "Alice6", Email: "alice6@test.com", Points: 1000}
+	user2 := &models.User{Name: "Bob6", Email: "bob6@test.com", Points: 500}
+	db.Create(user1)
+	db.Create(user2)
+
+	req := &models.TransferCreateRequest{
+		FromUserID:     user1.ID,
+		ToUserID:       user2.ID,
+		Amount:         100,
+		IdempotencyKey: "idem-replay-6",
+	}
+
+	first, err := service.CreateTransfer(req)
+	if err != nil {
+		t.Fatalf("Failed to create transfer: %v", err)
+	}
+
+	// Retry with the same key and payload
+	second, err := service.CreateTransfer(req)
+	if err != nil {
+		t.Fatalf("Expected replay without error, got: %v", err)
+	}
+
+	if second.ID != first.ID {
+		t.Errorf("Expected replay of transfer %d, got: %d", first.ID, second.ID)
+	}
+
+	// Points should only move once
+	var updatedUser1 models.User
+	db.First(&updatedUser1, user1.ID)
+
+	if updatedUser1.Points != 900 {
+		t.Errorf("Expected user1 points 900, got: %d", updatedUser1.Points)
+	}
+}
+
+func TestCreateTransfer_IdempotencyKeyReused(t *testing.T) {
+	db := setupTestDB(t)
+	service := services.NewTransferService(repository.NewGormStore(db))
+
+	// Create test users
+	user1 := &models.User{Name: "Alice7", Email: "alice7@test.com", Points: 1000}
+	user2 := &models.User{Name: "Bob7", Email: "bob7@test.com", Points: 500}
+	db.Create(user1)
+	db.Create(user2)
+
+	req := &models.TransferCreateRequest{
+		FromUserID:     user1.ID,
+		ToUserID:       user2.ID,
+		Amount:         100,
+		IdempotencyKey: "idem-reuse-7",
+	}
+
+	if _, err := service.CreateTransfer(req); err != nil {
+		t.Fatalf("Failed to create transfer: %v", err)
+	}
+
+	// Same key, different amount
+	req.Amount = 200
+	_, err := service.CreateTransfer(req)
+
+	if err != services.ErrIdempotencyKeyUsed {
+		t.Errorf("Expected ErrIdempotencyKeyUsed, got: %v", err)
+	}
+}
+
+func TestCreateTransfer_FailedReplay(t *testing.T) {
+	db := setupTestDB(t)
+	service := services.NewTransferService(repository.NewGormStore(db))
+
+	// Create test users
+	user1 := &models.User{Name: "Alice8", Email: "alice8@test.com", Points: 50}
+	user2 := &models.User{Name: "Bob8", Email: "bob8@test.com", Points: 500}
+	db.Create(user1)
+	db.Create(user2)
+
+	req := &models.TransferCreateRequest{
+		FromUserID:     user1.ID,
+		ToUserID:       user2.ID,
+		Amount:         100,
+		IdempotencyKey: "idem-failed-8",
+	}
+
+	first, err := service.CreateTransfer(req)
+	if err != services.ErrInsufficientPoints {
+		t.Fatalf("Expected ErrInsufficientPoints, got: %v", err)
+	}
+
+	// Replay should return the recorded failure
+	second, err := service.CreateTransfer(req)
+	if err != services.ErrInsufficientPoints {
+		t.Errorf("Expected ErrInsufficientPoints on replay, got: %v", err)
+	}
+
+	if second == nil || second.ID != first.ID {
+		t.Error("Expected replay to return the original failed transfer")
+	}
+}
+
+func TestReverseTransfer_Success(t *testing.T) {
+	db := setupTestDB(t)
+	service := services.NewTransferService(repository.NewGormStore(db))
+
+	// Create test users
+	user1 := &models.User{Name: "Alice9", Email: "alice9@test.com", Points: 1000}
+	user2 := &models.User{Name: "Bob9", Email: "bob9@test.com", Points: 0}
+	db.Create(user1)
+	db.Create(user2)
+
+	transfer, err := service.CreateTransfer(&models.TransferCreateRequest{
+		FromUserID: user1.ID,
+		ToUserID:   user2.ID,
+		Amount:     300,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create transfer: %v", err)
+	}
+
+	reversed, err := service.ReverseTransfer(transfer.IdempotencyKey, "Sent by mistake")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if reversed.Status != models.TransferStatusReversed {
+		t.Errorf("Expected status reversed, got: %s", reversed.Status)
+	}
+
+	if reversed.Reason != "Sent by mistake" {
+		t.Errorf("Expected reason to be recorded, got: %q", reversed.Reason)
+	}
+
+	// Points should be back where they started
+	var updatedUser1, updatedUser2 models.User
+	db.First(&updatedUser1, user1.ID)
+	db.First(&updatedUser2, user2.ID)
+
+	if updatedUser1.Points != 1000 {
+		t.Errorf("Expected user1 points 1000, got: %d", updatedUser1.Points)
+	}
+
+	if updatedUser2.Points != 0 {
+		t.Errorf("Expected user2 points 0, got: %d", updatedUser2.Points)
+	}
+
+	// Original pair plus compensating pair
+	var count int64
+	db.Model(&models.PointLedger{}).Where("transfer_id = ?", transfer.ID).Count(&count)
+
+	if count != 4 {
+		t.Errorf("Expected 4 ledger entries for transfer, got: %d", count)
+	}
+
+	// A reversed transfer cannot be reversed again
+	if _, err := service.ReverseTransfer(transfer.IdempotencyKey, "again"); err != services.ErrInvalidStatus {
+		t.Errorf("Expected ErrInvalidStatus, got: %v", err)
+	}
+}
+
+func TestReverseTransfer_ReceiverNoFunds(t *testing.T) {
+	db := setupTestDB(t)
+	service := services.NewTransferService(repository.NewGormStore(db))
+
+	// Create test users
+	user1 := &models.User{Name: "Alice10", Email: "alice10@test.com", Points: 1000}
+	user2 := &models.User{Name: "Bob10", Email: "bob10@test.com", Points: 0}
+	user3 := &models.User{Name: "Charlie10", Email: "charlie10@test.com", Points: 0}
+	db.Create(user1)
+	db.Create(user2)
+	db.Create(user3)
+
+	transfer, err := service.CreateTransfer(&models.TransferCreateRequest{
+		FromUserID: user1.ID,
+		ToUserID:   user2.ID,
+		Amount:     300,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create transfer: %v", err)
+	}
+
+	// Receiver spends the points
+	if _, err := service.CreateTransfer(&models.TransferCreateRequest{
+		FromUserID: user2.ID,
+		ToUserID:   user3.ID,
+		Amount:     200,
+	}); err != nil {
+		t.Fatalf("Failed to create transfer: %v", err)
+	}
+
+	_, err = service.ReverseTransfer(transfer.IdempotencyKey, "Sent by mistake")
+	if err != services.ErrReceiverNoFunds {
+		t.Errorf("Expected ErrReceiverNoFunds, got: %v", err)
+	}
+}
+
+func TestCancelTransfer(t *testing.T) {
+	db := setupTestDB(t)
+	service := services.NewTransferService(repository.NewGormStore(db))
+
+	// Create test users
+	user1 := &models.User{Name: "Alice11", Email: "alice11@test.com", Points: 1000}
+	user2 := &models.User{Name: "Bob11", Email: "bob11@test.com", Points: 0}
+	db.Create(user1)
+	db.Create(user2)
+
+	pending := &models.Transfer{
+		FromUserID:     user1.ID,
+		ToUserID:       user2.ID,
+		Amount:         100,
+		Status:         models.TransferStatusPending,
+		IdempotencyKey: "idem-cancel-11",
+	}
+	db.Create(pending)
+
+	cancelled, err := service.CancelTransfer(pending.IdempotencyKey, "Changed my mind")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if cancelled.Status != models.TransferStatusCancelled {
+		t.Errorf("Expected status cancelled, got: %s", cancelled.Status)
+	}
+
+	// Completed transfers cannot be cancelled
+	completed, _ := service.CreateTransfer(&models.TransferCreateRequest{
+		FromUserID: user1.ID,
+		ToUserID:   user2.ID,
+		Amount:     100,
+	})
+
+	if _, err := service.CancelTransfer(completed.IdempotencyKey, ""); err != services.ErrInvalidStatus {
+		t.Errorf("Expected ErrInvalidStatus, got: %v", err)
+	}
+}
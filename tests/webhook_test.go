@@ -0,0 +1,281 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"class-go-ai/middleware"
+	"class-go-ai/models"
+	"class-go-ai/repository"
+	"class-go-ai/services"
+	"class-go-ai/signing"
+)
+
+// webhookReceiver records the deliveries it accepts and verifies signatures
+type webhookReceiver struct {
+	mu       sync.Mutex
+	secret   []byte
+	status   int
+	payloads []models.WebhookPayload
+	invalid  int
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !signing.Verify(r.secret, req.Method, req.URL.RequestURI(),
+		req.Header.Get(signing.HeaderTimestamp), req.Header.Get(signing.HeaderNonce),
+		body, req.Header.Get(signing.HeaderSignature)) {
+		r.invalid++
+		w.WriteHeader(401)
+		return
+	}
+
+	var payload models.WebhookPayload
+	json.Unmarshal(body, &payload)
+	r.payloads = append(r.payloads, payload)
+	w.WriteHeader(r.status)
+}
+
+func TestWebhook_DeliversSignedEvents(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	transfers := services.NewTransferService(repository.NewGormStore(db))
+	webhooks := services.NewWebhookService(db)
+	webhooks.SetAllowPrivateTargets(true)
+
+	sender := &models.User{Name: "HookSender", Email: "hooksender@test.com", Points: 100}
+	receiver := &models.User{Name: "HookReceiver", Email: "hookreceiver@test.com"}
+	stranger := &models.User{Name: "HookStranger", Email: "hookstranger@test.com", Points: 100}
+	db.Create(sender)
+	db.Create(receiver)
+	db.Create(stranger)
+
+	recv := &webhookReceiver{secret: []byte("whsec_test"), status: 200}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	_, _, err := webhooks.CreateSubscription(sender.ID, &models.WebhookCreateRequest{
+		URL:    server.URL + "/hooks",
+		Secret: "whsec_test",
+		Events: []models.WebhookEvent{models.WebhookTransferCompleted, models.WebhookTransferFailed},
+	}, false)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	completed, _ := transfers.CreateTransfer(&models.TransferCreateRequest{FromUserID: sender.ID, ToUserID: receiver.ID, Amount: 60})
+	transfers.CreateTransfer(&models.TransferCreateRequest{FromUserID: sender.ID, ToUserID: receiver.ID, Amount: 500})
+	transfers.ReverseTransfer(completed.IdempotencyKey, "filtered out")
+	transfers.CreateTransfer(&models.TransferCreateRequest{FromUserID: stranger.ID, ToUserID: receiver.ID, Amount: 10})
+
+	// Every change wrote its event in the same transaction
+	var outbox int64
+	db.Model(&models.OutboxEvent{}).Count(&outbox)
+	if outbox != 4 {
+		t.Fatalf("Expected 4 outbox events, got %d", outbox)
+	}
+
+	now := time.Now()
+	if _, err := webhooks.Dispatch(now); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	delivered, err := webhooks.DeliverDue(context.Background(), now)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// The reversal is filtered by event and the stranger's transfer by owner
+	if delivered != 2 || len(recv.payloads) != 2 || recv.invalid != 0 {
+		t.Fatalf("Expected 2 valid deliveries, got %d (%d invalid)", len(recv.payloads), recv.invalid)
+	}
+	if recv.payloads[0].Type != models.WebhookTransferCompleted || recv.payloads[0].Transfer.ID != completed.ID {
+		t.Errorf("Expected completed event for transfer %d, got: %+v", completed.ID, recv.payloads[0])
+	}
+	if recv.payloads[1].Type != models.WebhookTransferFailed {
+		t.Errorf("Expected failed event, got: %s", recv.payloads[1].Type)
+	}
+}
+
+func TestWebhook_AtomicBatchRollbackWritesNoEvents(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	transfers := services.NewTransferService(repository.NewGormStore(db))
+
+	sender := &models.User{Name: "HookBatchSender", Email: "hookbatchsender@test.com", Points: 50}
+	receiver := &models.User{Name: "HookBatchReceiver", Email: "hookbatchreceiver@test.com"}
+	db.Create(sender)
+	db.Create(receiver)
+
+	transfers.CreateTransferBatch([]*models.TransferCreateRequest{
+		{FromUserID: sender.ID, ToUserID: receiver.ID, Amount: 30},
+		{FromUserID: sender.ID, ToUserID: receiver.ID, Amount: 30},
+	}, true)
+
+	var outbox int64
+	db.Model(&models.OutboxEvent{}).Count(&outbox)
+	if outbox != 0 {
+		t.Errorf("Expected no outbox events after rollback, got %d", outbox)
+	}
+}
+
+func TestWebhook_BackoffDeadLetterAndRedeliver(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	transfers := services.NewTransferService(repository.NewGormStore(db))
+	webhooks := services.NewWebhookService(db)
+	webhooks.SetRetryPolicy(3, time.Minute)
+	webhooks.SetAllowPrivateTargets(true)
+
+	sender := &models.User{Name: "HookRetrySender", Email: "hookretrysender@test.com", Points: 100}
+	receiver := &models.User{Name: "HookRetryReceiver", Email: "hookretryreceiver@test.com"}
+	db.Create(sender)
+	db.Create(receiver)
+
+	recv := &webhookReceiver{secret: []byte("whsec_retry"), status: 500}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	subscription, _, _ := webhooks.CreateSubscription(sender.ID, &models.WebhookCreateRequest{URL: server.URL, Secret: "whsec_retry"}, false)
+	transfers.CreateTransfer(&models.TransferCreateRequest{FromUserID: sender.ID, ToUserID: receiver.ID, Amount: 10})
+
+	now := time.Now()
+	webhooks.Dispatch(now)
+
+	// Attempts at +0, +1m and +3m (1m then 2m backoff), then dead
+	ctx := context.Background()
+	for _, offset := range []time.Duration{0, 30 * time.Second, time.Minute, 2 * time.Minute, 3 * time.Minute} {
+		webhooks.DeliverDue(ctx, now.Add(offset))
+	}
+
+	deliveries, _ := webhooks.ListDeliveries(subscription.ID, 10)
+	if len(deliveries) != 1 {
+		t.Fatalf("Expected 1 delivery, got %d", len(deliveries))
+	}
+	delivery := deliveries[0]
+	if delivery.Status != models.DeliveryDead || delivery.Attempts != 3 || delivery.LastStatusCode != 500 {
+		t.Fatalf("Expected dead delivery after 3 attempts, got: %+v", delivery)
+	}
+	if len(recv.payloads) != 3 {
+		t.Errorf("Expected 3 requests, got %d", len(recv.payloads))
+	}
+
+	// Redelivery succeeds once the receiver recovers
+	recv.status = 204
+	if _, err := webhooks.Redeliver(subscription.ID, delivery.ID); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if delivered, _ := webhooks.DeliverDue(ctx, time.Now()); delivered != 1 {
+		t.Errorf("Expected redelivery to succeed, got %d", delivered)
+	}
+
+	deliveries, _ = webhooks.ListDeliveries(subscription.ID, 10)
+	if deliveries[0].Status != models.DeliveryDelivered {
+		t.Errorf("Expected delivered status, got %s", deliveries[0].Status)
+	}
+}
+
+func TestWebhook_RefusesInternalTargets(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	transfers := services.NewTransferService(repository.NewGormStore(db))
+	webhooks := services.NewWebhookService(db)
+
+	sender := &models.User{Name: "HookSSRFSender", Email: "hookssrfsender@test.com", Points: 100}
+	receiver := &models.User{Name: "HookSSRFReceiver", Email: "hookssrfreceiver@test.com"}
+	db.Create(sender)
+	db.Create(receiver)
+
+	for _, target := range []string{
+		"http://127.0.0.1:8080/hooks",
+		"http://localhost/hooks",
+		"http://10.1.2.3/hooks",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::1]/hooks",
+		"http://0.0.0.0/hooks",
+	} {
+		_, _, err := webhooks.CreateSubscription(sender.ID, &models.WebhookCreateRequest{URL: target}, false)
+		if !errors.Is(err, services.ErrPrivateURL) {
+			t.Errorf("Expected ErrPrivateURL for %s, got: %v", target, err)
+		}
+	}
+
+	// A subscription whose host later points inside is refused when dialled
+	recv := &webhookReceiver{secret: []byte("whsec_rebind"), status: 200}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+	webhooks.SetAllowPrivateTargets(true)
+	subscription, _, err := webhooks.CreateSubscription(sender.ID, &models.WebhookCreateRequest{URL: server.URL, Secret: "whsec_rebind"}, false)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	webhooks.SetAllowPrivateTargets(false)
+
+	transfers.CreateTransfer(&models.TransferCreateRequest{FromUserID: sender.ID, ToUserID: receiver.ID, Amount: 10})
+	now := time.Now()
+	webhooks.Dispatch(now)
+	if delivered, _ := webhooks.DeliverDue(context.Background(), now); delivered != 0 || len(recv.payloads) != 0 {
+		t.Fatalf("Expected no delivery, got %d", delivered)
+	}
+	deliveries, _ := webhooks.ListDeliveries(subscription.ID, 10)
+	if len(deliveries) != 1 || !strings.Contains(deliveries[0].LastError, services.ErrPrivateURL.Error()) {
+		t.Errorf("Expected the dial refused, got: %+v", deliveries)
+	}
+}
+
+func TestWebhook_DemotedOwnerStopsSeeingAllUsers(t *testing.T) {
+	db := setupIsolatedTestDB(t)
+	transfers := services.NewTransferService(repository.NewGormStore(db))
+	webhooks := services.NewWebhookService(db)
+	webhooks.SetAllUsersPermission(func(role models.Role) bool {
+		return middleware.RoleCan(role, middleware.PermTransfersRead)
+	})
+
+	owner := &models.User{Name: "HookSupport", Email: "hooksupport@test.com", Role: models.RoleSupport, Points: 100}
+	sender := &models.User{Name: "HookDemoteSender", Email: "hookdemotesender@test.com", Points: 100}
+	receiver := &models.User{Name: "HookDemoteReceiver", Email: "hookdemotereceiver@test.com"}
+	db.Create(owner)
+	db.Create(sender)
+	db.Create(receiver)
+
+	webhooks.SetAllowPrivateTargets(true)
+	subscription, _, err := webhooks.CreateSubscription(owner.ID, &models.WebhookCreateRequest{URL: "http://127.0.0.1/hooks"}, true)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	deliveries := func() int {
+		var count int64
+		db.Model(&models.WebhookDelivery{}).Where("subscription_id = ?", subscription.ID).Count(&count)
+		return int(count)
+	}
+
+	now := time.Now()
+	transfers.CreateTransfer(&models.TransferCreateRequest{FromUserID: sender.ID, ToUserID: receiver.ID, Amount: 10})
+	webhooks.Dispatch(now)
+	if got := deliveries(); got != 1 {
+		t.Fatalf("Expected support to receive another user's transfer, got %d deliveries", got)
+	}
+
+	db.Model(&models.User{}).Where("id = ?", owner.ID).Update("role", models.RoleMember)
+
+	transfers.CreateTransfer(&models.TransferCreateRequest{FromUserID: sender.ID, ToUserID: receiver.ID, Amount: 10})
+	webhooks.Dispatch(now)
+	if got := deliveries(); got != 1 {
+		t.Errorf("Expected no delivery of other users' transfers after the demotion, got %d", got)
+	}
+
+	// The owner's own transfers still arrive
+	transfers.CreateTransfer(&models.TransferCreateRequest{FromUserID: owner.ID, ToUserID: receiver.ID, Amount: 10})
+	webhooks.Dispatch(now)
+	if got := deliveries(); got != 2 {
+		t.Errorf("Expected the owner's own transfer delivered, got %d", got)
+	}
+}
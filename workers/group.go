@@ -0,0 +1,113 @@
+package workers
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// staleAfter is how many intervals a worker may miss its heartbeat before
+// it is reported as stale
+const staleAfter = 3
+
+// Group runs background workers until Stop, lets shutdown wait for the run
+// each of them is in the middle of, and tracks their heartbeats
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	workers map[string]*worker
+}
+
+// worker is the state of one worker in a group
+type worker struct {
+	interval time.Duration
+	lastBeat atomic.Int64 // unix nanoseconds
+	running  atomic.Bool
+}
+
+// NewGroup creates a group whose workers stop when ctx is done or on Stop
+func NewGroup(ctx context.Context) *Group {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Group{ctx: ctx, cancel: cancel, workers: make(map[string]*worker)}
+}
+
+// Go runs fn in its own goroutine under name. fn should call Beat with its
+// context each time it starts a run, at least every interval; a run that
+// hangs then shows up in Stale.
+func (g *Group) Go(name string, interval time.Duration, fn func(ctx context.Context)) {
+	w := &worker{interval: interval}
+	w.lastBeat.Store(time.Now().UnixNano())
+	w.running.Store(true)
+
+	g.mu.Lock()
+	g.workers[name] = w
+	g.mu.Unlock()
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer w.running.Store(false)
+		fn(context.WithValue(g.ctx, workerKey{}, w))
+	}()
+}
+
+type workerKey struct{}
+
+// Beat records that the worker running with ctx is alive. It does nothing
+// outside a Group.
+func Beat(ctx context.Context) {
+	if w, ok := ctx.Value(workerKey{}).(*worker); ok {
+		w.lastBeat.Store(time.Now().UnixNano())
+	}
+}
+
+// Stale returns the names of the workers that have returned, or missed
+// their heartbeat for several intervals as of now
+func (g *Group) Stale(now time.Time) []string {
+	return g.names(func(w *worker) bool {
+		lastBeat := time.Unix(0, w.lastBeat.Load())
+		return !w.running.Load() || now.Sub(lastBeat) > staleAfter*w.interval
+	})
+}
+
+// Stop tells every worker to return once its current run is finished
+func (g *Group) Stop() {
+	g.cancel()
+}
+
+// Wait waits until every worker has returned or ctx is done, and returns
+// the names of the workers still running
+func (g *Group) Wait(ctx context.Context) []string {
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+	return g.names(func(w *worker) bool { return w.running.Load() })
+}
+
+// names returns the sorted names of the workers matching keep
+func (g *Group) names(keep func(w *worker) bool) []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var names []string
+	for name, w := range g.workers {
+		if keep(w) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,33 @@
+// Package workers contains the background jobs started from main.go
+package workers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"class-go-ai/services"
+)
+
+// RunHoldExpiry voids expired transfer holds every interval until ctx is done
+func RunHoldExpiry(ctx context.Context, service *services.TransferService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			Beat(ctx)
+			voided, err := service.VoidExpiredHolds(now)
+			if err != nil {
+				log.Println("Failed to void expired holds:", err)
+				continue
+			}
+			if voided > 0 {
+				log.Printf("Voided %d expired hold(s)", voided)
+			}
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"class-go-ai/services"
+)
+
+// RunScheduler executes due scheduled transfers every interval until ctx is done
+func RunScheduler(ctx context.Context, service *services.ScheduleService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			Beat(ctx)
+			ran, err := service.RunDue(now)
+			if err != nil {
+				log.Println("Failed to run scheduled transfers:", err)
+				continue
+			}
+			if ran > 0 {
+				log.Printf("Ran %d scheduled transfer(s)", ran)
+			}
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"class-go-ai/services"
+)
+
+// RunWebhookDelivery fans out outbox events and delivers due webhooks every
+// interval until ctx is done
+func RunWebhookDelivery(ctx context.Context, service *services.WebhookService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			Beat(ctx)
+			if _, err := service.Dispatch(now); err != nil {
+				log.Println("Failed to dispatch webhook events:", err)
+				continue
+			}
+			delivered, err := service.DeliverDue(ctx, now)
+			if err != nil {
+				log.Println("Failed to deliver webhooks:", err)
+				continue
+			}
+			if delivered > 0 {
+				log.Printf("Delivered %d webhook(s)", delivered)
+			}
+		}
+	}
+}